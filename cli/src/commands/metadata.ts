@@ -6,10 +6,12 @@ import {
   getFileMetadata,
   getGlobalMetadata,
   hasMetadataKey,
+  lastUpdateMetadata,
   removeFileMetadata,
   removeGlobalMetadata,
   setFileMetadata,
   setGlobalMetadata,
+  withLastUpdate,
 } from '../lib/website/metadata'
 import { Metadata } from '../lib/website/models/Metadata'
 import { listFiles } from '../lib/website/read'
@@ -69,6 +71,8 @@ export const metadataCommand = new Command('metadata')
     }
 
     let operation
+    // Set when the LAST_UPDATE metadata is part of the operation, otherwise it is updated afterwards
+    let lastUpdateSet = false
     if (options.file) {
       const { files } = await listFiles(provider, webSiteAddress)
 
@@ -163,8 +167,9 @@ export const metadataCommand = new Command('metadata')
         operation = await setGlobalMetadata(
           provider,
           webSiteAddress,
-          updateRequired
+          withLastUpdate(updateRequired)
         )
+        lastUpdateSet = true
       }
     }
     console.log(
@@ -172,6 +177,20 @@ export const metadataCommand = new Command('metadata')
     )
     await operation!.waitSpeculativeExecution()
     console.log(`Operation finalized.`)
+
+    // Gateways only drop their cached copy of the website when its LAST_UPDATE metadata changes
+    if (!lastUpdateSet) {
+      const lastUpdateOperation = await setGlobalMetadata(
+        provider,
+        webSiteAddress,
+        [lastUpdateMetadata()]
+      )
+      console.log(
+        `\nWaiting for the last update to be finalized. id: ${lastUpdateOperation.id}`
+      )
+      await lastUpdateOperation.waitSpeculativeExecution()
+      console.log(`Operation finalized.`)
+    }
   })
 
 function parseAddOption(addOption: string): Metadata[] {
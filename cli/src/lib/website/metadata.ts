@@ -213,6 +213,37 @@ function setMetadataStorageCost(
   )
 }
 
+/**
+ * Returns the LAST_UPDATE global metadata set to the given date.
+ * Gateways watch this metadata to drop their cached copy of a website, so it must be
+ * updated along with every change of files or metadata, including deletions.
+ * @param date - Date of the update
+ * @returns - The LAST_UPDATE Metadata object, holding a UNIX timestamp in seconds
+ */
+export function lastUpdateMetadata(date: Date = new Date()): Metadata {
+  return new Metadata(
+    LAST_UPDATE_KEY,
+    Math.floor(date.getTime() / 1000).toString()
+  )
+}
+
+/**
+ * Returns the metadata to set to record a change of a website:
+ * the given metadata, with the LAST_UPDATE metadata set to the given date in place of any given value.
+ * @param metadatas - List of Metadata objects changed on the website
+ * @param date - Date of the update
+ * @returns - List of Metadata objects to set
+ */
+export function withLastUpdate(
+  metadatas: Metadata[],
+  date: Date = new Date()
+): Metadata[] {
+  return [
+    ...metadatas.filter((m) => m.key !== LAST_UPDATE_KEY),
+    lastUpdateMetadata(date),
+  ]
+}
+
 /**
  * Set a list of global metadata on a website stored on Massa blockchain
  * @param provider - Provider instance
@@ -1,5 +1,5 @@
 import { ListrEnquirerPromptAdapter } from '@listr2/prompt-adapter-enquirer'
-import { formatMas, OperationStatus, U64 } from '@massalabs/massa-web3'
+import { formatMas, OperationStatus } from '@massalabs/massa-web3'
 import { ListrTask } from 'listr2'
 
 import {
@@ -7,8 +7,7 @@ import {
   prepareCost,
   sendFilesInits,
 } from '../lib/website/filesInit'
-import { LAST_UPDATE_KEY } from '../lib/website/metadata'
-import { Metadata } from '../lib/website/models/Metadata'
+import { withLastUpdate } from '../lib/website/metadata'
 
 import { UploadCtx } from './tasks'
 
@@ -30,9 +29,8 @@ export function prepareUploadTask(): ListrTask {
         return
       }
 
-      const utcNowDate = U64.fromNumber(Math.floor(Date.now() / 1000))
-
-      ctx.metadatas.push(new Metadata(LAST_UPDATE_KEY, utcNowDate.toString()))
+      // Deleted files and metadata only changes are recorded as well, for gateways to see them
+      ctx.metadatas = withLastUpdate(ctx.metadatas)
 
       return task.newListr(
         [
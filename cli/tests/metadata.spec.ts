@@ -0,0 +1,49 @@
+import {
+  LAST_UPDATE_KEY,
+  lastUpdateMetadata,
+  withLastUpdate,
+} from '../src/lib/website/metadata'
+import { Metadata } from '../src/lib/website/models/Metadata'
+
+describe('lastUpdateMetadata', () => {
+  it('should hold the UNIX timestamp of the date in seconds', () => {
+    const metadata = lastUpdateMetadata(new Date(1700000000999))
+
+    expect(metadata.key).toBe(LAST_UPDATE_KEY)
+    expect(metadata.value).toBe('1700000000')
+  })
+})
+
+describe('withLastUpdate', () => {
+  const date = new Date(1700000000000)
+
+  it('should record a change of metadata only', () => {
+    const metadatas = withLastUpdate(
+      [new Metadata('TITLE', 'My website')],
+      date
+    )
+
+    expect(metadatas).toEqual([
+      new Metadata('TITLE', 'My website'),
+      new Metadata(LAST_UPDATE_KEY, '1700000000'),
+    ])
+  })
+
+  it('should record a change without metadata, such as deleted files', () => {
+    expect(withLastUpdate([], date)).toEqual([
+      new Metadata(LAST_UPDATE_KEY, '1700000000'),
+    ])
+  })
+
+  it('should replace a given last update', () => {
+    const metadatas = withLastUpdate(
+      [new Metadata(LAST_UPDATE_KEY, '1'), new Metadata('TITLE', 'My website')],
+      date
+    )
+
+    expect(metadatas).toEqual([
+      new Metadata('TITLE', 'My website'),
+      new Metadata(LAST_UPDATE_KEY, '1700000000'),
+    ])
+  })
+})
@@ -33,6 +33,9 @@ type DeWebInfo struct {
 	// network
 	Network *DeWebInfoNetwork `json:"network,omitempty"`
 
+	// verification
+	Verification *DeWebInfoVerification `json:"verification,omitempty"`
+
 	// version
 	Version string `json:"version,omitempty"`
 }
@@ -45,6 +48,10 @@ func (m *DeWebInfo) Validate(formats strfmt.Registry) error {
 		res = append(res, err)
 	}
 
+	if err := m.validateVerification(formats); err != nil {
+		res = append(res, err)
+	}
+
 	if len(res) > 0 {
 		return errors.CompositeValidationError(res...)
 	}
@@ -70,6 +77,25 @@ func (m *DeWebInfo) validateNetwork(formats strfmt.Registry) error {
 	return nil
 }
 
+func (m *DeWebInfo) validateVerification(formats strfmt.Registry) error {
+	if swag.IsZero(m.Verification) { // not required
+		return nil
+	}
+
+	if m.Verification != nil {
+		if err := m.Verification.Validate(formats); err != nil {
+			if ve, ok := err.(*errors.Validation); ok {
+				return ve.ValidateName("verification")
+			} else if ce, ok := err.(*errors.CompositeError); ok {
+				return ce.ValidateName("verification")
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
 // ContextValidate validate this de web info based on the context it is used
 func (m *DeWebInfo) ContextValidate(ctx context.Context, formats strfmt.Registry) error {
 	var res []error
@@ -78,6 +104,10 @@ func (m *DeWebInfo) ContextValidate(ctx context.Context, formats strfmt.Registry
 		res = append(res, err)
 	}
 
+	if err := m.contextValidateVerification(ctx, formats); err != nil {
+		res = append(res, err)
+	}
+
 	if len(res) > 0 {
 		return errors.CompositeValidationError(res...)
 	}
@@ -105,6 +135,27 @@ func (m *DeWebInfo) contextValidateNetwork(ctx context.Context, formats strfmt.R
 	return nil
 }
 
+func (m *DeWebInfo) contextValidateVerification(ctx context.Context, formats strfmt.Registry) error {
+
+	if m.Verification != nil {
+
+		if swag.IsZero(m.Verification) { // not required
+			return nil
+		}
+
+		if err := m.Verification.ContextValidate(ctx, formats); err != nil {
+			if ve, ok := err.(*errors.Validation); ok {
+				return ve.ValidateName("verification")
+			} else if ce, ok := err.(*errors.CompositeError); ok {
+				return ce.ValidateName("verification")
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
 // MarshalBinary interface implementation
 func (m *DeWebInfo) MarshalBinary() ([]byte, error) {
 	if m == nil {
@@ -131,6 +182,9 @@ type DeWebInfoNetwork struct {
 	// chain ID
 	ChainID int64 `json:"chainID,omitempty"`
 
+	// degraded
+	Degraded bool `json:"degraded,omitempty"`
+
 	// network
 	Network string `json:"network,omitempty"`
 
@@ -165,3 +219,49 @@ func (m *DeWebInfoNetwork) UnmarshalBinary(b []byte) error {
 	*m = res
 	return nil
 }
+
+// DeWebInfoVerification de web info verification
+//
+// swagger:model DeWebInfoVerification
+type DeWebInfoVerification struct {
+
+	// mismatches
+	Mismatches int64 `json:"mismatches,omitempty"`
+
+	// mode
+	Mode string `json:"mode,omitempty"`
+
+	// unverified
+	Unverified int64 `json:"unverified,omitempty"`
+
+	// verified
+	Verified int64 `json:"verified,omitempty"`
+}
+
+// Validate validates this de web info verification
+func (m *DeWebInfoVerification) Validate(formats strfmt.Registry) error {
+	return nil
+}
+
+// ContextValidate validates this de web info verification based on context it is used
+func (m *DeWebInfoVerification) ContextValidate(ctx context.Context, formats strfmt.Registry) error {
+	return nil
+}
+
+// MarshalBinary interface implementation
+func (m *DeWebInfoVerification) MarshalBinary() ([]byte, error) {
+	if m == nil {
+		return nil, nil
+	}
+	return swag.WriteJSON(m)
+}
+
+// UnmarshalBinary interface implementation
+func (m *DeWebInfoVerification) UnmarshalBinary(b []byte) error {
+	var res DeWebInfoVerification
+	if err := swag.ReadJSON(b, &res); err != nil {
+		return err
+	}
+	*m = res
+	return nil
+}
@@ -109,6 +109,9 @@ func init() {
               "type": "integer",
               "format": "int64"
             },
+            "degraded": {
+              "type": "boolean"
+            },
             "network": {
               "type": "string"
             },
@@ -117,6 +120,26 @@ func init() {
             }
           }
         },
+        "verification": {
+          "type": "object",
+          "properties": {
+            "mismatches": {
+              "type": "integer",
+              "format": "int64"
+            },
+            "mode": {
+              "type": "string"
+            },
+            "unverified": {
+              "type": "integer",
+              "format": "int64"
+            },
+            "verified": {
+              "type": "integer",
+              "format": "int64"
+            }
+          }
+        },
         "version": {
           "type": "string"
         }
@@ -224,6 +247,9 @@ func init() {
               "type": "integer",
               "format": "int64"
             },
+            "degraded": {
+              "type": "boolean"
+            },
             "network": {
               "type": "string"
             },
@@ -232,6 +258,26 @@ func init() {
             }
           }
         },
+        "verification": {
+          "type": "object",
+          "properties": {
+            "mismatches": {
+              "type": "integer",
+              "format": "int64"
+            },
+            "mode": {
+              "type": "string"
+            },
+            "unverified": {
+              "type": "integer",
+              "format": "int64"
+            },
+            "verified": {
+              "type": "integer",
+              "format": "int64"
+            }
+          }
+        },
         "version": {
           "type": "string"
         }
@@ -244,6 +290,9 @@ func init() {
           "type": "integer",
           "format": "int64"
         },
+        "degraded": {
+          "type": "boolean"
+        },
         "network": {
           "type": "string"
         },
@@ -252,6 +301,26 @@ func init() {
         }
       }
     },
+    "DeWebInfoVerification": {
+      "type": "object",
+      "properties": {
+        "mismatches": {
+          "type": "integer",
+          "format": "int64"
+        },
+        "mode": {
+          "type": "string"
+        },
+        "unverified": {
+          "type": "integer",
+          "format": "int64"
+        },
+        "verified": {
+          "type": "integer",
+          "format": "int64"
+        }
+      }
+    },
     "Error": {
       "type": "object",
       "properties": {
@@ -4,13 +4,16 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/go-openapi/loads"
 	"github.com/massalabs/deweb-server/api/read/restapi"
 	"github.com/massalabs/deweb-server/api/read/restapi/operations"
 	"github.com/massalabs/deweb-server/int/api/config"
 	"github.com/massalabs/deweb-server/pkg/cache"
+	"github.com/massalabs/deweb-server/pkg/hostresolver"
 	mnscache "github.com/massalabs/deweb-server/pkg/mns/cache"
+	"github.com/massalabs/deweb-server/pkg/webmanager"
 	"github.com/massalabs/station/pkg/logger"
 )
 
@@ -25,11 +28,12 @@ const (
 )
 
 type API struct {
-	Conf      *config.ServerConfig
-	APIServer *restapi.Server
-	DewebAPI  *operations.DeWebAPI
-	Cache     *cache.Cache
-	MNSCache  *mnscache.MNSCache
+	Conf         *config.ServerConfig
+	APIServer    *restapi.Server
+	DewebAPI     *operations.DeWebAPI
+	Cache        *cache.Cache
+	MNSCache     *mnscache.MNSCache
+	HostResolver hostresolver.Resolver
 }
 
 func NewAPI(conf *config.ServerConfig) *API {
@@ -52,18 +56,35 @@ func NewAPI(conf *config.ServerConfig) *API {
 		}
 	}
 
+	webmanager.SetLastUpdateTTL(time.Duration(conf.CacheConfig.LastUpdateCacheDurationSeconds) * time.Second)
+	webmanager.SetMaxCachedFileSize(conf.CacheConfig.MaxFileSizeBytes)
+
 	var mnsCacheInstance *mnscache.MNSCache = nil
 	if conf.CacheConfig.Enabled {
 		mnsCacheInstance = mnscache.NewMNSCache(0, int(conf.CacheConfig.SiteRAMCacheMaxItems))
 	}
 
 	return &API{
-		Conf:      conf,
-		APIServer: server,
-		DewebAPI:  dewebAPI,
-		Cache:     cacheInstance,
-		MNSCache:  mnsCacheInstance,
+		Conf:         conf,
+		APIServer:    server,
+		DewebAPI:     dewebAPI,
+		Cache:        cacheInstance,
+		MNSCache:     mnsCacheInstance,
+		HostResolver: newHostResolver(conf.Domain, conf.CustomDomains),
+	}
+}
+
+// newHostResolver creates the resolver of custom domains: the static table is used first, then TXT records if enabled.
+// Hosts under domain, the server domain, are not looked up.
+func newHostResolver(domain string, conf config.CustomDomainsConfig) hostresolver.Resolver {
+	resolvers := hostresolver.Chain{hostresolver.StaticResolver(conf.Domains)}
+
+	if conf.DNSLookup {
+		ttl := time.Duration(conf.DNSCacheDurationSeconds) * time.Second
+		resolvers = append(resolvers, hostresolver.NewDNSResolver(nil, domain, ttl, hostresolver.DefaultDNSCacheSize))
 	}
+
+	return resolvers
 }
 
 // CacheMiddleware injects the cache instance into the request context
@@ -86,7 +107,7 @@ func (a *API) MNSCacheMiddleware(next http.Handler) http.Handler {
 
 // createHandler creates the base handler chain with common middleware
 func (a *API) createHandler() http.Handler {
-	return a.MNSCacheMiddleware(a.CacheMiddleware(SubdomainMiddleware(a.DewebAPI.Serve(nil), a.Conf)))
+	return a.MNSCacheMiddleware(a.CacheMiddleware(SubdomainMiddleware(PathMiddleware(a.DewebAPI.Serve(nil), a.Conf), a.Conf, a.HostResolver)))
 }
 
 // Start starts the API server.
@@ -101,6 +122,27 @@ func (a *API) Start() {
 		}
 	}()
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if a.Cache != nil && a.Conf.CacheConfig.UpdateWatcherEnabled && a.Conf.CacheConfig.UpdateWatcherIntervalSeconds > 0 {
+		watcher := webmanager.NewUpdateWatcher(
+			&a.Conf.NetworkInfos,
+			a.Cache,
+			time.Duration(a.Conf.CacheConfig.UpdateWatcherRetentionSeconds)*time.Second,
+		)
+
+		go watcher.Run(ctx, time.Duration(a.Conf.CacheConfig.UpdateWatcherIntervalSeconds)*time.Second)
+	}
+
+	if a.Conf.NetworkInfos.Nodes != nil && a.Conf.Nodes.HealthCheckIntervalSeconds > 0 {
+		go a.Conf.NetworkInfos.Nodes.Run(
+			ctx,
+			time.Duration(a.Conf.Nodes.HealthCheckIntervalSeconds)*time.Second,
+			time.Duration(a.Conf.Timeouts.NetworkCheckSeconds)*time.Second,
+		)
+	}
+
 	a.APIServer.Port = a.Conf.APIPort
 
 	a.configureAPI()
@@ -3,16 +3,30 @@ package api
 import (
 	"bytes"
 	_ "embed"
+	"errors"
 	"fmt"
-	"regexp"
-
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	userConfig "github.com/massalabs/deweb-server/int/api/config"
 	"github.com/massalabs/deweb-server/int/config"
 	pkgConfig "github.com/massalabs/deweb-server/pkg/config"
+	"github.com/massalabs/deweb-server/pkg/htmlrewriter"
+	"github.com/massalabs/deweb-server/pkg/webmanager"
 )
 
 const (
 	UnknownNetwork = "Unknown"
 	networkDocURL  = "https://docs.massa.net/docs/build/networks-faucets/public-networks"
+
+	boxTemplateFile   = "massaBox.html"
+	styleTemplateFile = "injectedStyle.css"
 )
 
 //go:embed resources/massa_logomark.svg
@@ -24,42 +38,175 @@ var injectedStyle []byte
 //go:embed resources/massaBox.html
 var massaBox []byte
 
-func InjectOnChainBox(content []byte, chainID uint64) []byte {
-	content = injectStyles(content)
-	content = injectHtmlBox(content, chainID)
+// BadgeData is the data available to the box template.
+type BadgeData struct {
+	Logo        template.HTML
+	ChainName   string
+	ChainDocURL string
+	Version     string
+	// Owner is the address of the website owner, it is empty if unknown.
+	Owner      string
+	ShortOwner string
+	// LastUpdate is the date of the last update of the website, it is empty if unknown.
+	LastUpdate string
+	// Minimal is true if the website requests the minimal box, without facts about the website.
+	Minimal bool
+}
 
-	return content
+// boxTemplates holds the box template and its style.
+type boxTemplates struct {
+	box   *template.Template
+	style []byte
 }
 
-// InjectStyles injects the DeWeb label style into the HTML content
-func injectStyles(content []byte) []byte {
-	styleHTML := fmt.Sprintf(`
-  		<!-- Injected DeWeb label style -->
-  		  <style type="text/css" >
-  		    %s
-  		  </style>
-  		<!-- Injected DeWeb label style -->`, injectedStyle)
+// loadedBoxTemplates holds the box templates, by template directory.
+var loadedBoxTemplates sync.Map
+
+// shouldInjectBox returns true if the box is enabled for the website.
+func shouldInjectBox(conf *userConfig.BadgeConfig, websiteAddress string) bool {
+	return conf.Enabled && (len(conf.Sites) == 0 || slices.Contains(conf.Sites, websiteAddress))
+}
+
+// NewBadgeData returns the box data of a website. The website facts are omitted if info is nil.
+func NewBadgeData(chainID uint64, info *webmanager.BadgeInfo) BadgeData {
+	data := BadgeData{
+		Logo:        template.HTML(massaLogomark), //nolint:gosec // The logo is embedded
+		ChainName:   getChainName(chainID),
+		ChainDocURL: getChainDocURL(chainID),
+		Version:     config.Version,
+	}
+
+	if info == nil {
+		return data
+	}
+
+	data.Owner = info.Owner
+	data.ShortOwner = shortAddress(info.Owner)
+	data.Minimal = info.Minimal
 
-	return bytes.Replace(content, []byte("</head>"), []byte(styleHTML), 1)
+	if !info.LastUpdate.IsZero() {
+		data.LastUpdate = info.LastUpdate.UTC().Format(time.DateOnly)
+	}
+
+	return data
 }
 
-// InjectHtmlBox injects a "Hosted by Massa" box into the HTML content
-func injectHtmlBox(content []byte, chainID uint64) []byte {
-	chainName := getChainName(chainID)
-	chainDocURL := getChainDocURL(chainID)
+// InjectOnChainBox injects the "Hosted by Massa" box and its style into an HTML page.
+// The Content Security Policy headers of the page are returned updated to allow the injected style and script.
+func InjectOnChainBox(
+	content []byte,
+	conf *userConfig.BadgeConfig,
+	data BadgeData,
+	httpHeaders map[string]string,
+) ([]byte, map[string]string, error) {
+	templates, err := loadBoxTemplates(conf.TemplateDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var box bytes.Buffer
+
+	if err := templates.box.Execute(&box, data); err != nil {
+		return nil, nil, fmt.Errorf("rendering box template: %w", err)
+	}
+
+	position := htmlrewriter.BodyStart
+	if conf.Position == userConfig.BadgePositionBottom {
+		position = htmlrewriter.BodyEnd
+	}
+
+	rewriter, err := htmlrewriter.New(
+		htmlrewriter.Injection{Position: htmlrewriter.HeadEnd, HTML: styleHTML(templates.style)},
+		htmlrewriter.Injection{Position: position, HTML: box.Bytes()},
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating page rewriter: %w", err)
+	}
 
-	boxHTML := fmt.Sprintf(string(massaBox), massaLogomark, chainDocURL, chainName, config.Version)
+	content, err = rewriter.Rewrite(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("rewriting page: %w", err)
+	}
 
-	bodyRegex := regexp.MustCompile(`(?i)<body[^>]*>`)
+	headers := make(map[string]string, len(httpHeaders))
 
-	loc := bodyRegex.FindIndex(content)
-	if loc == nil {
-		return content
+	for key, value := range httpHeaders {
+		if strings.EqualFold(key, "Content-Security-Policy") || strings.EqualFold(key, "Content-Security-Policy-Report-Only") {
+			value = rewriter.AllowInCSP(value)
+		}
+
+		headers[key] = value
 	}
 
-	result := append(content[:loc[1]], append([]byte(boxHTML), content[loc[1]:]...)...)
+	return content, headers, nil
+}
+
+// loadBoxTemplates returns the box templates of a template directory, or the embedded ones if dir is empty.
+// Templates are loaded once, so they must be changed before the server starts.
+func loadBoxTemplates(dir string) (*boxTemplates, error) {
+	if templates, ok := loadedBoxTemplates.Load(dir); ok {
+		return templates.(*boxTemplates), nil
+	}
+
+	boxSource, err := readTemplateFile(dir, boxTemplateFile, massaBox)
+	if err != nil {
+		return nil, err
+	}
+
+	style, err := readTemplateFile(dir, styleTemplateFile, injectedStyle)
+	if err != nil {
+		return nil, err
+	}
+
+	box, err := template.New(boxTemplateFile).Parse(string(boxSource))
+	if err != nil {
+		return nil, fmt.Errorf("parsing box template: %w", err)
+	}
+
+	templates := &boxTemplates{box: box, style: style}
+	loadedBoxTemplates.Store(dir, templates)
+
+	return templates, nil
+}
+
+// readTemplateFile reads a template file from the template directory, or returns the embedded one
+// if dir is empty or doesn't hold the file.
+func readTemplateFile(dir, name string, embedded []byte) ([]byte, error) {
+	if dir == "" {
+		return embedded, nil
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return embedded, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("reading template %s: %w", name, err)
+	}
+
+	return content, nil
+}
+
+// styleHTML returns the DeWeb label style element.
+func styleHTML(style []byte) []byte {
+	return []byte(fmt.Sprintf(`
+  		<!-- Injected DeWeb label style -->
+  		  <style type="text/css" >
+  		    %s
+  		  </style>
+  		<!-- Injected DeWeb label style -->`, style))
+}
+
+// shortAddress returns the start and the end of an address.
+func shortAddress(address string) string {
+	const keep = 6
+
+	if len(address) <= 2*keep {
+		return address
+	}
 
-	return result
+	return address[:keep] + "..." + address[len(address)-keep:]
 }
 
 // getChainName returns the name of the chain based on the chainID
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	userConfig "github.com/massalabs/deweb-server/int/api/config"
+	pkgConfig "github.com/massalabs/deweb-server/pkg/config"
+	"github.com/massalabs/deweb-server/pkg/webmanager"
+)
+
+func TestInjectOnChainBox(t *testing.T) {
+	page := "<html><head></head><body><p>content</p></body></html>"
+	owner := "AU12dG5xP1RDEB5ocdHkymNVvvSJmUL9BgHwCksDowqmGWxfpm93x"
+	info := &webmanager.BadgeInfo{Owner: owner, LastUpdate: time.Date(2024, 5, 17, 10, 0, 0, 0, time.UTC)}
+
+	templateDir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(templateDir, boxTemplateFile), []byte(`<div id="custom">{{.ChainName}}</div>`), 0o600)
+	if err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+
+	testCases := []struct {
+		name        string
+		conf        userConfig.BadgeConfig
+		info        *webmanager.BadgeInfo
+		contains    []string
+		notContains []string
+		boxAtEnd    bool
+	}{
+		{
+			name:     "Default box",
+			conf:     userConfig.DefaultBadgeConfig(),
+			info:     info,
+			contains: []string{`id="massaBox"`, "by AU12dG...fpm93x", "updated 2024-05-17", pkgConfig.MainnetName, ".massa-box"},
+		},
+		{
+			name:        "Unknown website facts",
+			conf:        userConfig.DefaultBadgeConfig(),
+			contains:    []string{`id="massaBox"`, pkgConfig.MainnetName},
+			notContains: []string{"massa-owner", "massa-last-update"},
+		},
+		{
+			name:        "Minimal box",
+			conf:        userConfig.DefaultBadgeConfig(),
+			info:        &webmanager.BadgeInfo{Owner: owner, Minimal: true},
+			contains:    []string{`id="massaBox"`, "hosted on chain"},
+			notContains: []string{"massa-owner", pkgConfig.MainnetName},
+		},
+		{
+			name:     "Bottom position",
+			conf:     userConfig.BadgeConfig{Enabled: true, Position: userConfig.BadgePositionBottom},
+			info:     info,
+			contains: []string{`id="massaBox"`},
+			boxAtEnd: true,
+		},
+		{
+			name:        "Custom template",
+			conf:        userConfig.BadgeConfig{Enabled: true, Position: userConfig.BadgePositionTop, TemplateDir: templateDir},
+			info:        info,
+			contains:    []string{`<div id="custom">` + pkgConfig.MainnetName + `</div>`, ".massa-box"},
+			notContains: []string{`id="massaBox"`},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := NewBadgeData(pkgConfig.MainnetChainID, tc.info)
+
+			result, _, err := InjectOnChainBox([]byte(page), &tc.conf, data, nil)
+			if err != nil {
+				t.Fatalf("Failed to inject box: %v", err)
+			}
+
+			for _, s := range tc.contains {
+				if !strings.Contains(string(result), s) {
+					t.Errorf("Expected page to contain %q, got:\n%s", s, result)
+				}
+			}
+
+			for _, s := range tc.notContains {
+				if strings.Contains(string(result), s) {
+					t.Errorf("Expected page not to contain %q, got:\n%s", s, result)
+				}
+			}
+
+			content := strings.Index(string(result), "<p>content</p>")
+			box := strings.Index(string(result), "</style>")
+
+			if box > content {
+				t.Errorf("Expected style to be injected in the head")
+			}
+
+			if tc.boxAtEnd != (strings.Index(string(result), `id="massaBox"`) > content) {
+				t.Errorf("Expected box at end %v, got:\n%s", tc.boxAtEnd, result)
+			}
+		})
+	}
+}
+
+func TestInjectOnChainBoxCSP(t *testing.T) {
+	page := "<html><head></head><body></body></html>"
+	headers := map[string]string{
+		"content-security-policy": "default-src 'self'",
+		"Cache-Control":           "no-cache",
+	}
+
+	conf := userConfig.DefaultBadgeConfig()
+
+	_, result, err := InjectOnChainBox([]byte(page), &conf, NewBadgeData(pkgConfig.MainnetChainID, nil), headers)
+	if err != nil {
+		t.Fatalf("Failed to inject box: %v", err)
+	}
+
+	policy := result["content-security-policy"]
+	if !strings.Contains(policy, "script-src 'self' 'sha256-") || !strings.Contains(policy, "style-src 'self' 'sha256-") {
+		t.Errorf("Expected policy to allow the box, got %q", policy)
+	}
+
+	if result["Cache-Control"] != "no-cache" {
+		t.Errorf("Expected other headers to be kept, got %v", result)
+	}
+
+	if headers["content-security-policy"] != "default-src 'self'" {
+		t.Errorf("Expected original headers to be left unchanged")
+	}
+}
+
+func TestShouldInjectBox(t *testing.T) {
+	testCases := []struct {
+		name     string
+		conf     userConfig.BadgeConfig
+		address  string
+		expected bool
+	}{
+		{"Enabled for all sites", userConfig.BadgeConfig{Enabled: true}, "AS1", true},
+		{"Disabled", userConfig.BadgeConfig{Enabled: false}, "AS1", false},
+		{"Listed site", userConfig.BadgeConfig{Enabled: true, Sites: []string{"AS1", "AS2"}}, "AS2", true},
+		{"Unlisted site", userConfig.BadgeConfig{Enabled: true, Sites: []string{"AS1"}}, "AS2", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := shouldInjectBox(&tc.conf, tc.address); result != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, result)
+			}
+		})
+	}
+}
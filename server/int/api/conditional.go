@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// isNotModified evaluates the If-None-Match and If-Modified-Since headers of a GET or HEAD request
+// against the given validators, as described in RFC 9110 section 13.2.2.
+// An empty etag or a zero lastModified means that the validator is unknown.
+func isNotModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	// If-Modified-Since must be ignored when If-None-Match is present
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return etag != "" && etagListMatches(ifNoneMatch, etag)
+	}
+
+	ifModifiedSince := r.Header.Get("If-Modified-Since")
+	if ifModifiedSince == "" || lastModified.IsZero() {
+		return false
+	}
+
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+
+	// HTTP dates have a one second resolution
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// etagListMatches returns true if the etag weakly matches one of the entity tags of the given list.
+func etagListMatches(list string, etag string) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeNotModified writes a 304 Not Modified response with the given validators.
+func writeNotModified(w http.ResponseWriter, etag string, lastModified time.Time) {
+	setValidatorHeaders(w, etag, lastModified)
+
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// setValidatorHeaders sets the ETag and Last-Modified headers if they are known.
+func setValidatorHeaders(w http.ResponseWriter, etag string, lastModified time.Time) {
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+}
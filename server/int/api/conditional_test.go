@@ -0,0 +1,118 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIsNotModified(t *testing.T) {
+	lastModified := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	etag := `"abc"`
+
+	testCases := []struct {
+		name         string
+		method       string
+		headers      map[string]string
+		etag         string
+		lastModified time.Time
+		expected     bool
+	}{
+		{
+			name:         "No conditional header",
+			method:       http.MethodGet,
+			etag:         etag,
+			lastModified: lastModified,
+			expected:     false,
+		},
+		{
+			name:         "Matching If-None-Match",
+			method:       http.MethodGet,
+			headers:      map[string]string{"If-None-Match": `"xyz", "abc"`},
+			etag:         etag,
+			lastModified: lastModified,
+			expected:     true,
+		},
+		{
+			name:         "Weak If-None-Match",
+			method:       http.MethodHead,
+			headers:      map[string]string{"If-None-Match": `W/"abc"`},
+			etag:         etag,
+			lastModified: lastModified,
+			expected:     true,
+		},
+		{
+			name:         "Wildcard If-None-Match",
+			method:       http.MethodGet,
+			headers:      map[string]string{"If-None-Match": "*"},
+			etag:         etag,
+			lastModified: lastModified,
+			expected:     true,
+		},
+		{
+			name:         "Unknown ETag",
+			method:       http.MethodGet,
+			headers:      map[string]string{"If-None-Match": `"abc"`},
+			etag:         "",
+			lastModified: lastModified,
+			expected:     false,
+		},
+		{
+			name:   "If-None-Match takes precedence over If-Modified-Since",
+			method: http.MethodGet,
+			headers: map[string]string{
+				"If-None-Match":     `"xyz"`,
+				"If-Modified-Since": lastModified.Format(http.TimeFormat),
+			},
+			etag:         etag,
+			lastModified: lastModified,
+			expected:     false,
+		},
+		{
+			name:         "Not modified since",
+			method:       http.MethodGet,
+			headers:      map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)},
+			etag:         etag,
+			lastModified: lastModified.Add(500 * time.Millisecond),
+			expected:     true,
+		},
+		{
+			name:         "Modified since",
+			method:       http.MethodGet,
+			headers:      map[string]string{"If-Modified-Since": lastModified.Add(-time.Hour).Format(http.TimeFormat)},
+			etag:         etag,
+			lastModified: lastModified,
+			expected:     false,
+		},
+		{
+			name:         "Unknown last modification",
+			method:       http.MethodGet,
+			headers:      map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)},
+			etag:         etag,
+			lastModified: time.Time{},
+			expected:     false,
+		},
+		{
+			name:         "Not a GET or HEAD request",
+			method:       http.MethodPost,
+			headers:      map[string]string{"If-None-Match": `"abc"`},
+			etag:         etag,
+			lastModified: lastModified,
+			expected:     false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, "/index.html", nil)
+			for key, value := range tc.headers {
+				r.Header.Set(key, value)
+			}
+
+			if result := isNotModified(r, tc.etag, tc.lastModified); result != tc.expected {
+				t.Errorf("Expected %t, but got %t", tc.expected, result)
+			}
+		})
+	}
+}
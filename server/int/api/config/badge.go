@@ -0,0 +1,76 @@
+package config
+
+import (
+	"github.com/massalabs/station/pkg/logger"
+)
+
+// Position of the badge in the pages.
+const (
+	// BadgePositionTop inserts the badge at the start of the page body.
+	BadgePositionTop = "top"
+	// BadgePositionBottom inserts the badge at the end of the page body.
+	BadgePositionBottom = "bottom"
+
+	DefaultBadgePosition = BadgePositionTop
+)
+
+// BadgeConfig configures the "Hosted by Massa" badge injected into the HTML pages of websites.
+type BadgeConfig struct {
+	Enabled bool
+	// TemplateDir is a directory holding massaBox.html and injectedStyle.css files replacing the embedded ones.
+	// A file missing from the directory falls back to the embedded one.
+	TemplateDir string
+	// Position is where the badge is inserted: BadgePositionTop or BadgePositionBottom.
+	Position string
+	// Sites limits the injection to the websites with these addresses. If empty, the badge is injected in all websites.
+	Sites []string
+}
+
+type YamlBadgeConfig struct {
+	Enabled     *bool    `yaml:"enabled"`
+	TemplateDir *string  `yaml:"template_dir"`
+	Position    *string  `yaml:"position"`
+	Sites       []string `yaml:"sites"`
+}
+
+// DefaultBadgeConfig returns a badge configuration with default values
+func DefaultBadgeConfig() BadgeConfig {
+	return BadgeConfig{
+		Enabled:     true,
+		TemplateDir: "",
+		Position:    DefaultBadgePosition,
+		Sites:       []string{},
+	}
+}
+
+// ProcessBadgeConfig processes YAML config into a ready-to-use BadgeConfig
+func ProcessBadgeConfig(yamlConf *YamlBadgeConfig) BadgeConfig {
+	config := DefaultBadgeConfig()
+
+	if yamlConf == nil {
+		return config
+	}
+
+	if yamlConf.Enabled != nil {
+		config.Enabled = *yamlConf.Enabled
+	}
+
+	if yamlConf.TemplateDir != nil {
+		config.TemplateDir = *yamlConf.TemplateDir
+	}
+
+	if yamlConf.Position != nil {
+		switch *yamlConf.Position {
+		case BadgePositionTop, BadgePositionBottom:
+			config.Position = *yamlConf.Position
+		default:
+			logger.Warnf("invalid badge position %q, using %q", *yamlConf.Position, DefaultBadgePosition)
+		}
+	}
+
+	if yamlConf.Sites != nil {
+		config.Sites = yamlConf.Sites
+	}
+
+	return config
+}
@@ -8,10 +8,14 @@ import (
 
 const (
 	// Default cache size limits
-	DefaultMaxRAMItems         uint64 = 1000               // Maximum RAM items, Default is 1000
-	DefaultMaxDiskItems        uint64 = 10000              // Maximum disk items, Default is 10000
-	DefaultFileListCachePeriod        = 60                 // Default expiration of the file list cache in seconds
-	DefaultDiskCacheDir               = "./websitesCache/" // Default cache directory
+	DefaultMaxRAMItems            uint64 = 1000               // Maximum RAM items, Default is 1000
+	DefaultMaxDiskItems           uint64 = 10000              // Maximum disk items, Default is 10000
+	DefaultFileListCachePeriod           = 60                 // Default expiration of an unconfirmed website index in seconds
+	DefaultLastUpdateCachePeriod         = 10                 // Default period between checks of a website last update in seconds
+	DefaultUpdateWatcherInterval         = 2                  // Default period between polls of the websites last update in seconds
+	DefaultUpdateWatcherRetention        = 600                // Default duration during which a served website is watched in seconds
+	DefaultDiskCacheDir                  = "./websitesCache/" // Default cache directory
+	DefaultMaxCachedFileSize      int64  = 10 << 20           // Default maximum size of a cached file in bytes
 )
 
 type CacheConfig struct {
@@ -20,24 +24,44 @@ type CacheConfig struct {
 	SiteDiskCacheMaxItems        uint64
 	DiskCacheDir                 string
 	FileListCacheDurationSeconds int
+	// LastUpdateCacheDurationSeconds is the period during which the last update of a website is not checked on the node.
+	// Once it has expired, cached resources are still served while the last update is checked in the background.
+	LastUpdateCacheDurationSeconds int
+	// UpdateWatcherEnabled enables polling the last update timestamps of recently served websites,
+	// to remove their cached resources as soon as they are modified.
+	UpdateWatcherEnabled          bool
+	UpdateWatcherIntervalSeconds  int
+	UpdateWatcherRetentionSeconds int
+	// MaxFileSizeBytes is the size above which files are streamed from the node without being cached,
+	// as they are held in memory until they are saved.
+	MaxFileSizeBytes int64
 }
 
 type YamlCacheConfig struct {
-	Enabled                      *bool   `yaml:"enabled"`
-	SiteRAMCacheMaxItems         *uint64 `yaml:"site_ram_cache_max_items"`
-	SiteDiskCacheMaxItems        *uint64 `yaml:"site_disk_cache_max_items"`
-	DiskCacheDir                 *string `yaml:"disk_cache_dir"`
-	FileListCacheDurationSeconds *int    `yaml:"file_list_cache_duration_seconds"`
+	Enabled                        *bool   `yaml:"enabled"`
+	SiteRAMCacheMaxItems           *uint64 `yaml:"site_ram_cache_max_items"`
+	SiteDiskCacheMaxItems          *uint64 `yaml:"site_disk_cache_max_items"`
+	DiskCacheDir                   *string `yaml:"disk_cache_dir"`
+	FileListCacheDurationSeconds   *int    `yaml:"file_list_cache_duration_seconds"`
+	LastUpdateCacheDurationSeconds *int    `yaml:"last_update_cache_duration_seconds"`
+	UpdateWatcherEnabled           *bool   `yaml:"update_watcher_enabled"`
+	UpdateWatcherIntervalSeconds   *int    `yaml:"update_watcher_interval_seconds"`
+	UpdateWatcherRetentionSeconds  *int    `yaml:"update_watcher_retention_seconds"`
+	MaxFileSizeBytes               *int64  `yaml:"max_file_size_bytes"`
 }
 
 // DefaultCacheConfig returns a cache configuration with default values
 func DefaultCacheConfig() CacheConfig {
 	return CacheConfig{
-		Enabled:                      true,
-		SiteRAMCacheMaxItems:         DefaultMaxRAMItems,
-		SiteDiskCacheMaxItems:        DefaultMaxDiskItems,
-		DiskCacheDir:                 DefaultDiskCacheDir,
-		FileListCacheDurationSeconds: DefaultFileListCachePeriod,
+		Enabled:                        true,
+		SiteRAMCacheMaxItems:           DefaultMaxRAMItems,
+		SiteDiskCacheMaxItems:          DefaultMaxDiskItems,
+		DiskCacheDir:                   DefaultDiskCacheDir,
+		FileListCacheDurationSeconds:   DefaultFileListCachePeriod,
+		LastUpdateCacheDurationSeconds: DefaultLastUpdateCachePeriod,
+		UpdateWatcherIntervalSeconds:   DefaultUpdateWatcherInterval,
+		UpdateWatcherRetentionSeconds:  DefaultUpdateWatcherRetention,
+		MaxFileSizeBytes:               DefaultMaxCachedFileSize,
 	}
 }
 
@@ -96,4 +120,24 @@ func applyYamlOverrides(config *CacheConfig, yamlConf *YamlCacheConfig) {
 	if yamlConf.FileListCacheDurationSeconds != nil {
 		config.FileListCacheDurationSeconds = *yamlConf.FileListCacheDurationSeconds
 	}
+
+	if yamlConf.LastUpdateCacheDurationSeconds != nil {
+		config.LastUpdateCacheDurationSeconds = *yamlConf.LastUpdateCacheDurationSeconds
+	}
+
+	if yamlConf.UpdateWatcherEnabled != nil {
+		config.UpdateWatcherEnabled = *yamlConf.UpdateWatcherEnabled
+	}
+
+	if yamlConf.UpdateWatcherIntervalSeconds != nil {
+		config.UpdateWatcherIntervalSeconds = *yamlConf.UpdateWatcherIntervalSeconds
+	}
+
+	if yamlConf.UpdateWatcherRetentionSeconds != nil {
+		config.UpdateWatcherRetentionSeconds = *yamlConf.UpdateWatcherRetentionSeconds
+	}
+
+	if yamlConf.MaxFileSizeBytes != nil {
+		config.MaxFileSizeBytes = *yamlConf.MaxFileSizeBytes
+	}
 }
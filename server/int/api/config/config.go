@@ -1,12 +1,15 @@
 package config
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/massalabs/deweb-server/int/utils"
 	pkgConfig "github.com/massalabs/deweb-server/pkg/config"
 	pkgErrors "github.com/massalabs/deweb-server/pkg/error"
+	"github.com/massalabs/deweb-server/pkg/headerrules"
 	"github.com/massalabs/station/pkg/logger"
 	yaml "gopkg.in/yaml.v2"
 )
@@ -17,6 +20,18 @@ const (
 	DefaultAPIPort        = 8080
 )
 
+// Trailing slash canonicalization of directory paths, for directories with an index.html page.
+const (
+	// TrailingSlashAdd redirects directory paths without trailing slash to the path with a trailing slash.
+	TrailingSlashAdd = "add"
+	// TrailingSlashRemove redirects directory paths with a trailing slash to the path without it.
+	TrailingSlashRemove = "remove"
+	// TrailingSlashIgnore serves the directory index on both paths.
+	TrailingSlashIgnore = "ignore"
+
+	DefaultTrailingSlash = TrailingSlashAdd
+)
+
 type ServerConfig struct {
 	Domain             string
 	APIPort            int
@@ -26,26 +41,53 @@ type ServerConfig struct {
 	MiscPublicInfoJson interface{}
 	CacheConfig        CacheConfig
 	AllowOffline       bool
+	// PathGateway enables serving websites under /_deweb/{name}/ on the server domain, alongside subdomains.
+	// Websites served this way share the same origin, so they are not isolated from each other by the browser.
+	PathGateway   bool
+	CustomDomains CustomDomainsConfig
+	// TrailingSlash is the canonical form of directory paths: TrailingSlashAdd, TrailingSlashRemove or TrailingSlashIgnore.
+	TrailingSlash string
+	Badge         BadgeConfig
+	HTTPHeaders   HTTPHeadersConfig
+	// HeaderRules add operator headers to the resources of websites, after the http headers policy is applied.
+	HeaderRules headerrules.Rules
+	// Nodes configures the fallback nodes of the network node.
+	Nodes        NodesConfig
+	Verification VerificationConfig
+	// Timeouts bounds the duration of each kind of node operation.
+	Timeouts TimeoutsConfig
 }
 
 type YamlServerConfig struct {
-	Domain             *string          `yaml:"domain,omitempty"`
-	NetworkNodeURL     *string          `yaml:"network_node_url,omitempty"`
-	APIPort            *int             `yaml:"api_port,omitempty"`
-	AllowList          []string         `yaml:"allow_list,omitempty"`
-	BlockList          []string         `yaml:"block_list,omitempty"`
-	MiscPublicInfoJson interface{}      `yaml:"misc_public_info,omitempty"`
-	CacheConfig        *YamlCacheConfig `yaml:"cache,omitempty"`
-	AllowOffline       bool             `yaml:"allow_offline,omitempty"`
+	Domain             *string                  `yaml:"domain,omitempty"`
+	NetworkNodeURL     *string                  `yaml:"network_node_url,omitempty"`
+	APIPort            *int                     `yaml:"api_port,omitempty"`
+	AllowList          []string                 `yaml:"allow_list,omitempty"`
+	BlockList          []string                 `yaml:"block_list,omitempty"`
+	MiscPublicInfoJson interface{}              `yaml:"misc_public_info,omitempty"`
+	CacheConfig        *YamlCacheConfig         `yaml:"cache,omitempty"`
+	AllowOffline       bool                     `yaml:"allow_offline,omitempty"`
+	PathGateway        bool                     `yaml:"path_gateway,omitempty"`
+	CustomDomains      *YamlCustomDomainsConfig `yaml:"custom_domains,omitempty"`
+	TrailingSlash      *string                  `yaml:"trailing_slash,omitempty"`
+	Badge              *YamlBadgeConfig         `yaml:"badge,omitempty"`
+	HTTPHeaders        *YamlHTTPHeadersConfig   `yaml:"http_headers,omitempty"`
+	HeaderRules        []YamlHeaderRule         `yaml:"header_rules,omitempty"`
+	Nodes              *YamlNodesConfig         `yaml:"nodes,omitempty"`
+	Verification       *YamlVerificationConfig  `yaml:"verification,omitempty"`
+	Timeouts           *YamlTimeoutsConfig      `yaml:"timeouts,omitempty"`
 }
 
 func DefaultConfig() (*ServerConfig, error) {
-	networkInfos, err := pkgConfig.NewNetworkConfig(DefaultNetworkNodeURL)
+	ctx, cancel := WithTimeout(context.Background(), DefaultNetworkCheckTimeout)
+	defer cancel()
+
+	networkInfos, err := pkgConfig.NewNetworkConfig(ctx, DefaultNetworkNodeURL)
 	if err != nil {
 		return nil, pkgErrors.NewServerError(fmt.Sprintf("unable to create network config: %v", err), pkgErrors.ErrNetworkConfigCode)
 	}
 
-	return &ServerConfig{
+	conf := &ServerConfig{
 		Domain:             DefaultDomain,
 		APIPort:            DefaultAPIPort,
 		NetworkInfos:       networkInfos,
@@ -54,7 +96,19 @@ func DefaultConfig() (*ServerConfig, error) {
 		MiscPublicInfoJson: map[string]interface{}{},
 		CacheConfig:        DefaultCacheConfig(),
 		AllowOffline:       false,
-	}, nil
+		PathGateway:        false,
+		CustomDomains:      DefaultCustomDomainsConfig(),
+		TrailingSlash:      DefaultTrailingSlash,
+		Badge:              DefaultBadgeConfig(),
+		HTTPHeaders:        DefaultHTTPHeadersConfig(),
+		HeaderRules:        headerrules.Rules{},
+		Nodes:              DefaultNodesConfig(),
+		Verification:       DefaultVerificationConfig(),
+		Timeouts:           DefaultTimeoutsConfig(),
+	}
+	conf.NetworkInfos.Reads = conf.ReadOptions()
+
+	return conf, nil
 }
 
 // LoadServerConfig loads the server configuration from the given path, or returns the default configuration
@@ -64,21 +118,41 @@ func LoadServerConfig(configPath string) (*ServerConfig, error) {
 		return nil, fmt.Errorf("failed to load server config: %w", err)
 	}
 
-	networkInfos, err := pkgConfig.NewNetworkConfig(Conf.NetworkInfos.NodeURL)
+	nodeURLs := append([]string{Conf.NetworkInfos.NodeURL}, Conf.Nodes.FallbackURLs...)
+
+	ctx, cancel := WithTimeout(context.Background(), Conf.Timeouts.NetworkCheckSeconds)
+	defer cancel()
+
+	// Offline, the pool is kept so that the nodes are used once its health checks reach them
+	networkInfos, err := pkgConfig.NewNetworkConfigWithOptions(ctx, Conf.Nodes.PoolOptions(), nodeURLs...)
 	if err != nil {
-		if Conf.AllowOffline {
+		if Conf.AllowOffline && networkInfos.Nodes != nil {
 			logger.Errorf("unable retrieve network config: %v", err)
-			logger.Warnf("using default values for minimal fees, chain ID, and network version")
+			logger.Warnf("starting offline, the network version is unknown until the nodes can be reached")
 		} else {
 			// return error and servrConfig with empty networkInfos
 			return nil, pkgErrors.NewServerError(fmt.Sprintf("unable to retrieve network config from node: %v", err), pkgErrors.ErrNetworkConfigCode)
 		}
 	}
+	networkInfos.Reads = Conf.ReadOptions()
 	Conf.NetworkInfos = networkInfos
 
 	return Conf, nil
 }
 
+// ReadOptions returns the options of the reads of website data from the nodes of the network.
+func (c *ServerConfig) ReadOptions() *pkgConfig.ReadOptions {
+	return &pkgConfig.ReadOptions{
+		LastUpdateTimeout: time.Duration(c.Timeouts.LastUpdateSeconds) * time.Second,
+		IndexTimeout:      time.Duration(c.Timeouts.IndexSeconds) * time.Second,
+		ReadTimeout:       time.Duration(c.Timeouts.ReadSeconds) * time.Second,
+		IndexMaxAge:       time.Duration(c.CacheConfig.FileListCacheDurationSeconds) * time.Second,
+		ChunkFetchWorkers: c.Nodes.ChunkFetchWorkers,
+		VerificationMode:  c.Verification.Mode,
+		VerificationNodes: c.Verification.Nodes,
+	}
+}
+
 /*
 	LoadConfigWhitoutNodeFetchedData loads the server configuration from the file at the given path only.
 
@@ -132,6 +206,16 @@ func LoadConfigWhitoutNodeFetchedData(configPath string) (*ServerConfig, error)
 	// Process cache configuration
 	cacheConfig := ProcessCacheConfig(yamlConf.CacheConfig, configPath)
 
+	nodes, err := ProcessNodesConfig(yamlConf.Nodes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process nodes config: %w", err)
+	}
+
+	verification, err := ProcessVerificationConfig(yamlConf.Verification)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process verification config: %w", err)
+	}
+
 	return &ServerConfig{
 		Domain:  domain,
 		APIPort: apiPort,
@@ -143,9 +227,33 @@ func LoadConfigWhitoutNodeFetchedData(configPath string) (*ServerConfig, error)
 		MiscPublicInfoJson: convertYamlMisc2Json(yamlConf.MiscPublicInfoJson),
 		CacheConfig:        cacheConfig,
 		AllowOffline:       yamlConf.AllowOffline,
+		PathGateway:        yamlConf.PathGateway,
+		CustomDomains:      ProcessCustomDomainsConfig(yamlConf.CustomDomains),
+		TrailingSlash:      processTrailingSlash(yamlConf.TrailingSlash),
+		Badge:              ProcessBadgeConfig(yamlConf.Badge),
+		HTTPHeaders:        ProcessHTTPHeadersConfig(yamlConf.HTTPHeaders),
+		HeaderRules:        ProcessHeaderRules(yamlConf.HeaderRules),
+		Nodes:              nodes,
+		Verification:       verification,
+		Timeouts:           ProcessTimeoutsConfig(yamlConf.Timeouts),
 	}, nil
 }
 
+// processTrailingSlash returns the configured trailing slash canonicalization, or the default one if it is not set or invalid.
+func processTrailingSlash(trailingSlash *string) string {
+	if trailingSlash == nil {
+		return DefaultTrailingSlash
+	}
+
+	switch *trailingSlash {
+	case TrailingSlashAdd, TrailingSlashRemove, TrailingSlashIgnore:
+		return *trailingSlash
+	default:
+		logger.Warnf("invalid trailing_slash value %q, using %q", *trailingSlash, DefaultTrailingSlash)
+		return DefaultTrailingSlash
+	}
+}
+
 /*
 convertYamlMisc2Json convert the config's "misc" json field from a
 map[interface{}]interface{} (as unmarshaled by yaml.Unmarshal function)
@@ -3,6 +3,8 @@ package config
 import (
 	"encoding/json"
 	"testing"
+
+	pkgConfig "github.com/massalabs/deweb-server/pkg/config"
 )
 
 func TestConvertYamlMisc2Json(t *testing.T) {
@@ -95,3 +97,89 @@ func TestConvertYamlMisc2Json(t *testing.T) {
 		})
 	}
 }
+
+func TestProcessVerificationConfig(t *testing.T) {
+	mode := func(m string) *string { return &m }
+	nodes := func(n int) *int { return &n }
+
+	testCases := []struct {
+		name     string
+		yamlConf *YamlVerificationConfig
+		expected VerificationConfig
+		isError  bool
+	}{
+		{"Not set", nil, DefaultVerificationConfig(), false},
+		{"Fail mode", &YamlVerificationConfig{Mode: mode(VerificationFail), Nodes: nodes(1)}, VerificationConfig{Mode: VerificationFail, Nodes: 1}, false},
+		{"Invalid mode", &YamlVerificationConfig{Mode: mode("fail ")}, VerificationConfig{}, true},
+		{"No verification node", &YamlVerificationConfig{Mode: mode(VerificationWarn), Nodes: nodes(0)}, VerificationConfig{}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conf, err := ProcessVerificationConfig(tc.yamlConf)
+			if (err != nil) != tc.isError {
+				t.Fatalf("Expected error %v, got %v", tc.isError, err)
+			}
+
+			if !tc.isError && conf != tc.expected {
+				t.Errorf("Expected %+v, got %+v", tc.expected, conf)
+			}
+		})
+	}
+}
+
+func TestProcessNodesConfig(t *testing.T) {
+	network := func(n string) *string { return &n }
+
+	testCases := []struct {
+		name     string
+		yamlConf *YamlNodesConfig
+		chainID  uint64
+		isError  bool
+	}{
+		{"Not set", nil, 0, false},
+		{"Network", &YamlNodesConfig{Network: network("buildnet")}, 77658366, false},
+		{"Fallback nodes", &YamlNodesConfig{Network: network("mainnet"), FallbackURLs: []string{"https://node2"}}, 77658377, false},
+		{"Fallback nodes without network", &YamlNodesConfig{FallbackURLs: []string{"https://node2"}}, 0, true},
+		{"Unknown network", &YamlNodesConfig{Network: network("testnet")}, 0, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conf, err := ProcessNodesConfig(tc.yamlConf)
+			if (err != nil) != tc.isError {
+				t.Fatalf("Expected error %v, got %v", tc.isError, err)
+			}
+
+			if !tc.isError && conf.PoolOptions().ChainID != tc.chainID {
+				t.Errorf("Expected chain ID %d, got %d", tc.chainID, conf.PoolOptions().ChainID)
+			}
+		})
+	}
+}
+
+func TestReadOptions(t *testing.T) {
+	conf := &ServerConfig{
+		CacheConfig:  DefaultCacheConfig(),
+		Nodes:        DefaultNodesConfig(),
+		Verification: DefaultVerificationConfig(),
+		Timeouts:     DefaultTimeoutsConfig(),
+	}
+
+	// The defaults of the server are the ones of a network without read options
+	if reads := conf.ReadOptions(); *reads != pkgConfig.DefaultReadOptions() {
+		t.Errorf("Expected default read options %+v, got %+v", pkgConfig.DefaultReadOptions(), *reads)
+	}
+
+	conf.Timeouts.ReadSeconds = 0
+	conf.Verification = VerificationConfig{Mode: VerificationFail, Nodes: 1}
+
+	reads := conf.ReadOptions()
+	if reads.ReadTimeout != 0 || reads.IndexTimeout != pkgConfig.DefaultIndexTimeout {
+		t.Errorf("Expected no read timeout and default index timeout, got %+v", *reads)
+	}
+
+	if reads.VerificationMode != VerificationFail || reads.VerificationNodes != 1 {
+		t.Errorf("Expected configured verification, got %+v", *reads)
+	}
+}
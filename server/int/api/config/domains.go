@@ -0,0 +1,57 @@
+package config
+
+import (
+	"strings"
+)
+
+// DefaultDNSCacheDurationSeconds is the default duration for which TXT record lookups of custom domains are cached.
+const DefaultDNSCacheDurationSeconds = 300
+
+// CustomDomainsConfig maps hosts outside of the server domain to the websites they serve.
+type CustomDomainsConfig struct {
+	// Domains maps hosts to the MNS name or address of a website.
+	Domains map[string]string
+	// DNSLookup enables mapping hosts with the TXT record of their _deweb subdomain.
+	DNSLookup bool
+	// DNSCacheDurationSeconds is the duration for which lookups are cached, they are not cached if it is not positive.
+	DNSCacheDurationSeconds int
+}
+
+type YamlCustomDomainsConfig struct {
+	Domains                 map[string]string `yaml:"domains"`
+	DNSLookup               *bool             `yaml:"dns_lookup"`
+	DNSCacheDurationSeconds *int              `yaml:"dns_cache_duration_seconds"`
+}
+
+// DefaultCustomDomainsConfig returns a custom domains configuration with default values
+func DefaultCustomDomainsConfig() CustomDomainsConfig {
+	return CustomDomainsConfig{
+		Domains:                 map[string]string{},
+		DNSLookup:               false,
+		DNSCacheDurationSeconds: DefaultDNSCacheDurationSeconds,
+	}
+}
+
+// ProcessCustomDomainsConfig processes YAML config into a ready-to-use CustomDomainsConfig
+func ProcessCustomDomainsConfig(yamlConf *YamlCustomDomainsConfig) CustomDomainsConfig {
+	config := DefaultCustomDomainsConfig()
+
+	if yamlConf == nil {
+		return config
+	}
+
+	// Hosts are case-insensitive
+	for host, website := range yamlConf.Domains {
+		config.Domains[strings.ToLower(host)] = website
+	}
+
+	if yamlConf.DNSLookup != nil {
+		config.DNSLookup = *yamlConf.DNSLookup
+	}
+
+	if yamlConf.DNSCacheDurationSeconds != nil {
+		config.DNSCacheDurationSeconds = *yamlConf.DNSCacheDurationSeconds
+	}
+
+	return config
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"github.com/massalabs/deweb-server/pkg/headerrules"
+	"github.com/massalabs/station/pkg/logger"
+)
+
+type YamlHeaderRule struct {
+	Site    string           `yaml:"site"`
+	Path    string           `yaml:"path"`
+	Headers []YamlRuleHeader `yaml:"headers"`
+}
+
+type YamlRuleHeader struct {
+	Name  string  `yaml:"name"`
+	Value string  `yaml:"value"`
+	Mode  *string `yaml:"mode"`
+}
+
+// ProcessHeaderRules processes YAML header rules into ready-to-use rules.
+// Headers default to the override mode. Invalid rules are skipped.
+func ProcessHeaderRules(yamlRules []YamlHeaderRule) headerrules.Rules {
+	rules := headerrules.Rules{}
+
+	for _, yamlRule := range yamlRules {
+		rule := headerrules.Rule{Site: yamlRule.Site, Path: yamlRule.Path}
+
+		for _, yamlHeader := range yamlRule.Headers {
+			mode := headerrules.ModeOverride
+			if yamlHeader.Mode != nil {
+				mode = headerrules.Mode(*yamlHeader.Mode)
+			}
+
+			rule.Headers = append(rule.Headers, headerrules.Header{Name: yamlHeader.Name, Value: yamlHeader.Value, Mode: mode})
+		}
+
+		if err := rule.Validate(); err != nil {
+			logger.Warnf("skipping header rule for site %q and path %q: %v", rule.Site, rule.Path, err)
+			continue
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
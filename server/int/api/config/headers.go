@@ -0,0 +1,51 @@
+package config
+
+import (
+	"github.com/massalabs/deweb-server/pkg/headerpolicy"
+)
+
+// HTTPHeadersConfig restricts the headers websites can set with their http-header metadata,
+// in addition to the built-in denylist of headerpolicy.
+type HTTPHeadersConfig struct {
+	// Allow lists the only headers websites can set. If empty, all headers not denied are allowed.
+	Allow []string
+	// Deny lists headers websites can't set.
+	Deny []string
+	// Policy is the policy built from Allow and Deny.
+	Policy *headerpolicy.Policy
+}
+
+type YamlHTTPHeadersConfig struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+// DefaultHTTPHeadersConfig returns an http headers configuration with default values
+func DefaultHTTPHeadersConfig() HTTPHeadersConfig {
+	return HTTPHeadersConfig{
+		Allow:  []string{},
+		Deny:   []string{},
+		Policy: headerpolicy.New(nil, nil),
+	}
+}
+
+// ProcessHTTPHeadersConfig processes YAML config into a ready-to-use HTTPHeadersConfig
+func ProcessHTTPHeadersConfig(yamlConf *YamlHTTPHeadersConfig) HTTPHeadersConfig {
+	config := DefaultHTTPHeadersConfig()
+
+	if yamlConf == nil {
+		return config
+	}
+
+	if yamlConf.Allow != nil {
+		config.Allow = yamlConf.Allow
+	}
+
+	if yamlConf.Deny != nil {
+		config.Deny = yamlConf.Deny
+	}
+
+	config.Policy = headerpolicy.New(config.Allow, config.Deny)
+
+	return config
+}
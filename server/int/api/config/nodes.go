@@ -0,0 +1,129 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	pkgConfig "github.com/massalabs/deweb-server/pkg/config"
+	"github.com/massalabs/deweb-server/pkg/nodepool"
+)
+
+// DefaultChunkFetchWorkers is the default number of chunk batches of a file fetched concurrently.
+const DefaultChunkFetchWorkers = pkgConfig.DefaultChunkFetchWorkers
+
+// NodesConfig configures the pool of nodes the server reads the websites from.
+type NodesConfig struct {
+	// Network is the name of the network the nodes must be on, its chain ID is checked when the nodes are.
+	// It may only be omitted without fallback node, the network node then defines the network.
+	Network string
+	// FallbackURLs are the nodes used in addition to the network node, they must be on the same network.
+	FallbackURLs               []string
+	HealthCheckIntervalSeconds int
+	// MaxRetries is the number of times a call is retried on another node when a node can't be reached.
+	MaxRetries     int
+	RetryBackoffMs int
+	// FailureThreshold is the number of consecutive failures after which a node is skipped for OpenDurationSeconds.
+	FailureThreshold    int
+	OpenDurationSeconds int
+	// MaxRequestsPerSecond is the maximum number of calls per second to each node, 0 means unlimited.
+	MaxRequestsPerSecond int
+	// ChunkFetchWorkers is the maximum number of chunk batches of a file fetched concurrently.
+	ChunkFetchWorkers int
+}
+
+type YamlNodesConfig struct {
+	Network                    *string  `yaml:"network"`
+	FallbackURLs               []string `yaml:"fallback_urls"`
+	HealthCheckIntervalSeconds *int     `yaml:"health_check_interval_seconds"`
+	MaxRetries                 *int     `yaml:"max_retries"`
+	RetryBackoffMs             *int     `yaml:"retry_backoff_ms"`
+	FailureThreshold           *int     `yaml:"failure_threshold"`
+	OpenDurationSeconds        *int     `yaml:"open_duration_seconds"`
+	MaxRequestsPerSecond       *int     `yaml:"max_requests_per_second"`
+	ChunkFetchWorkers          *int     `yaml:"chunk_fetch_workers"`
+}
+
+// DefaultNodesConfig returns a nodes configuration with default values
+func DefaultNodesConfig() NodesConfig {
+	return NodesConfig{
+		FallbackURLs:               []string{},
+		HealthCheckIntervalSeconds: int(nodepool.DefaultHealthCheckInterval / time.Second),
+		MaxRetries:                 nodepool.DefaultMaxRetries,
+		RetryBackoffMs:             int(nodepool.DefaultRetryBackoff / time.Millisecond),
+		FailureThreshold:           nodepool.DefaultFailureThreshold,
+		OpenDurationSeconds:        int(nodepool.DefaultOpenDuration / time.Second),
+		ChunkFetchWorkers:          DefaultChunkFetchWorkers,
+	}
+}
+
+// ProcessNodesConfig processes YAML config into a ready-to-use NodesConfig.
+// Fallback nodes require the network to be set, so that a fallback node on another network is not trusted
+// when it is the first one to answer.
+func ProcessNodesConfig(yamlConf *YamlNodesConfig) (NodesConfig, error) {
+	config := DefaultNodesConfig()
+
+	if yamlConf == nil {
+		return config, nil
+	}
+
+	if yamlConf.Network != nil {
+		if _, known := pkgConfig.NetworkChainID(*yamlConf.Network); !known {
+			return config, fmt.Errorf("unknown network %q, expected %q or %q", *yamlConf.Network, pkgConfig.MainnetName, pkgConfig.BuildnetName)
+		}
+
+		config.Network = *yamlConf.Network
+	}
+
+	if yamlConf.FallbackURLs != nil {
+		config.FallbackURLs = yamlConf.FallbackURLs
+	}
+
+	if yamlConf.HealthCheckIntervalSeconds != nil {
+		config.HealthCheckIntervalSeconds = *yamlConf.HealthCheckIntervalSeconds
+	}
+
+	if yamlConf.MaxRetries != nil {
+		config.MaxRetries = *yamlConf.MaxRetries
+	}
+
+	if yamlConf.RetryBackoffMs != nil {
+		config.RetryBackoffMs = *yamlConf.RetryBackoffMs
+	}
+
+	if yamlConf.FailureThreshold != nil {
+		config.FailureThreshold = *yamlConf.FailureThreshold
+	}
+
+	if yamlConf.OpenDurationSeconds != nil {
+		config.OpenDurationSeconds = *yamlConf.OpenDurationSeconds
+	}
+
+	if yamlConf.MaxRequestsPerSecond != nil {
+		config.MaxRequestsPerSecond = *yamlConf.MaxRequestsPerSecond
+	}
+
+	if yamlConf.ChunkFetchWorkers != nil {
+		config.ChunkFetchWorkers = *yamlConf.ChunkFetchWorkers
+	}
+
+	if config.Network == "" && len(config.FallbackURLs) > 0 {
+		return config, errors.New("the network of the nodes must be set to use fallback nodes")
+	}
+
+	return config, nil
+}
+
+// PoolOptions returns the retries, circuit breaker, rate limit and expected chain ID options of the node pool.
+func (c NodesConfig) PoolOptions() nodepool.Options {
+	chainID, _ := pkgConfig.NetworkChainID(c.Network)
+
+	return nodepool.Options{
+		MaxRetries:       c.MaxRetries,
+		RetryBackoff:     time.Duration(c.RetryBackoffMs) * time.Millisecond,
+		FailureThreshold: c.FailureThreshold,
+		OpenDuration:     time.Duration(c.OpenDurationSeconds) * time.Second,
+		RateLimit:        c.MaxRequestsPerSecond,
+		ChainID:          chainID,
+	}
+}
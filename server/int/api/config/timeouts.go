@@ -0,0 +1,89 @@
+package config
+
+import (
+	"context"
+	"time"
+
+	pkgConfig "github.com/massalabs/deweb-server/pkg/config"
+)
+
+// Default timeouts of the node operations, in seconds.
+const (
+	DefaultNetworkCheckTimeout = 10
+	DefaultResolveTimeout      = 5
+	DefaultLastUpdateTimeout   = int(pkgConfig.DefaultLastUpdateTimeout / time.Second)
+	DefaultIndexTimeout        = int(pkgConfig.DefaultIndexTimeout / time.Second)
+	DefaultReadTimeout         = int(pkgConfig.DefaultReadTimeout / time.Second)
+)
+
+// TimeoutsConfig configures how long each kind of node operation may take before it is given up, in seconds.
+// Operations shared by several requests, such as building the index of a website, are only bounded by their timeout,
+// while the others are also given up when the client disconnects. 0 means no timeout.
+type TimeoutsConfig struct {
+	// NetworkCheckSeconds bounds the checks of the nodes, at startup and by the health checks.
+	NetworkCheckSeconds int
+	// ResolveSeconds bounds the resolution of a MNS domain, and the TXT record lookup of a custom domain.
+	ResolveSeconds int
+	// LastUpdateSeconds bounds the read of the last update timestamp of a website.
+	LastUpdateSeconds int
+	// IndexSeconds bounds the build of the index of a website: its files, chunk counts, metadata and owner.
+	IndexSeconds int
+	// ReadSeconds bounds each read of file chunks or file metadata.
+	ReadSeconds int
+}
+
+type YamlTimeoutsConfig struct {
+	NetworkCheckSeconds *int `yaml:"network_check_seconds"`
+	ResolveSeconds      *int `yaml:"resolve_seconds"`
+	LastUpdateSeconds   *int `yaml:"last_update_seconds"`
+	IndexSeconds        *int `yaml:"index_seconds"`
+	ReadSeconds         *int `yaml:"read_seconds"`
+}
+
+// DefaultTimeoutsConfig returns a timeouts configuration with default values
+func DefaultTimeoutsConfig() TimeoutsConfig {
+	return TimeoutsConfig{
+		NetworkCheckSeconds: DefaultNetworkCheckTimeout,
+		ResolveSeconds:      DefaultResolveTimeout,
+		LastUpdateSeconds:   DefaultLastUpdateTimeout,
+		IndexSeconds:        DefaultIndexTimeout,
+		ReadSeconds:         DefaultReadTimeout,
+	}
+}
+
+// ProcessTimeoutsConfig processes YAML config into a ready-to-use TimeoutsConfig
+func ProcessTimeoutsConfig(yamlConf *YamlTimeoutsConfig) TimeoutsConfig {
+	config := DefaultTimeoutsConfig()
+
+	if yamlConf == nil {
+		return config
+	}
+
+	if yamlConf.NetworkCheckSeconds != nil {
+		config.NetworkCheckSeconds = *yamlConf.NetworkCheckSeconds
+	}
+
+	if yamlConf.ResolveSeconds != nil {
+		config.ResolveSeconds = *yamlConf.ResolveSeconds
+	}
+
+	if yamlConf.LastUpdateSeconds != nil {
+		config.LastUpdateSeconds = *yamlConf.LastUpdateSeconds
+	}
+
+	if yamlConf.IndexSeconds != nil {
+		config.IndexSeconds = *yamlConf.IndexSeconds
+	}
+
+	if yamlConf.ReadSeconds != nil {
+		config.ReadSeconds = *yamlConf.ReadSeconds
+	}
+
+	return config
+}
+
+// WithTimeout returns a context done after the given number of seconds or when ctx is done.
+// If seconds is 0, it is only done when ctx is done.
+func WithTimeout(ctx context.Context, seconds int) (context.Context, context.CancelFunc) {
+	return pkgConfig.WithTimeout(ctx, time.Duration(seconds)*time.Second)
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+
+	pkgConfig "github.com/massalabs/deweb-server/pkg/config"
+)
+
+// Verification modes of the website data read from the node, see pkgConfig.ReadOptions.
+const (
+	VerificationOff  = pkgConfig.VerificationOff
+	VerificationWarn = pkgConfig.VerificationWarn
+	VerificationFail = pkgConfig.VerificationFail
+
+	DefaultVerificationNodes = pkgConfig.DefaultVerificationNodes
+)
+
+// VerificationConfig configures the verification of website chunks and http headers against several nodes,
+// to detect a compromised or lagging node.
+type VerificationConfig struct {
+	// Mode is VerificationOff, VerificationWarn or VerificationFail.
+	Mode string
+	// Nodes is the number of other nodes of the pool than the one the data was read from
+	// which must return it identically for it to be verified.
+	Nodes int
+}
+
+type YamlVerificationConfig struct {
+	Mode  *string `yaml:"mode"`
+	Nodes *int    `yaml:"nodes"`
+}
+
+// DefaultVerificationConfig returns a verification configuration with default values
+func DefaultVerificationConfig() VerificationConfig {
+	return VerificationConfig{
+		Mode:  VerificationOff,
+		Nodes: DefaultVerificationNodes,
+	}
+}
+
+// ProcessVerificationConfig processes YAML config into a ready-to-use VerificationConfig.
+// An unknown mode is an error rather than a fallback, as it could silently disable the verification.
+func ProcessVerificationConfig(yamlConf *YamlVerificationConfig) (VerificationConfig, error) {
+	config := DefaultVerificationConfig()
+
+	if yamlConf == nil {
+		return config, nil
+	}
+
+	if yamlConf.Mode != nil {
+		switch *yamlConf.Mode {
+		case VerificationOff, VerificationWarn, VerificationFail:
+			config.Mode = *yamlConf.Mode
+		default:
+			return config, fmt.Errorf("invalid verification mode %q, expected %q, %q or %q",
+				*yamlConf.Mode, VerificationOff, VerificationWarn, VerificationFail)
+		}
+	}
+
+	if yamlConf.Nodes != nil {
+		if *yamlConf.Nodes < 1 {
+			return config, fmt.Errorf("invalid number of verification nodes %d, expected at least 1", *yamlConf.Nodes)
+		}
+
+		config.Nodes = *yamlConf.Nodes
+	}
+
+	return config, nil
+}
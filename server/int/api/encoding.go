@@ -0,0 +1,109 @@
+package api
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/massalabs/deweb-server/pkg/cache"
+	"github.com/massalabs/deweb-server/pkg/compression"
+	"github.com/massalabs/deweb-server/pkg/webmanager"
+	"github.com/massalabs/station/pkg/logger"
+)
+
+// declaredEncoding returns the Content-Encoding declared in the on-chain http headers of a resource,
+// which means that it was uploaded compressed.
+func declaredEncoding(httpHeaders map[string]string) string {
+	for key, value := range httpHeaders {
+		if strings.EqualFold(key, "Content-Encoding") {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// acceptsEncoding returns true if the resource may be compressed for this request, based on its extension.
+// Such resources are not streamed, as their whole content is needed to compress them.
+func acceptsEncoding(r *http.Request, resourceName string) bool {
+	return compression.IsCompressible(mime.TypeByExtension(filepath.Ext(resourceName))) &&
+		compression.Negotiate(r.Header.Get("Accept-Encoding")) != ""
+}
+
+// serveMaybeEncoded serves the content of a resource, compressed with the encoding negotiated with the client
+// if its content type benefits from it. Resources uploaded compressed are served unchanged.
+// Compressed responses have a weak ETag, as they are semantically equivalent to the uncompressed resource
+// but not byte-for-byte identical. Range requests are not supported on compressed responses, which are
+// written without http.ServeContent so that they don't advertise byte ranges.
+func serveMaybeEncoded(
+	w http.ResponseWriter,
+	r *http.Request,
+	address, resourceName, contentType string,
+	content []byte,
+	info *webmanager.ResourceInfo,
+	cache *cache.Cache,
+) {
+	if setVaryEncoding(w, contentType, info.HttpHeaders) {
+		if encoding := compression.Negotiate(r.Header.Get("Accept-Encoding")); encoding != "" && len(content) >= compression.MinSize {
+			encoded, err := webmanager.EncodeResource(address, resourceName, encoding, content, info.LastModified, cache)
+			if err == nil {
+				writeEncoded(w, r, encoding, encoded, weakETag(info.ETag), info.LastModified)
+
+				return
+			}
+
+			logger.Warnf("Failed to encode website %s resource %s, sending it uncompressed: %v", address, resourceName, err)
+		}
+	}
+
+	setValidatorHeaders(w, info.ETag, info.LastModified)
+
+	http.ServeContent(w, r, resourceName, info.LastModified, bytes.NewReader(content))
+}
+
+// writeEncoded writes a compressed response, or a 304 Not Modified response if the client has it already.
+func writeEncoded(w http.ResponseWriter, r *http.Request, encoding string, encoded []byte, etag string, lastModified time.Time) {
+	if isNotModified(r, etag, lastModified) {
+		writeNotModified(w, etag, lastModified)
+
+		return
+	}
+
+	setValidatorHeaders(w, etag, lastModified)
+	w.Header().Set("Content-Encoding", encoding)
+	w.Header().Set("Content-Length", strconv.Itoa(len(encoded)))
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	if _, err := w.Write(encoded); err != nil {
+		logger.Errorf("Failed to write content: %v", err)
+	}
+}
+
+// setVaryEncoding adds Accept-Encoding to the Vary header if the resource may be served compressed,
+// which depends on the encodings accepted by the client. It returns true if the header was added.
+func setVaryEncoding(w http.ResponseWriter, contentType string, httpHeaders map[string]string) bool {
+	if declaredEncoding(httpHeaders) != "" || !compression.IsCompressible(contentType) {
+		return false
+	}
+
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	return true
+}
+
+// weakETag returns the weak version of an ETag.
+func weakETag(etag string) string {
+	if etag == "" || strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+
+	return "W/" + etag
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/massalabs/deweb-server/pkg/webmanager"
+)
+
+func TestServeMaybeEncoded(t *testing.T) {
+	content := bytes.Repeat([]byte("body { color: black; }\n"), 100)
+	etag := `"abc"`
+
+	testCases := []struct {
+		name             string
+		contentType      string
+		content          []byte
+		acceptEncoding   string
+		httpHeaders      map[string]string
+		expectedEncoding string
+		expectedVary     bool
+		expectedETag     string
+	}{
+		{"Gzip", "text/css", content, "gzip", nil, "gzip", true, `W/"abc"`},
+		{"Brotli preferred", "text/css", content, "gzip, br", nil, "br", true, `W/"abc"`},
+		{"No accepted encoding", "text/css", content, "", nil, "", true, etag},
+		{"Small content", "text/css", content[:100], "gzip", nil, "", true, etag},
+		{"Not compressible", "image/png", content, "gzip", nil, "", false, etag},
+		{"Uploaded compressed", "text/css", content, "br", map[string]string{"Content-Encoding": "gzip"}, "", false, etag},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/style.css", nil)
+			r.Header.Set("Accept-Encoding", tc.acceptEncoding)
+
+			w := httptest.NewRecorder()
+			info := &webmanager.ResourceInfo{HttpHeaders: tc.httpHeaders, ETag: etag, LastModified: time.Now()}
+
+			serveMaybeEncoded(w, r, "AS1", "style.css", tc.contentType, tc.content, info, nil)
+
+			if encoding := w.Header().Get("Content-Encoding"); encoding != tc.expectedEncoding {
+				t.Errorf("Expected Content-Encoding %q, but got %q", tc.expectedEncoding, encoding)
+			}
+
+			if vary := w.Header().Get("Vary") == "Accept-Encoding"; vary != tc.expectedVary {
+				t.Errorf("Expected Vary header %v, but got %q", tc.expectedVary, w.Header().Get("Vary"))
+			}
+
+			if w.Header().Get("ETag") != tc.expectedETag {
+				t.Errorf("Expected ETag %s, but got %s", tc.expectedETag, w.Header().Get("ETag"))
+			}
+
+			// Range requests are only supported on uncompressed responses
+			if acceptRanges := w.Header().Get("Accept-Ranges") == "bytes"; acceptRanges != (tc.expectedEncoding == "") {
+				t.Errorf("Unexpected Accept-Ranges %q with Content-Encoding %q", w.Header().Get("Accept-Ranges"), tc.expectedEncoding)
+			}
+
+			if tc.expectedEncoding == "gzip" {
+				reader, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatalf("Failed to read gzip body: %v", err)
+				}
+
+				body, err := io.ReadAll(reader)
+				if err != nil || !bytes.Equal(body, tc.content) {
+					t.Errorf("Decompressed body mismatch: %v", err)
+				}
+			} else if tc.expectedEncoding == "" && !bytes.Equal(w.Body.Bytes(), tc.content) {
+				t.Errorf("Expected body to be sent unchanged")
+			}
+		})
+	}
+}
+
+func TestServeMaybeEncodedRange(t *testing.T) {
+	content := bytes.Repeat([]byte("body { color: black; }\n"), 100)
+
+	r := httptest.NewRequest(http.MethodGet, "/style.css", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	r.Header.Set("Range", "bytes=0-9")
+
+	w := httptest.NewRecorder()
+	info := &webmanager.ResourceInfo{ETag: `"abc"`, LastModified: time.Now()}
+
+	serveMaybeEncoded(w, r, "AS1", "style.css", "text/css", content, info, nil)
+
+	// Range requests are not supported on compressed responses
+	if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("Expected a full compressed response, got status %d and encoding %q", w.Code, w.Header().Get("Content-Encoding"))
+	}
+
+	if r.Header.Get("Range") != "bytes=0-9" {
+		t.Errorf("Expected the request Range header to be kept, got %q", r.Header.Get("Range"))
+	}
+}
+
+func TestServeMaybeEncodedNotModified(t *testing.T) {
+	content := bytes.Repeat([]byte("body { color: black; }\n"), 100)
+	info := &webmanager.ResourceInfo{ETag: `"abc"`, LastModified: time.Now()}
+
+	for _, acceptEncoding := range []string{"gzip", ""} {
+		t.Run("Accept-Encoding "+acceptEncoding, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/style.css", nil)
+			r.Header.Set("Accept-Encoding", acceptEncoding)
+
+			w := httptest.NewRecorder()
+			serveMaybeEncoded(w, r, "AS1", "style.css", "text/css", content, info, nil)
+
+			etag := w.Header().Get("ETag")
+
+			// The 304 response has the validator sent with the full response
+			r.Header.Set("If-None-Match", etag)
+
+			w = httptest.NewRecorder()
+			serveMaybeEncoded(w, r, "AS1", "style.css", "text/css", content, info, nil)
+
+			if w.Code != http.StatusNotModified {
+				t.Errorf("Expected status %d, got %d", http.StatusNotModified, w.Code)
+			}
+
+			if w.Header().Get("ETag") != etag {
+				t.Errorf("Expected ETag %s, got %s", etag, w.Header().Get("ETag"))
+			}
+		})
+	}
+}
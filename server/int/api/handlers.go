@@ -10,6 +10,8 @@ import (
 	"github.com/massalabs/deweb-server/api/read/restapi/operations"
 	userConfig "github.com/massalabs/deweb-server/int/api/config"
 	config "github.com/massalabs/deweb-server/int/config"
+	"github.com/massalabs/deweb-server/pkg/webmanager"
+	"github.com/massalabs/deweb-server/pkg/website"
 	"github.com/massalabs/station/pkg/logger"
 )
 
@@ -19,7 +21,7 @@ var homeZip []byte
 // As websites are catched by the subdomain middleware, this handler is only called for the landing page resources.
 func getResourceHandler(params operations.GetResourceParams) middleware.Responder {
 	return middleware.ResponderFunc(func(w http.ResponseWriter, _ runtime.Producer) {
-		localHandler(w, homeZip, params.Resource)
+		localHandler(w, params.HTTPRequest, homeZip, params.Resource, http.StatusOK)
 	})
 }
 
@@ -54,10 +56,25 @@ func (dI *dewebInfo) Handle(params operations.GetDeWebInfoParams) middleware.Res
 			Network: &models.DeWebInfoNetwork{
 				Network: dI.conf.NetworkInfos.Name,
 				Version: dI.conf.NetworkInfos.Version,
-				ChainID: int64(dI.conf.NetworkInfos.ChainID),
+				ChainID: int64(dI.conf.NetworkInfos.CurrentChainID()),
+				// Degraded is true if the node can't be reached and cached websites are served without checking for updates
+				Degraded: webmanager.IsDegraded(),
 			},
-			AllowList: dI.conf.AllowList,
-			BlockList: dI.conf.BlockList,
+			AllowList:    dI.conf.AllowList,
+			BlockList:    dI.conf.BlockList,
+			Verification: newVerificationInfo(dI.conf.Verification),
 		}).WriteResponse(w, runtime)
 	})
 }
+
+// newVerificationInfo returns the verification mode and the counters of the reads verified against several nodes.
+func newVerificationInfo(conf userConfig.VerificationConfig) *models.DeWebInfoVerification {
+	stats := website.GetVerificationStats()
+
+	return &models.DeWebInfoVerification{
+		Mode:       conf.Mode,
+		Verified:   int64(stats.Verified),
+		Mismatches: int64(stats.Mismatches),
+		Unverified: int64(stats.Unverified),
+	}
+}
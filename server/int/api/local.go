@@ -7,7 +7,12 @@ import (
 	"github.com/massalabs/station/pkg/logger"
 )
 
-func localHandler(w http.ResponseWriter, zipBytes []byte, resourceName string) {
+// localHandler serves a resource from an embedded zip with the given status code.
+// Resources missing from the zip are answered with its index.html page.
+// The assets of the page are only served with a 200 status when the browser loads them as subresources,
+// so that it renders them, as a path of a missing website may match an asset of the zip.
+// Error responses must not be cached as the resource they were requested for.
+func localHandler(w http.ResponseWriter, r *http.Request, zipBytes []byte, resourceName string, statusCode int) {
 	if resourceName == "" {
 		logger.Debugf("localHandler: No resource specified, using index.html")
 		resourceName = "index.html"
@@ -16,7 +21,6 @@ func localHandler(w http.ResponseWriter, zipBytes []byte, resourceName string) {
 	isPresent, err := zipper.VerifyFilePresence(zipBytes, resourceName)
 	if err != nil && !zipper.IsNotFoundError(err, resourceName) {
 		logger.Errorf("localHandler: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
 	}
 
 	// If requested resource is not present, it might be the original requested resource.
@@ -38,9 +42,25 @@ func localHandler(w http.ResponseWriter, zipBytes []byte, resourceName string) {
 
 	w.Header().Set("Content-Type", contentType)
 
-	w.WriteHeader(http.StatusOK)
+	if statusCode != http.StatusOK {
+		w.Header().Set("Cache-Control", "no-store")
+
+		if resourceName != "index.html" && isSubresourceRequest(r) {
+			statusCode = http.StatusOK
+		}
+	}
+
+	w.WriteHeader(statusCode)
 
 	if _, err := w.Write(content); err != nil {
 		logger.Errorf("localHandler: %v", err)
 	}
 }
+
+// isSubresourceRequest returns true if the browser requested a resource to be loaded by a page, like a script,
+// a style sheet or an image, rather than to be displayed.
+func isSubresourceRequest(r *http.Request) bool {
+	dest := r.Header.Get("Sec-Fetch-Dest")
+
+	return dest != "" && dest != "document" && dest != "iframe" && dest != "frame" && dest != "empty"
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestZip returns a zip holding the given files, indexed by name.
+func newTestZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	writer := zip.NewWriter(&buf)
+
+	for name, content := range files {
+		file, err := writer.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to create %s in zip: %v", name, err)
+		}
+
+		if _, err := file.Write([]byte(content)); err != nil {
+			t.Fatalf("Failed to write %s in zip: %v", name, err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close zip: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestLocalHandler(t *testing.T) {
+	zipBytes := newTestZip(t, map[string]string{
+		"index.html": "<html><body>Not found</body></html>",
+		"style.css":  "body { color: red; }",
+	})
+
+	testCases := []struct {
+		name         string
+		resourceName string
+		fetchDest    string
+		statusCode   int
+		expected     int
+		expectedBody string
+		cacheable    bool
+	}{
+		{"Page", "", "document", http.StatusNotFound, http.StatusNotFound, "<html><body>Not found</body></html>", false},
+		{"Missing resource", "missing.js", "script", http.StatusNotFound, http.StatusNotFound, "<html><body>Not found</body></html>", false},
+		{"Asset loaded by the page", "style.css", "style", http.StatusNotFound, http.StatusOK, "body { color: red; }", false},
+		{"Asset requested directly", "style.css", "", http.StatusNotFound, http.StatusNotFound, "body { color: red; }", false},
+		{"Asset navigated to", "style.css", "document", http.StatusServiceUnavailable, http.StatusServiceUnavailable, "body { color: red; }", false},
+		{"Blocked website", "style.css", "", http.StatusForbidden, http.StatusForbidden, "body { color: red; }", false},
+		{"Landing page asset", "style.css", "", http.StatusOK, http.StatusOK, "body { color: red; }", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/"+tc.resourceName, nil)
+			if tc.fetchDest != "" {
+				r.Header.Set("Sec-Fetch-Dest", tc.fetchDest)
+			}
+
+			w := httptest.NewRecorder()
+			localHandler(w, r, zipBytes, tc.resourceName, tc.statusCode)
+
+			if w.Code != tc.expected {
+				t.Errorf("Expected status %d, got %d", tc.expected, w.Code)
+			}
+
+			if w.Body.String() != tc.expectedBody {
+				t.Errorf("Expected body %q, got %q", tc.expectedBody, w.Body.String())
+			}
+
+			if cacheable := w.Header().Get("Cache-Control") != "no-store"; cacheable != tc.cacheable {
+				t.Errorf("Expected cacheable %v, got Cache-Control %q", tc.cacheable, w.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
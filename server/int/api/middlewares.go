@@ -1,15 +1,27 @@
 package api
 
 import (
+	"context"
 	_ "embed"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
+	"path/filepath"
 	"slices"
 	"strings"
 
 	"github.com/massalabs/deweb-server/int/api/config"
 	"github.com/massalabs/deweb-server/pkg/cache"
+	"github.com/massalabs/deweb-server/pkg/coalesce"
 	msConfig "github.com/massalabs/deweb-server/pkg/config"
+	"github.com/massalabs/deweb-server/pkg/dnsaddress"
+	pkgErrors "github.com/massalabs/deweb-server/pkg/error"
+	"github.com/massalabs/deweb-server/pkg/headerpolicy"
+	"github.com/massalabs/deweb-server/pkg/hostresolver"
 	"github.com/massalabs/deweb-server/pkg/mns"
 	mnscache "github.com/massalabs/deweb-server/pkg/mns/cache"
 	"github.com/massalabs/deweb-server/pkg/webmanager"
@@ -17,6 +29,9 @@ import (
 	"github.com/massalabs/station/pkg/logger"
 )
 
+// mnsResolutions coalesces concurrent resolutions of the same MNS domain.
+var mnsResolutions coalesce.Group[string]
+
 //go:embed resources/domainNotFound.zip
 var domainNotFoundZip []byte
 
@@ -28,12 +43,31 @@ var brokenWebsiteZip []byte
 
 var dewebInfoPath = "/__deweb_info"
 
+// notFoundPage is the page served by websites when a resource is not found.
+const notFoundPage = "404.html"
+
+const (
+	// staleHeader is set on resources served from the cache while the node can't be reached.
+	staleHeader = "X-DeWeb-Stale"
+	// staleWarning is the Warning header value of these resources.
+	staleWarning = `110 - "Response is Stale"`
+)
+
 // SubdomainMiddleware handles subdomain website serving.
-func SubdomainMiddleware(handler http.Handler, conf *config.ServerConfig) http.Handler {
+// Websites are also served on the custom domains mapped by the host resolver.
+func SubdomainMiddleware(handler http.Handler, conf *config.ServerConfig, hostResolver hostresolver.Resolver) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		logger.Debugf("SubdomainMiddleware: Handling request for %s", r.Host)
 
-		subdomain := extractSubdomain(r.Host, conf.Domain)
+		subdomain, err := resolveHost(r.Context(), r.Host, conf, hostResolver)
+		if err != nil {
+			logger.Warnf("Host %s could not be resolved: %v", r.Host, err)
+
+			localHandler(w, r, domainNotFoundZip, cleanPath(r.URL.Path), errorStatusCode(err))
+
+			return
+		}
+
 		if subdomain == "" {
 			logger.Debug("SubdomainMiddleware: No subdomain found. Proceeding with the next handler.")
 			handler.ServeHTTP(w, r)
@@ -49,73 +83,351 @@ func SubdomainMiddleware(handler http.Handler, conf *config.ServerConfig) http.H
 			return
 		}
 
-		path := cleanPath(r.URL.Path)
-
 		logger.Debugf("SubdomainMiddleware: Subdomain %s found, resolving address", subdomain)
 
-		logger.Debugf("SubdomainMiddleware: Getting cache from context")
+		serveWebsite(conf, subdomain, "", w, r)
+	})
+}
+
+// serveWebsite serves the requested resource of the website with the given name, which is resolved to its address.
+// The request path is relative to the root of the website, and basePath is the path under which
+// the website root is served.
+func serveWebsite(conf *config.ServerConfig, name string, basePath string, w http.ResponseWriter, r *http.Request) {
+	path := cleanPath(r.URL.Path)
 
-		cache := GetCacheFromContext(r)
-		if cache == nil {
-			logger.Warnf("No cache instance found in context")
-		}
+	logger.Debugf("serveWebsite: Getting cache from context")
 
-		mnsCache := GetMNSCacheFromContext(r)
-		if mnsCache == nil {
-			logger.Warnf("No MNS cache instance found in context")
-		}
+	cache := GetCacheFromContext(r)
+	if cache == nil {
+		logger.Warnf("No cache instance found in context")
+	}
+
+	mnsCache := GetMNSCacheFromContext(r)
+	if mnsCache == nil {
+		logger.Warnf("No MNS cache instance found in context")
+	}
+
+	address, err := resolveAddress(r.Context(), name, conf, mnsCache)
+	if err != nil {
+		logger.Warnf("Website %s could not be resolved to an address: %v", name, err)
+
+		localHandler(w, r, domainNotFoundZip, path, errorStatusCode(err))
+
+		return
+	}
+
+	// The domain doesn't lead to a website
+	if !mwUtils.IsValidAddress(address) {
+		logger.Warnf("%s is not a valid address", address)
+
+		localHandler(w, r, domainNotFoundZip, path, http.StatusNotFound)
+
+		return
+	}
+
+	if !isWebsiteAllowed(address, name, conf) {
+		logger.Warnf("Website %s or address %s is not allowed", name, address)
+
+		localHandler(w, r, notAvailableZip, path, http.StatusForbidden)
+
+		return
+	}
+
+	if applyRedirectRules(conf, name, address, basePath, w, r, cache, mnsCache) {
+		return
+	}
+
+	serveContent(conf, name, address, path, w, r, cache)
+}
+
+// serveContent serves the requested resource for the given website address.
+// The website name is the one it was requested with, used with its address to select the operator header rules.
+// Conditional requests of streamed resources are answered without fetching their content, other resources
+// are validated against the content actually served, see serveMaybeEncoded.
+// Resources that are not HTML are streamed from the chain, unless they are compressed, other ones are fetched entirely
+// so that the 'Hosted by Massa' box can be injected. Range requests are handled by http.ServeContent.
+func serveContent(
+	conf *config.ServerConfig,
+	name, address, path string,
+	w http.ResponseWriter,
+	r *http.Request,
+	cache *cache.Cache,
+) {
+	// Rewritten paths are not canonicalized, as the redirect would target the rewritten path
+	trailingSlash := conf.TrailingSlash
+	if path != cleanPath(r.URL.Path) {
+		trailingSlash = config.TrailingSlashIgnore
+	}
+
+	// TODO: Check in cache before resolving the resource name ?
+	resourceName, statusCode, err := resolveResourceName(r.Context(), &conf.NetworkInfos, address, path, trailingSlash, cache)
+	if err != nil {
+		logger.Errorf("Failed to resolve website %s resource %s: %v", address, path, err)
+
+		localHandler(w, r, brokenWebsiteZip, path, errorStatusCode(err))
+
+		return
+	}
+
+	if statusCode == http.StatusMovedPermanently {
+		redirectRelative(w, r, resourceName, statusCode)
+
+		return
+	}
+
+	if statusCode != http.StatusOK {
+		serveErrorPage(conf, name, address, resourceName, statusCode, w, r, cache)
+
+		return
+	}
+
+	if isStreamable(resourceName) {
+		serveResourceStream(conf, name, address, resourceName, w, r, cache)
+
+		return
+	}
+
+	content, mimeType, info, err := getWebsiteResource(r.Context(), conf, name, address, resourceName, cache)
+	if err != nil {
+		logger.Errorf("Failed to get website %s resource %s: %v", address, resourceName, err)
+
+		localHandler(w, r, brokenWebsiteZip, path, errorStatusCode(err))
+
+		return
+	}
+
+	setResourceHeaders(w, mimeType, info.HttpHeaders)
+	setStaleHeaders(w, info)
+
+	serveMaybeEncoded(w, r, address, resourceName, mimeType, content, info, cache)
+}
+
+// redirectRelative redirects to a location relative to the requested path.
+// http.Redirect is not used as it makes the location absolute from the request path, which doesn't include
+// the base path of the website when it is served by the path gateway.
+func redirectRelative(w http.ResponseWriter, r *http.Request, location string, statusCode int) {
+	if r.URL.RawQuery != "" {
+		location += "?" + r.URL.RawQuery
+	}
+
+	w.Header().Set("Location", location)
+	w.WriteHeader(statusCode)
+}
+
+// serveErrorPage serves a page of the website with the given error status code.
+// Conditional and range requests don't apply to error responses.
+func serveErrorPage(
+	conf *config.ServerConfig,
+	name, address, resourceName string,
+	statusCode int,
+	w http.ResponseWriter,
+	r *http.Request,
+	cache *cache.Cache,
+) {
+	content, mimeType, info, err := getWebsiteResource(r.Context(), conf, name, address, resourceName, cache)
+	if err != nil {
+		logger.Errorf("Failed to get website %s error page %s: %v", address, resourceName, err)
+
+		localHandler(w, r, brokenWebsiteZip, resourceName, errorStatusCode(err))
+
+		return
+	}
+
+	setResourceHeaders(w, mimeType, info.HttpHeaders)
+	setStaleHeaders(w, info)
+
+	w.WriteHeader(statusCode)
+
+	if _, err := w.Write(content); err != nil {
+		logger.Errorf("Failed to write content: %v", err)
+	}
+}
 
-		address, err := resolveAddress(subdomain, conf.NetworkInfos, mnsCache)
+// serveResourceStream serves a resource while its chunks are fetched from the chain.
+// For range requests, only the chunks covering the requested range are fetched.
+// Resources which may be compressed for the client are read entirely instead, unless they are larger than
+// the maximum cached file size, see serveMaybeEncoded.
+// Conditional requests are answered without opening the resource, as its validators are known without reading it.
+func serveResourceStream(
+	conf *config.ServerConfig,
+	name, address, resourceName string,
+	w http.ResponseWriter,
+	r *http.Request,
+	cache *cache.Cache,
+) {
+	contentType := mime.TypeByExtension(filepath.Ext(resourceName))
+
+	if r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+		info, err := webmanager.GetResourceInfo(r.Context(), &conf.NetworkInfos, address, resourceName, cache)
 		if err != nil {
-			logger.Warnf("Subdomain %s could not be resolved to an address: %v", subdomain, err)
+			logger.Warnf("Failed to get validators of website %s resource %s: %v", address, resourceName, err)
+		} else if isNotModified(r, info.ETag, info.LastModified) {
+			logger.Debugf("Website %s resource %s not modified", address, resourceName)
+
+			etag := info.ETag
+
+			// The validator of a response which may be compressed is weak, see serveMaybeEncoded
+			if setVaryEncoding(w, contentType, resourceHeaders(conf, name, address, resourceName, info.HttpHeaders)) &&
+				acceptsEncoding(r, resourceName) {
+				etag = weakETag(etag)
+			}
 
-			localHandler(w, domainNotFoundZip, path)
+			writeNotModified(w, etag, info.LastModified)
 
 			return
 		}
+	}
+
+	logger.Debugf("Streaming website %s resource %s", address, resourceName)
+
+	reader, info, err := webmanager.OpenWebsiteResource(r.Context(), &conf.NetworkInfos, address, resourceName, cache)
+	if err != nil {
+		logger.Errorf("Failed to open website %s resource %s: %v", address, resourceName, err)
+
+		localHandler(w, r, brokenWebsiteZip, resourceName, errorStatusCode(err))
+
+		return
+	}
+
+	info.HttpHeaders = resourceHeaders(conf, name, address, resourceName, info.HttpHeaders)
 
-		if !mwUtils.IsValidAddress(address) {
-			logger.Warnf("%s is not a valid address", address)
+	if acceptsEncoding(r, resourceName) && declaredEncoding(info.HttpHeaders) == "" {
+		content, err := readCompressible(reader)
+		if err != nil {
+			logger.Errorf("Failed to read website %s resource %s: %v", address, resourceName, err)
 
-			localHandler(w, brokenWebsiteZip, path)
+			localHandler(w, r, brokenWebsiteZip, resourceName, errorStatusCode(err))
 
 			return
 		}
 
-		if !isWebsiteAllowed(address, subdomain, conf) {
-			logger.Warnf("Subdomain %s or address %s is not allowed", subdomain, address)
+		if content != nil {
+			setResourceHeaders(w, contentType, info.HttpHeaders)
+			setStaleHeaders(w, info)
 
-			localHandler(w, notAvailableZip, path)
+			serveMaybeEncoded(w, r, address, resourceName, contentType, content, info, cache)
 
 			return
 		}
+	}
 
-		serveContent(conf, address, path, w, cache)
-	})
+	// Compressible resources are only streamed when the client doesn't accept compressed responses,
+	// or when they are too large to be compressed
+	setVaryEncoding(w, contentType, info.HttpHeaders)
+	setResourceHeaders(w, contentType, info.HttpHeaders)
+	setValidatorHeaders(w, info.ETag, info.LastModified)
+	setStaleHeaders(w, info)
+
+	http.ServeContent(w, r, resourceName, info.LastModified, reader)
 }
 
-// serveContent serves the requested resource for the given website address.
-func serveContent(conf *config.ServerConfig, address string, path string, w http.ResponseWriter, cache *cache.Cache) {
-	content, mimeType, httpHeaders, err := getWebsiteResource(conf, address, path, cache)
+// readCompressible reads a resource entirely to compress it, or returns nil if it is larger than
+// the maximum cached file size, in which case it is streamed uncompressed rather than held in memory.
+func readCompressible(reader io.ReadSeeker) ([]byte, error) {
+	size, err := reader.Seek(0, io.SeekEnd)
 	if err != nil {
-		logger.Errorf("Failed to get website %s resource %s: %v", address, path, err)
+		return nil, err
+	}
 
-		localHandler(w, brokenWebsiteZip, path)
+	if size > webmanager.MaxCachedFileSize() {
+		return nil, nil
+	}
 
-		return
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(reader)
+}
+
+// isStreamable returns true if the resource can be served without reading its whole content first.
+// It is not the case for HTML resources, in which the 'Hosted by Massa' box is injected,
+// and for resources whose content type can only be detected from their content.
+func isStreamable(resourceName string) bool {
+	contentType := mime.TypeByExtension(filepath.Ext(resourceName))
+
+	return contentType != "" && !strings.HasPrefix(contentType, "text/html")
+}
+
+// resourceHeaders returns the on-chain http headers of a resource allowed by the http headers policy,
+// merged with the headers of the operator header rules matching the resource.
+// Rejected headers are logged, as they are usually a mistake of the website owner.
+func resourceHeaders(conf *config.ServerConfig, name, address, resourceName string, httpHeaders map[string]string) map[string]string {
+	policy := conf.HTTPHeaders.Policy
+	if policy == nil {
+		policy = headerpolicy.New(nil, nil)
 	}
 
-	w.Header().Set("Content-Type", mimeType)
+	filtered, err := policy.Filter(httpHeaders)
+	if err != nil {
+		logger.Warnf("Rejected http headers of website %s resource %s: %v", address, resourceName, err)
+	}
+
+	return conf.HeaderRules.Apply(filtered, name, address, "/"+strings.TrimPrefix(resourceName, "/"))
+}
+
+// setResourceHeaders sets the content type and the on-chain http headers of a resource.
+func setResourceHeaders(w http.ResponseWriter, contentType string, httpHeaders map[string]string) {
+	w.Header().Set("Content-Type", contentType)
 
 	for key, value := range httpHeaders {
 		w.Header().Set(key, value)
 	}
+}
+
+// setStaleHeaders warns clients when a resource was served from the cache because the node can't be reached,
+// in which case it may be outdated.
+func setStaleHeaders(w http.ResponseWriter, info *webmanager.ResourceInfo) {
+	if !info.Stale {
+		return
+	}
 
-	_, err = w.Write(content)
-	if err != nil {
-		logger.Errorf("Failed to write content: %v", err)
-		http.Error(w, "an error occurred while writing response", http.StatusInternalServerError)
+	w.Header().Set("Warning", staleWarning)
+	w.Header().Set(staleHeader, "true")
+}
+
+// errorStatusCode returns the HTTP status code matching an error returned while serving a website.
+func errorStatusCode(err error) int {
+	switch {
+	case errors.Is(err, pkgErrors.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, pkgErrors.ErrTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, pkgErrors.ErrNodeUnavailable), errors.Is(err, pkgErrors.ErrUnverified):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, pkgErrors.ErrCorruptedChunk), errors.Is(err, pkgErrors.ErrNodeMismatch),
+		errors.Is(err, hostresolver.ErrLookup):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// resolveHost returns the name of the website served on host: the website mapped by the host resolver
+// if the host is a custom domain, or the subdomain of the server domain otherwise.
+// Custom domain lookups are given up after the resolve timeout, or when ctx is done.
+func resolveHost(ctx context.Context, host string, conf *config.ServerConfig, hostResolver hostresolver.Resolver) (string, error) {
+	hostname := hostresolver.NormalizeHost(host)
+	serverDomain := hostresolver.NormalizeHost(conf.Domain)
+
+	isServerHost := hostname == serverDomain || strings.HasSuffix(hostname, "."+serverDomain)
+
+	if hostResolver != nil && !isServerHost && net.ParseIP(hostname) == nil {
+		ctx, cancel := config.WithTimeout(ctx, conf.Timeouts.ResolveSeconds)
+		defer cancel()
+
+		website, found, err := hostResolver.Resolve(ctx, hostname)
+		if err != nil {
+			return "", fmt.Errorf("resolving custom domain %s: %w", hostname, err)
+		}
+
+		if found {
+			logger.Debugf("Custom domain %s is mapped to website %s", hostname, website)
+			return website, nil
+		}
 	}
+
+	return extractSubdomain(host, conf.Domain), nil
 }
 
 // extractSubdomain extracts the subdomain from the host.
@@ -137,7 +449,24 @@ func cleanPath(urlPath string) string {
 }
 
 // resolveAddress resolves the subdomain to an address.
-func resolveAddress(subdomain string, network msConfig.NetworkInfos, mnsCache *mnscache.MNSCache) (string, error) {
+// Website addresses, as is or encoded in a DNS label, are used without MNS lookup.
+// Other subdomains are resolved with MNS.
+// MNS lookups are given up after the resolve timeout, or when ctx is done.
+func resolveAddress(ctx context.Context, subdomain string, conf *config.ServerConfig, mnsCache *mnscache.MNSCache) (string, error) {
+	if strings.HasPrefix(subdomain, "AS") && mwUtils.IsValidAddress(subdomain) {
+		return subdomain, nil
+	}
+
+	if dnsaddress.IsEncoded(subdomain) {
+		address, err := dnsaddress.Decode(subdomain)
+		if err == nil && mwUtils.IsValidAddress(address) {
+			logger.Debugf("Decoded subdomain %s to address %s", subdomain, address)
+			return address, nil
+		}
+
+		logger.Debugf("Subdomain %s is not an encoded address, resolving it with MNS", subdomain)
+	}
+
 	if mnsCache != nil {
 		domainTarget, ok := mnsCache.Get(subdomain)
 		if ok {
@@ -146,7 +475,13 @@ func resolveAddress(subdomain string, network msConfig.NetworkInfos, mnsCache *m
 		}
 	}
 
-	domainTarget, err := mns.ResolveDomain(&network, subdomain)
+	// Concurrent resolutions of the same domain share a single call to the node
+	domainTarget, err := mnsResolutions.Do(ctx, subdomain, func(ctx context.Context) (string, error) {
+		ctx, cancel := config.WithTimeout(ctx, conf.Timeouts.ResolveSeconds)
+		defer cancel()
+
+		return mns.ResolveDomain(ctx, &conf.NetworkInfos, subdomain)
+	})
 	if err != nil {
 		return "", fmt.Errorf("could not resolve MNS domain: %w", err)
 	}
@@ -160,73 +495,169 @@ func resolveAddress(subdomain string, network msConfig.NetworkInfos, mnsCache *m
 	return domainTarget, nil
 }
 
-// resolveResourceName resolves the resource name to the resource name on the chain.
+// resolveResourceName resolves the resource name to the resource name on the chain, and the status code to serve it with.
 // It also handles the case where the resource name is not found and tries to find the closest match
-// by adding the .html extension or by using the index.html resource.
-func resolveResourceName(network *msConfig.NetworkInfos, websiteAddress, resourceName string) (string, error) {
-	exists, err := webmanager.ResourceExistsOnChain(network, websiteAddress, resourceName)
-	if err != nil {
-		return "", fmt.Errorf("failed to check if resource exists: %w", err)
-	}
+// by adding the .html extension.
+// Directories are served with their index.html page. Depending on trailingSlash, directory paths are redirected
+// to their canonical form, in which case the status code is 301 and the returned name is the location
+// to redirect to, relative to the requested path.
+// If there is still no match, the index.html resource is used for single page apps, otherwise the website
+// 404.html page is served with a 404 status. Websites choose their behavior with their SPA_FALLBACK global metadata.
+// If it is not defined, the single page app fallback is only used if the website has no 404.html page.
+func resolveResourceName(
+	ctx context.Context,
+	network *msConfig.NetworkInfos,
+	websiteAddress, resourceName string,
+	trailingSlash string,
+	cache *cache.Cache,
+) (string, int, error) {
+	if strings.HasSuffix(resourceName, "/") {
+		name, statusCode, found, err := resolveDirectoryIndex(ctx, network, websiteAddress, resourceName, true, trailingSlash, cache)
+		if err != nil || found {
+			return name, statusCode, err
+		}
+	} else {
+		exists, err := webmanager.ResourceExistsOnChain(ctx, network, websiteAddress, resourceName, cache)
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to check if resource exists: %w", err)
+		}
+
+		if exists {
+			return resourceName, http.StatusOK, nil
+		}
 
-	if !exists {
-		logger.Warnf("Resource %s not found in website %s", resourceName, websiteAddress)
 		// Handling missing .html extension
 		if !strings.HasSuffix(resourceName, ".html") {
-			resourceName += ".html"
-
-			exists, err = webmanager.ResourceExistsOnChain(network, websiteAddress, resourceName)
+			exists, err = webmanager.ResourceExistsOnChain(ctx, network, websiteAddress, resourceName+".html", cache)
 			if err != nil {
-				return "", fmt.Errorf("failed to check if resource exists: %w", err)
+				return "", 0, fmt.Errorf("failed to check if resource exists: %w", err)
 			}
 
 			if exists {
-				return resourceName, nil
+				return resourceName + ".html", http.StatusOK, nil
 			}
 		}
 
-		// Handling Single Page Apps
-		if resourceName != "index.html" {
-			resourceName = "index.html"
+		// Handling directories requested without trailing slash
+		name, statusCode, found, err := resolveDirectoryIndex(ctx, network, websiteAddress, resourceName+"/", false, trailingSlash, cache)
+		if err != nil || found {
+			return name, statusCode, err
+		}
+	}
+
+	logger.Warnf("Resource %s not found in website %s", resourceName, websiteAddress)
 
-			exists, err = webmanager.ResourceExistsOnChain(network, websiteAddress, resourceName)
-			if err != nil {
-				return "", fmt.Errorf("failed to check if resource exists: %w", err)
-			}
+	hasNotFoundPage, err := webmanager.ResourceExistsOnChain(ctx, network, websiteAddress, notFoundPage, cache)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to check if resource exists: %w", err)
+	}
 
-			if exists {
-				return resourceName, nil
-			}
+	spaFallback, defined, err := webmanager.GetSPAFallback(ctx, network, websiteAddress)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to get SPA fallback setting: %w", err)
+	}
+
+	if !defined {
+		spaFallback = !hasNotFoundPage
+	}
+
+	// Handling Single Page Apps
+	if spaFallback && resourceName != "index.html" {
+		exists, err := webmanager.ResourceExistsOnChain(ctx, network, websiteAddress, "index.html", cache)
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to check if resource exists: %w", err)
+		}
+
+		if exists {
+			return "index.html", http.StatusOK, nil
 		}
 	}
 
-	return resourceName, nil
-}
+	if hasNotFoundPage {
+		return notFoundPage, http.StatusNotFound, nil
+	}
 
-func getWebsiteResource(config *config.ServerConfig, websiteAddress, resourceName string, cache *cache.Cache) ([]byte, string, map[string]string, error) {
-	logger.Debugf("Getting website %s resource %s", websiteAddress, resourceName)
+	return "", 0, fmt.Errorf("resource %s %w in website %s", resourceName, pkgErrors.ErrNotFound, websiteAddress)
+}
 
-	// TODO: Check in cache before resolving the resource name ?
-	resourceName, err := resolveResourceName(&config.NetworkInfos, websiteAddress, resourceName)
+// resolveDirectoryIndex returns the index.html page of a directory, or a redirect to the canonical directory path.
+// The directory path ends with a slash, requestedWithSlash tells whether it was requested with it.
+// The returned bool is false if the directory has no index.html page.
+func resolveDirectoryIndex(
+	ctx context.Context,
+	network *msConfig.NetworkInfos,
+	websiteAddress, directory string,
+	requestedWithSlash bool,
+	trailingSlash string,
+	cache *cache.Cache,
+) (string, int, bool, error) {
+	index := directory + "index.html"
+
+	exists, err := webmanager.ResourceExistsOnChain(ctx, network, websiteAddress, index, cache)
 	if err != nil {
-		return nil, "", nil, fmt.Errorf("failed to resolve resource name: %w", err)
+		return "", 0, false, fmt.Errorf("failed to check if resource exists: %w", err)
+	}
+
+	if !exists {
+		return "", 0, false, nil
 	}
 
-	content, httpHeaders, err := webmanager.GetWebsiteResource(&config.NetworkInfos, websiteAddress, resourceName, cache)
+	directoryName := strings.TrimSuffix(directory, "/")
+	directoryName = directoryName[strings.LastIndex(directoryName, "/")+1:]
+
+	// The location is relative, the directory name is escaped so that it can't be read as a scheme or a host
+	switch {
+	case trailingSlash == config.TrailingSlashAdd && !requestedWithSlash:
+		return "./" + url.PathEscape(directoryName) + "/", http.StatusMovedPermanently, true, nil
+	case trailingSlash == config.TrailingSlashRemove && requestedWithSlash:
+		return "../" + url.PathEscape(directoryName), http.StatusMovedPermanently, true, nil
+	default:
+		return index, http.StatusOK, true, nil
+	}
+}
+
+func getWebsiteResource(
+	ctx context.Context,
+	config *config.ServerConfig,
+	name, websiteAddress, resourceName string,
+	cacheInstance *cache.Cache,
+) ([]byte, string, *webmanager.ResourceInfo, error) {
+	logger.Debugf("Getting website %s resource %s", websiteAddress, resourceName)
+
+	content, info, err := webmanager.GetWebsiteResource(ctx, &config.NetworkInfos, websiteAddress, resourceName, cacheInstance)
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to get website %s resource %s: %w", websiteAddress, resourceName, err)
 	}
 
+	info.HttpHeaders = resourceHeaders(config, name, websiteAddress, resourceName, info.HttpHeaders)
+
 	contentType := ContentType(resourceName, content)
 	logger.Debugf("Got website %s resource %s with content type %s", websiteAddress, resourceName, contentType)
 
-	if strings.HasPrefix(contentType, "text/html") {
+	// Resources uploaded compressed can't be modified
+	if strings.HasPrefix(contentType, "text/html") && declaredEncoding(info.HttpHeaders) == "" &&
+		shouldInjectBox(&config.Badge, websiteAddress) {
 		logger.Debugf("Injecting 'Hosted by Massa' box")
 
-		content = InjectOnChainBox(content, config.NetworkInfos.ChainID)
+		badgeInfo, err := webmanager.GetBadgeInfo(ctx, &config.NetworkInfos, websiteAddress, cacheInstance)
+		if err != nil {
+			logger.Warnf("Failed to get badge info of website %s: %v", websiteAddress, err)
+		}
+
+		data := NewBadgeData(config.NetworkInfos.CurrentChainID(), badgeInfo)
+
+		injected, headers, err := InjectOnChainBox(content, &config.Badge, data, info.HttpHeaders)
+		if err != nil {
+			logger.Warnf("Failed to inject 'Hosted by Massa' box in website %s resource %s: %v", websiteAddress, resourceName, err)
+		} else {
+			content = injected
+			info.HttpHeaders = headers
+			// The ETag must identify the content actually served, which depends on the injected badge
+			info.ETag = cache.ContentETag(content)
+		}
 	}
 
-	return content, contentType, httpHeaders, nil
+	return content, contentType, info, nil
 }
 
 // isWebsiteAllowed checks the allow and block lists and returns false if the address or domain is not allowed.
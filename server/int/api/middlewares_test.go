@@ -0,0 +1,485 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/massalabs/deweb-server/int/api/config"
+	"github.com/massalabs/deweb-server/pkg/cache"
+	msConfig "github.com/massalabs/deweb-server/pkg/config"
+	pkgErrors "github.com/massalabs/deweb-server/pkg/error"
+	"github.com/massalabs/deweb-server/pkg/headerpolicy"
+	"github.com/massalabs/deweb-server/pkg/headerrules"
+	"github.com/massalabs/deweb-server/pkg/hostresolver"
+	mnscache "github.com/massalabs/deweb-server/pkg/mns/cache"
+	"github.com/massalabs/deweb-server/pkg/webmanager"
+)
+
+func TestErrorStatusCode(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{
+			name:     "Not found",
+			err:      fmt.Errorf("failed to get file: %w", pkgErrors.ErrNotFound),
+			expected: http.StatusNotFound,
+		},
+		{
+			name:     "Node unavailable",
+			err:      fmt.Errorf("failed to get file: %w", pkgErrors.NodeError(errors.New("connection refused"))),
+			expected: http.StatusServiceUnavailable,
+		},
+		{
+			name:     "Timeout",
+			err:      fmt.Errorf("failed to get file: %w", pkgErrors.ErrTimeout),
+			expected: http.StatusGatewayTimeout,
+		},
+		{
+			name:     "Corrupted chunk",
+			err:      fmt.Errorf("failed to get file: %w", pkgErrors.ErrCorruptedChunk),
+			expected: http.StatusBadGateway,
+		},
+		{
+			name:     "Node mismatch",
+			err:      fmt.Errorf("failed to get file: %w", pkgErrors.ErrNodeMismatch),
+			expected: http.StatusBadGateway,
+		},
+		{
+			name:     "Custom domain lookup failure",
+			err:      fmt.Errorf("resolving custom domain: %w", hostresolver.ErrLookup),
+			expected: http.StatusBadGateway,
+		},
+		{
+			name:     "Unknown error",
+			err:      errors.New("unknown"),
+			expected: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if code := errorStatusCode(tc.err); code != tc.expected {
+				t.Errorf("Expected status code %d, but got %d", tc.expected, code)
+			}
+		})
+	}
+}
+
+func TestResolveHost(t *testing.T) {
+	hostResolver := hostresolver.StaticResolver{
+		"docs.example.com": "docs",
+		"localhost":        "shadowed",
+	}
+	conf := &config.ServerConfig{Domain: "localhost"}
+
+	testCases := []struct {
+		name     string
+		host     string
+		expected string
+	}{
+		{"Server domain", "localhost:8080", ""},
+		{"Subdomain", "mysite.localhost:8080", "mysite"},
+		{"Custom domain", "docs.example.com", "docs"},
+		{"Custom domain with port", "Docs.Example.com:443", "docs"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			subdomain, err := resolveHost(context.Background(), tc.host, conf, hostResolver)
+			if err != nil {
+				t.Fatalf("Failed to resolve host %s: %v", tc.host, err)
+			}
+
+			if subdomain != tc.expected {
+				t.Errorf("Expected %q, but got %q", tc.expected, subdomain)
+			}
+		})
+	}
+}
+
+func TestResourceHeaders(t *testing.T) {
+	conf := &config.ServerConfig{
+		HTTPHeaders: config.HTTPHeadersConfig{Policy: headerpolicy.New(nil, []string{"X-Powered-By"})},
+		HeaderRules: headerrules.Rules{
+			{
+				Path: "*.html",
+				Headers: []headerrules.Header{
+					{Name: "X-Content-Type-Options", Value: "nosniff", Mode: headerrules.ModeOverride},
+					{Name: "Cache-Control", Value: "no-cache", Mode: headerrules.ModeDefault},
+				},
+			},
+		},
+	}
+
+	httpHeaders := map[string]string{
+		"Set-Cookie":    "session=1",
+		"X-Powered-By":  "DeWeb",
+		"Cache-Control": "max-age=60",
+	}
+
+	testCases := []struct {
+		name         string
+		resourceName string
+		expected     map[string]string
+	}{
+		{
+			name:         "Matching rule",
+			resourceName: "index.html",
+			expected:     map[string]string{"Cache-Control": "max-age=60", "X-Content-Type-Options": "nosniff"},
+		},
+		{
+			name:         "No matching rule",
+			resourceName: "style.css",
+			expected:     map[string]string{"Cache-Control": "max-age=60"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := resourceHeaders(conf, "blog", "AS1", tc.resourceName, httpHeaders)
+			if !maps.Equal(result, tc.expected) {
+				t.Errorf("Expected headers %v, got %v", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestSetStaleHeaders(t *testing.T) {
+	testCases := []struct {
+		name    string
+		stale   bool
+		warning string
+		header  string
+	}{
+		{name: "Fresh resource", stale: false},
+		{name: "Stale resource", stale: true, warning: staleWarning, header: "true"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+
+			setStaleHeaders(w, &webmanager.ResourceInfo{Stale: tc.stale})
+
+			if w.Header().Get("Warning") != tc.warning || w.Header().Get(staleHeader) != tc.header {
+				t.Errorf("Expected Warning %q and %s %q, got %q and %q",
+					tc.warning, staleHeader, tc.header, w.Header().Get("Warning"), w.Header().Get(staleHeader))
+			}
+		})
+	}
+}
+
+func TestResolveResourceNameNodeDown(t *testing.T) {
+	const websiteAddress = "AS1NodeDown"
+
+	cacheInstance, err := cache.NewCache(t.TempDir(), 10, 10)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	for _, name := range []string{"about.html", notFoundPage} {
+		if err := cacheInstance.Save(websiteAddress, name, []byte(name), time.Unix(1000, 0), nil); err != nil {
+			t.Fatalf("Failed to cache %s: %v", name, err)
+		}
+	}
+
+	node, network := newFakeWebsiteNode(t, []string{"index.html", "about.html", "blog.html", notFoundPage}, nil)
+	node.setDown(true)
+
+	testCases := []struct {
+		name         string
+		resourceName string
+		expected     string
+		statusCode   int
+	}{
+		{"Cached file", "about.html", "about.html", http.StatusOK},
+		{"Cached file without extension", "about", "about.html", http.StatusOK},
+		{"Uncached file", "blog.html", notFoundPage, http.StatusNotFound},
+		{"Missing file", "missing", notFoundPage, http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			name, statusCode, err := resolveResourceName(context.Background(), network, websiteAddress, tc.resourceName,
+				config.TrailingSlashIgnore, cacheInstance)
+			if err != nil {
+				t.Fatalf("Failed to resolve %s: %v", tc.resourceName, err)
+			}
+
+			if name != tc.expected || statusCode != tc.statusCode {
+				t.Errorf("Expected %s with status %d, got %s with status %d", tc.expected, tc.statusCode, name, statusCode)
+			}
+		})
+	}
+
+	// Without cache, the node is needed to answer
+	_, _, err = resolveResourceName(context.Background(), network, websiteAddress, "about.html", config.TrailingSlashIgnore, nil)
+	if errorStatusCode(err) != http.StatusServiceUnavailable {
+		t.Errorf("Expected a node unavailable error, got %v", err)
+	}
+}
+
+func TestServeResourceStreamNotModified(t *testing.T) {
+	const websiteAddress = "AS1NotModified"
+
+	cacheInstance, err := cache.NewCache(t.TempDir(), 10, 10)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	content := []byte("body { color: red; }")
+	if err := cacheInstance.Save(websiteAddress, "style.css", content, time.Unix(1000, 0), nil); err != nil {
+		t.Fatalf("Failed to cache style.css: %v", err)
+	}
+
+	node, network := newFakeWebsiteNode(t, []string{"style.css"}, map[string]string{"LAST_UPDATE": "1000"})
+	conf := &config.ServerConfig{NetworkInfos: *network}
+
+	serve := func(etag string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/style.css", nil)
+		if etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+
+		w := httptest.NewRecorder()
+		serveResourceStream(conf, "", websiteAddress, "style.css", w, r, cacheInstance)
+
+		return w
+	}
+
+	// The cached resource is served with the hash of its content as ETag
+	w := serve("")
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != cache.ContentETag(content) {
+		t.Fatalf("Expected status %d with ETag %s, got %d with ETag %s", http.StatusOK, cache.ContentETag(content), w.Code, w.Header().Get("ETag"))
+	}
+
+	calls := node.callCount()
+
+	w = serve(cache.ContentETag(content))
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status %d, got %d", http.StatusNotModified, w.Code)
+	}
+
+	if node.callCount() != calls {
+		t.Errorf("Expected the conditional request to be answered without calling the node, got %d calls", node.callCount()-calls)
+	}
+
+	// Without cache, the validators identify the website update
+	r := httptest.NewRequest(http.MethodGet, "/style.css", nil)
+	r.Header.Set("If-Modified-Since", time.Unix(1000, 0).UTC().Format(http.TimeFormat))
+
+	w = httptest.NewRecorder()
+	serveResourceStream(conf, "", websiteAddress, "style.css", w, r, nil)
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status %d, got %d", http.StatusNotModified, w.Code)
+	}
+
+	if etag := w.Header().Get("ETag"); etag == "" || etag == cache.ContentETag(content) {
+		t.Errorf("Expected an ETag identifying the website update, got %q", etag)
+	}
+}
+
+func TestServeResourceStreamEncoding(t *testing.T) {
+	const websiteAddress = "AS1StreamEncoding"
+
+	cacheInstance, err := cache.NewCache(t.TempDir(), 10, 10)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	content := bytes.Repeat([]byte(`{"key": "value"}`), 100)
+	packed := []byte("packed content")
+
+	if err := cacheInstance.Save(websiteAddress, "data.json", content, time.Unix(1000, 0), nil); err != nil {
+		t.Fatalf("Failed to cache data.json: %v", err)
+	}
+
+	if err := cacheInstance.Save(websiteAddress, "packed.js", packed, time.Unix(1000, 0), map[string]string{"Content-Encoding": "gzip"}); err != nil {
+		t.Fatalf("Failed to cache packed.js: %v", err)
+	}
+
+	_, network := newFakeWebsiteNode(t, []string{"data.json", "packed.js"}, map[string]string{"LAST_UPDATE": "1000"})
+	conf := &config.ServerConfig{NetworkInfos: *network}
+
+	defer webmanager.SetMaxCachedFileSize(webmanager.DefaultMaxCachedFileSize)
+
+	testCases := []struct {
+		name             string
+		resourceName     string
+		ifNoneMatch      string
+		maxSize          int64
+		expectedStatus   int
+		expectedEncoding string
+		expectedVary     bool
+	}{
+		{"Compressed", "data.json", "", webmanager.DefaultMaxCachedFileSize, http.StatusOK, "gzip", true},
+		{"Too large to be compressed", "data.json", "", 100, http.StatusOK, "", true},
+		{"Not modified", "data.json", cache.ContentETag(content), webmanager.DefaultMaxCachedFileSize, http.StatusNotModified, "", true},
+		{"Uploaded compressed", "packed.js", "", webmanager.DefaultMaxCachedFileSize, http.StatusOK, "gzip", false},
+		{"Uploaded compressed not modified", "packed.js", cache.ContentETag(packed), webmanager.DefaultMaxCachedFileSize, http.StatusNotModified, "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			webmanager.SetMaxCachedFileSize(tc.maxSize)
+
+			r := httptest.NewRequest(http.MethodGet, "/"+tc.resourceName, nil)
+			r.Header.Set("Accept-Encoding", "gzip")
+
+			if tc.ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", tc.ifNoneMatch)
+			}
+
+			w := httptest.NewRecorder()
+			serveResourceStream(conf, "", websiteAddress, tc.resourceName, w, r, cacheInstance)
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tc.expectedStatus, w.Code)
+			}
+
+			if encoding := w.Header().Get("Content-Encoding"); encoding != tc.expectedEncoding {
+				t.Errorf("Expected Content-Encoding %q, got %q", tc.expectedEncoding, encoding)
+			}
+
+			if vary := w.Header().Get("Vary") == "Accept-Encoding"; vary != tc.expectedVary {
+				t.Errorf("Expected Vary header %v, got %q", tc.expectedVary, w.Header().Get("Vary"))
+			}
+		})
+	}
+}
+
+func TestResolveResourceName(t *testing.T) {
+	withNotFoundPage := []string{"index.html", "about.html", "docs/index.html", notFoundPage}
+	withoutNotFoundPage := []string{"index.html", "about.html"}
+
+	testCases := []struct {
+		name         string
+		files        []string
+		metadata     map[string]string
+		resourceName string
+		expected     string
+		statusCode   int
+	}{
+		{"File", withNotFoundPage, nil, "about.html", "about.html", http.StatusOK},
+		{"File without extension", withNotFoundPage, nil, "about", "about.html", http.StatusOK},
+		{"Directory", withNotFoundPage, nil, "docs/", "docs/index.html", http.StatusOK},
+		{"Directory without slash", withNotFoundPage, nil, "docs", "docs/index.html", http.StatusOK},
+		{"Missing file with 404 page", withNotFoundPage, nil, "missing", notFoundPage, http.StatusNotFound},
+		{"Missing file without 404 page", withoutNotFoundPage, nil, "missing", "index.html", http.StatusOK},
+		{"SPA fallback enabled", withNotFoundPage, map[string]string{"SPA_FALLBACK": "true"}, "missing", "index.html", http.StatusOK},
+		{"SPA fallback disabled", withoutNotFoundPage, map[string]string{"SPA_FALLBACK": "false"}, "missing", "", http.StatusNotFound},
+		{"Invalid SPA fallback", withNotFoundPage, map[string]string{"SPA_FALLBACK": "yes"}, "missing", notFoundPage, http.StatusNotFound},
+		{"No page to fall back to", []string{"about.html"}, nil, "missing", "", http.StatusNotFound},
+	}
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, network := newFakeWebsiteNode(t, tc.files, tc.metadata)
+
+			// Each website has its own address, as website indexes are cached by address
+			websiteAddress := fmt.Sprintf("AS1Resolve%d", i)
+
+			name, statusCode, err := resolveResourceName(context.Background(), network, websiteAddress, tc.resourceName,
+				config.TrailingSlashIgnore, nil)
+			if err != nil {
+				statusCode = errorStatusCode(err)
+			}
+
+			if name != tc.expected || statusCode != tc.statusCode {
+				t.Errorf("Expected %q with status %d, got %q with status %d (%v)", tc.expected, tc.statusCode, name, statusCode, err)
+			}
+		})
+	}
+}
+
+func TestResolveResourceNameTrailingSlash(t *testing.T) {
+	_, network := newFakeWebsiteNode(t, []string{"index.html", "docs/index.html", "guides/my docs/index.html"}, nil)
+
+	testCases := []struct {
+		name          string
+		trailingSlash string
+		resourceName  string
+		expected      string
+		statusCode    int
+	}{
+		{"Add without slash", config.TrailingSlashAdd, "docs", "./docs/", http.StatusMovedPermanently},
+		{"Add with slash", config.TrailingSlashAdd, "docs/", "docs/index.html", http.StatusOK},
+		{"Remove without slash", config.TrailingSlashRemove, "docs", "docs/index.html", http.StatusOK},
+		{"Remove with slash", config.TrailingSlashRemove, "docs/", "../docs", http.StatusMovedPermanently},
+		{"Ignore without slash", config.TrailingSlashIgnore, "docs", "docs/index.html", http.StatusOK},
+		{"Ignore with slash", config.TrailingSlashIgnore, "docs/", "docs/index.html", http.StatusOK},
+		{"Add escaped", config.TrailingSlashAdd, "guides/my docs", "./my%20docs/", http.StatusMovedPermanently},
+		{"Remove escaped", config.TrailingSlashRemove, "guides/my docs/", "../my%20docs", http.StatusMovedPermanently},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			name, statusCode, err := resolveResourceName(context.Background(), network, "AS1TrailingSlash", tc.resourceName,
+				tc.trailingSlash, nil)
+			if err != nil {
+				t.Fatalf("Failed to resolve %s: %v", tc.resourceName, err)
+			}
+
+			if name != tc.expected || statusCode != tc.statusCode {
+				t.Errorf("Expected %q with status %d, got %q with status %d", tc.expected, tc.statusCode, name, statusCode)
+			}
+		})
+	}
+}
+
+func TestSubdomainMiddlewareStatus(t *testing.T) {
+	_, network := newFakeWebsiteNode(t, nil, nil)
+	downNode, downNetwork := newFakeWebsiteNode(t, []string{"index.html"}, nil)
+	downNode.setDown(true)
+
+	mnsCache := mnscache.NewMNSCache(time.Minute, 0)
+	mnsCache.Set("broken", "xx")
+
+	testCases := []struct {
+		name       string
+		host       string
+		network    *msConfig.NetworkInfos
+		blockList  []string
+		allowList  []string
+		statusCode int
+	}{
+		{"MNS domain without valid address", "broken.localhost", network, nil, nil, http.StatusNotFound},
+		{"Website without files", "AS1StatusEmpty.localhost", network, nil, nil, http.StatusNotFound},
+		{"Node down", "AS1StatusNodeDown.localhost", downNetwork, nil, nil, http.StatusServiceUnavailable},
+		{"Blocked website", "AS1StatusBlocked.localhost", network, []string{"AS1StatusBlocked"}, nil, http.StatusForbidden},
+		{"Website not allowed", "AS1StatusNotAllowed.localhost", network, nil, []string{"AS1StatusAllowed"}, http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := &config.ServerConfig{
+				Domain:       "localhost",
+				NetworkInfos: *tc.network,
+				BlockList:    tc.blockList,
+				AllowList:    tc.allowList,
+			}
+			handler := SubdomainMiddleware(http.NotFoundHandler(), conf, nil)
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Host = tc.host
+			r = r.WithContext(context.WithValue(r.Context(), mnsCacheKey, mnsCache))
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tc.statusCode {
+				t.Errorf("Expected status %d, got %d", tc.statusCode, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	msConfig "github.com/massalabs/deweb-server/pkg/config"
+	"github.com/massalabs/deweb-server/pkg/website/storagekeys"
+)
+
+// fakeWebsiteNode serves the datastore of websites through the JSON-RPC API of a node.
+// Only the file locations and the global metadata of the websites are stored.
+type fakeWebsiteNode struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	down    bool
+	calls   int
+}
+
+// fakeNodeBytes is a datastore key or value, encoded by the node API as an array of bytes.
+type fakeNodeBytes []byte
+
+func (b fakeNodeBytes) MarshalJSON() ([]byte, error) {
+	if b == nil {
+		return []byte("null"), nil
+	}
+
+	values := make([]int, len(b))
+	for i, v := range b {
+		values[i] = int(v)
+	}
+
+	return json.Marshal(values)
+}
+
+func (b *fakeNodeBytes) UnmarshalJSON(data []byte) error {
+	var values []int
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+
+	*b = make([]byte, len(values))
+	for i, v := range values {
+		(*b)[i] = byte(v)
+	}
+
+	return nil
+}
+
+// newFakeWebsiteNode returns a node serving a website with the given files and global metadata.
+func newFakeWebsiteNode(t *testing.T, files []string, metadata map[string]string) (*fakeWebsiteNode, *msConfig.NetworkInfos) {
+	t.Helper()
+
+	fake := &fakeWebsiteNode{entries: map[string][]byte{}}
+
+	for _, file := range files {
+		fake.entries[string(storagekeys.FileLocationTag())+file] = []byte(file)
+	}
+
+	for key, value := range metadata {
+		fake.entries[string(storagekeys.GlobalMetadataKey(key))] = []byte(value)
+	}
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	return fake, &msConfig.NetworkInfos{NodeURL: server.URL}
+}
+
+// setDown makes the node answer every request with a 502 error.
+func (n *fakeWebsiteNode) setDown(down bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.down = down
+}
+
+// callCount returns the number of requests the node received.
+func (n *fakeWebsiteNode) callCount() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.calls
+}
+
+func (n *fakeWebsiteNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.calls++
+
+	if n.down {
+		http.Error(w, "node down", http.StatusBadGateway)
+		return
+	}
+
+	var req struct {
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var queries []struct {
+		Address  string        `json:"address"`
+		Key      fakeNodeBytes `json:"key"`
+		Prefix   fakeNodeBytes `json:"prefix"`
+		StartKey fakeNodeBytes `json:"start_key"`
+		Count    int           `json:"count"`
+	}
+
+	if err := json.Unmarshal(req.Params[0], &queries); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var result any
+
+	switch req.Method {
+	case "get_addresses_datastore_keys":
+		query := queries[0]
+		keys := []fakeNodeBytes{}
+
+		for _, key := range slices.Sorted(maps.Keys(n.entries)) {
+			if len(keys) == query.Count {
+				break
+			}
+
+			if bytes.HasPrefix([]byte(key), query.Prefix) && (query.StartKey == nil || key > string(query.StartKey)) {
+				keys = append(keys, fakeNodeBytes(key))
+			}
+		}
+
+		result = []map[string]any{{"address": query.Address, "is_final": true, "keys": keys}}
+	case "get_datastore_entries":
+		entries := make([]map[string]any, len(queries))
+		for i, query := range queries {
+			entries[i] = map[string]any{"final_value": fakeNodeBytes(n.entries[string(query.Key)])}
+		}
+
+		result = entries
+	default:
+		http.Error(w, "unknown method "+req.Method, http.StatusBadRequest)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": 1, "result": result})
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/massalabs/deweb-server/int/api/config"
+	"github.com/massalabs/deweb-server/pkg/mns"
+	"github.com/massalabs/station/pkg/logger"
+)
+
+// pathGatewayPrefix is the path under which websites are served when the path gateway is enabled,
+// followed by the MNS name or the address of the website.
+const pathGatewayPrefix = "/_deweb/"
+
+// PathMiddleware handles website serving under /_deweb/{name}/ on the server domain,
+// for hosts where subdomains can't be resolved to the server.
+//
+// Websites expect to be served from the root of their domain, so root-relative links such as "/style.css"
+// leave the website path. Such requests are redirected back to the website they were made from,
+// which is found in their Referer. A cookie can't be used instead, as it can't be scoped to the root paths
+// that need to be redirected and would leak between websites and into the server own pages.
+// To make the Referer reliable, websites are served with a same-origin referrer policy, unless they set their own.
+// Only GET and HEAD requests are redirected, as the Referer of other requests doesn't tell which page made them.
+func PathMiddleware(handler http.Handler, conf *config.ServerConfig) http.Handler {
+	if !conf.PathGateway {
+		return handler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, path, ok := parseGatewayPath(r.URL.Path)
+		if ok {
+			servePathGateway(conf, name, path, w, r)
+
+			return
+		}
+
+		if r.URL.Path == dewebInfoPath {
+			handler.ServeHTTP(w, r)
+
+			return
+		}
+
+		if name, ok := refererWebsite(r); ok && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+			target := pathGatewayPrefix + name + r.URL.RequestURI()
+
+			logger.Debugf("PathMiddleware: Redirecting %s to %s from its referer", r.URL.Path, target)
+
+			// 307 keeps the method of the request
+			http.Redirect(w, r, target, http.StatusTemporaryRedirect)
+
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// servePathGateway serves the resource at path of the named website.
+func servePathGateway(conf *config.ServerConfig, name string, path string, w http.ResponseWriter, r *http.Request) {
+	basePath := pathGatewayPrefix + name
+
+	// Relative links are resolved against the website root only if it ends with a slash
+	if path == "" {
+		http.Redirect(w, r, basePath+"/", http.StatusMovedPermanently)
+
+		return
+	}
+
+	logger.Debugf("PathMiddleware: Serving %s of website %s", path, name)
+
+	websiteRequest := r.Clone(r.Context())
+	websiteRequest.URL.Path = path
+	websiteRequest.URL.RawPath = ""
+
+	serveWebsite(conf, strings.TrimSuffix(name, mns.Extension), basePath, &pathGatewayWriter{w}, websiteRequest)
+}
+
+// parseGatewayPath splits a path gateway URL path into the website name and the path within the website.
+// The returned path is empty if the website root is requested without trailing slash.
+func parseGatewayPath(urlPath string) (string, string, bool) {
+	rest, found := strings.CutPrefix(urlPath, pathGatewayPrefix)
+	if !found {
+		return "", "", false
+	}
+
+	name, path, hasPath := strings.Cut(rest, "/")
+	if name == "" {
+		return "", "", false
+	}
+
+	if !hasPath {
+		return name, "", true
+	}
+
+	return name, "/" + path, true
+}
+
+// refererWebsite returns the name of the website served by the path gateway from which the request was made.
+func refererWebsite(r *http.Request) (string, bool) {
+	referer, err := url.Parse(r.Referer())
+	if err != nil || referer.Host != r.Host {
+		return "", false
+	}
+
+	name, _, ok := parseGatewayPath(referer.Path)
+
+	return name, ok
+}
+
+// pathGatewayWriter sets a same-origin referrer policy on website responses which don't have one,
+// so that the path of the website is sent in the Referer of the requests it makes.
+// A policy set by the website is kept, even if it prevents its root-relative links from being redirected.
+type pathGatewayWriter struct {
+	http.ResponseWriter
+}
+
+func (w *pathGatewayWriter) WriteHeader(statusCode int) {
+	w.setReferrerPolicy()
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *pathGatewayWriter) Write(content []byte) (int, error) {
+	w.setReferrerPolicy()
+
+	return w.ResponseWriter.Write(content)
+}
+
+// Flush sends the buffered response to the client, if the underlying writer supports it.
+func (w *pathGatewayWriter) Flush() {
+	w.setReferrerPolicy()
+
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// setReferrerPolicy sets the same-origin referrer policy if the response has none.
+func (w *pathGatewayWriter) setReferrerPolicy() {
+	if w.Header().Get("Referrer-Policy") == "" {
+		w.Header().Set("Referrer-Policy", "same-origin")
+	}
+}
+
+// Unwrap returns the underlying writer, so that http.ResponseController reaches its other features.
+func (w *pathGatewayWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
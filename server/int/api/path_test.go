@@ -0,0 +1,144 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/massalabs/deweb-server/int/api/config"
+)
+
+func TestParseGatewayPath(t *testing.T) {
+	testCases := []struct {
+		urlPath      string
+		expectedName string
+		expectedPath string
+		expectedOk   bool
+	}{
+		{"/_deweb/mysite/index.html", "mysite", "/index.html", true},
+		{"/_deweb/mysite/", "mysite", "/", true},
+		{"/_deweb/mysite", "mysite", "", true},
+		{"/_deweb/mysite/assets/app.js", "mysite", "/assets/app.js", true},
+		{"/_deweb/", "", "", false},
+		{"/index.html", "", "", false},
+		{"/", "", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.urlPath, func(t *testing.T) {
+			name, path, ok := parseGatewayPath(tc.urlPath)
+			if name != tc.expectedName || path != tc.expectedPath || ok != tc.expectedOk {
+				t.Errorf("Expected (%q, %q, %v), but got (%q, %q, %v)", tc.expectedName, tc.expectedPath, tc.expectedOk, name, path, ok)
+			}
+		})
+	}
+}
+
+func TestRefererWebsite(t *testing.T) {
+	testCases := []struct {
+		name         string
+		referer      string
+		expectedName string
+		expectedOk   bool
+	}{
+		{"Website page", "http://localhost:8080/_deweb/mysite/about.html", "mysite", true},
+		{"Website root", "http://localhost:8080/_deweb/mysite/", "mysite", true},
+		{"Server page", "http://localhost:8080/index.html", "", false},
+		{"Other host", "http://example.com/_deweb/mysite/", "", false},
+		{"No referer", "", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://localhost:8080/style.css", nil)
+			if tc.referer != "" {
+				r.Header.Set("Referer", tc.referer)
+			}
+
+			name, ok := refererWebsite(r)
+			if name != tc.expectedName || ok != tc.expectedOk {
+				t.Errorf("Expected (%q, %v), but got (%q, %v)", tc.expectedName, tc.expectedOk, name, ok)
+			}
+		})
+	}
+}
+
+func TestPathGatewayWriter(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	w := &pathGatewayWriter{recorder}
+
+	// Streamed responses are flushed through the wrapper
+	if err := http.NewResponseController(w).Flush(); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+
+	if !recorder.Flushed {
+		t.Errorf("Expected the underlying writer to be flushed")
+	}
+
+	if policy := recorder.Result().Header.Get("Referrer-Policy"); policy != "same-origin" {
+		t.Errorf("Expected Referrer-Policy same-origin, got %q", policy)
+	}
+
+	if w.Unwrap() != recorder {
+		t.Errorf("Expected Unwrap to return the underlying writer")
+	}
+}
+
+func TestPathGatewayWriterKeepsPolicy(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	w := &pathGatewayWriter{recorder}
+
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(http.StatusOK)
+
+	if policy := recorder.Result().Header.Get("Referrer-Policy"); policy != "no-referrer" {
+		t.Errorf("Expected the website Referrer-Policy to be kept, got %q", policy)
+	}
+}
+
+func TestPathMiddleware(t *testing.T) {
+	conf := &config.ServerConfig{PathGateway: true}
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	handler := PathMiddleware(next, conf)
+
+	const websiteReferer = "http://localhost:8080/_deweb/mysite/about.html"
+
+	testCases := []struct {
+		name             string
+		method           string
+		target           string
+		referer          string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{"Root-relative link of a website", http.MethodGet, "/style.css?v=2", websiteReferer, http.StatusTemporaryRedirect, "/_deweb/mysite/style.css?v=2"},
+		{"Root-relative HEAD request", http.MethodHead, "/style.css", websiteReferer, http.StatusTemporaryRedirect, "/_deweb/mysite/style.css"},
+		{"Form posted by a website", http.MethodPost, "/api/form", websiteReferer, http.StatusTeapot, ""},
+		{"Server page", http.MethodGet, "/style.css", "", http.StatusTeapot, ""},
+		{"Info endpoint", http.MethodGet, dewebInfoPath, websiteReferer, http.StatusTeapot, ""},
+		{"Website root without slash", http.MethodGet, "/_deweb/mysite", "", http.StatusMovedPermanently, "/_deweb/mysite/"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, "http://localhost:8080"+tc.target, nil)
+			if tc.referer != "" {
+				r.Header.Set("Referer", tc.referer)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tc.expectedStatus, w.Code)
+			}
+
+			if location := w.Header().Get("Location"); location != tc.expectedLocation {
+				t.Errorf("Expected location %q, got %q", tc.expectedLocation, location)
+			}
+		})
+	}
+}
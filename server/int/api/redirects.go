@@ -0,0 +1,109 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/massalabs/deweb-server/int/api/config"
+	"github.com/massalabs/deweb-server/pkg/cache"
+	mnscache "github.com/massalabs/deweb-server/pkg/mns/cache"
+	"github.com/massalabs/deweb-server/pkg/webmanager"
+	mwUtils "github.com/massalabs/station-massa-wallet/pkg/utils"
+	"github.com/massalabs/station/pkg/logger"
+)
+
+// applyRedirectRules applies the first redirect or rewrite rule of the website matching the request.
+// It returns false if no rule applies, in which case the request must be served as usual.
+// Rules are shadowed by existing resources unless they are forced.
+// Redirects to a path of the website are prefixed with basePath, the path under which the website is served.
+func applyRedirectRules(
+	conf *config.ServerConfig,
+	name, address string,
+	basePath string,
+	w http.ResponseWriter,
+	r *http.Request,
+	cache *cache.Cache,
+	mnsCache *mnscache.MNSCache,
+) bool {
+	rules, err := webmanager.GetRedirectRules(r.Context(), &conf.NetworkInfos, address, cache)
+	if err != nil {
+		logger.Warnf("Failed to get redirect rules of website %s: %v", address, err)
+		return false
+	}
+
+	match, ok := rules.Match(r.URL.Path)
+	if !ok {
+		return false
+	}
+
+	if !match.Rule.Force {
+		exists, err := webmanager.ResourceExistsOnChain(r.Context(), &conf.NetworkInfos, address, cleanPath(r.URL.Path), cache)
+		if err != nil {
+			logger.Warnf("Failed to check if website %s resource %s exists: %v", address, r.URL.Path, err)
+			return false
+		}
+
+		if exists {
+			return false
+		}
+	}
+
+	logger.Debugf("Applying rule '%s %s %d' of website %s to %s", match.Rule.From, match.Rule.To, match.Rule.Status, address, r.URL.Path)
+
+	switch {
+	case match.IsRedirect():
+		target := match.Target
+		if strings.HasPrefix(target, "/") {
+			target = basePath + target
+		}
+
+		if r.URL.RawQuery != "" && !strings.Contains(target, "?") {
+			target += "?" + r.URL.RawQuery
+		}
+
+		http.Redirect(w, r, target, match.Rule.Status)
+	case match.Rule.Status == http.StatusNotFound:
+		serveErrorPage(conf, name, address, cleanPath(targetPath(match.Target)), http.StatusNotFound, w, r, cache)
+	default:
+		proxyName, path, isProxy := match.MNSTarget()
+		if !isProxy {
+			serveContent(conf, name, address, cleanPath(targetPath(match.Target)), w, r, cache)
+
+			return true
+		}
+
+		proxyAddress, err := resolveAddress(r.Context(), proxyName, conf, mnsCache)
+		if err != nil {
+			logger.Warnf("Proxy target %s of website %s could not be resolved: %v", proxyName, address, err)
+
+			localHandler(w, r, domainNotFoundZip, cleanPath(r.URL.Path), errorStatusCode(err))
+
+			return true
+		}
+
+		if !mwUtils.IsValidAddress(proxyAddress) || !isWebsiteAllowed(proxyAddress, proxyName, conf) {
+			logger.Warnf("Proxy target %s (%s) of website %s is not available", proxyName, proxyAddress, address)
+
+			localHandler(w, r, notAvailableZip, cleanPath(r.URL.Path), http.StatusForbidden)
+
+			return true
+		}
+
+		// Rules of the proxied website are not applied, so that websites can't proxy each other in a loop
+		serveContent(conf, proxyName, proxyAddress, cleanPath(targetPath(path)), w, r, cache)
+	}
+
+	return true
+}
+
+// targetPath returns the unescaped path of a rule target, without its query string.
+func targetPath(target string) string {
+	path, _, _ := strings.Cut(target, "?")
+
+	if unescaped, err := url.PathUnescape(path); err == nil {
+		return unescaped
+	}
+
+	return path
+}
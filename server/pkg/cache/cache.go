@@ -1,6 +1,8 @@
 package cache
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"hash/fnv"
 	"sync"
@@ -27,10 +29,18 @@ type cacheEntry struct {
 	content        []byte
 	modified       time.Time
 	headers        map[string]string
+	etag           string
 	websiteAddress string
 	resourceName   string
 }
 
+// ContentETag returns a strong ETag for the given content, based on its SHA-256 hash.
+func ContentETag(content []byte) string {
+	hash := sha256.Sum256(content)
+
+	return `"` + hex.EncodeToString(hash[:]) + `"`
+}
+
 // NewCache initializes the cache with configurable maximum sizes for RAM and disk storage
 func NewCache(cacheDir string, maxRAMEntries, maxDiskEntries uint64) (*Cache, error) {
 	var initErr error
@@ -103,6 +113,23 @@ func (c *Cache) GetLastModified(websiteAddress string, fileName string) (time.Ti
 	return modified, nil
 }
 
+// GetETag returns the ETag of a resource in the cache for a given website, without reading its content
+func (c *Cache) GetETag(websiteAddress string, resourceName string) (string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	key := getHashKey(websiteAddress, resourceName)
+
+	// First try RAM cache without promoting
+	if value, ok := c.ramCache.Peek(key); ok {
+		if entry, ok := value.(*cacheEntry); ok {
+			return entry.etag, nil
+		}
+	}
+
+	return c.diskCache.GetETag(websiteAddress, resourceName)
+}
+
 // Read returns the content of a resource in the cache for a given website
 func (c *Cache) Read(websiteAddress string, resourceName string) ([]byte, map[string]string, error) {
 	c.mu.Lock()
@@ -118,7 +145,7 @@ func (c *Cache) Read(websiteAddress string, resourceName string) ([]byte, map[st
 	}
 
 	// If not in RAM cache, check disk cache and move to RAM cache
-	content, modified, headers, err := c.diskCache.RemoveAndGet(websiteAddress, resourceName)
+	content, modified, headers, etag, err := c.diskCache.RemoveAndGet(websiteAddress, resourceName)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -128,6 +155,7 @@ func (c *Cache) Read(websiteAddress string, resourceName string) ([]byte, map[st
 		content:  content,
 		modified: modified,
 		headers:  headers,
+		etag:     etag,
 
 		websiteAddress: websiteAddress,
 		resourceName:   resourceName,
@@ -149,6 +177,7 @@ func (c *Cache) Save(websiteAddress string, resourceName string, content []byte,
 		content:        content,
 		modified:       modified,
 		headers:        headers,
+		etag:           ContentETag(content),
 		websiteAddress: websiteAddress,
 		resourceName:   resourceName,
 	}
@@ -167,6 +196,40 @@ func (c *Cache) Save(websiteAddress string, resourceName string, content []byte,
 	return nil
 }
 
+// variantName returns the name under which a variant of a resource is cached, next to the resource itself.
+func variantName(resourceName string, variant string) string {
+	return resourceName + "\x00" + variant
+}
+
+// SaveVariant saves a variant of a resource in the cache, such as an encoded version of its content.
+func (c *Cache) SaveVariant(websiteAddress string, resourceName string, variant string, content []byte, modified time.Time) error {
+	return c.Save(websiteAddress, variantName(resourceName, variant), content, modified, nil)
+}
+
+// ReadVariant returns a cached variant of a resource if it is not older than modified.
+// Outdated variants are removed from the cache.
+func (c *Cache) ReadVariant(websiteAddress string, resourceName string, variant string, modified time.Time) ([]byte, bool) {
+	name := variantName(resourceName, variant)
+
+	variantModified, err := c.GetLastModified(websiteAddress, name)
+	if err != nil {
+		return nil, false
+	}
+
+	if variantModified.Before(modified) {
+		_ = c.Delete(websiteAddress, name)
+
+		return nil, false
+	}
+
+	content, _, err := c.Read(websiteAddress, name)
+	if err != nil {
+		return nil, false
+	}
+
+	return content, true
+}
+
 // Delete a resource in the cache for a given website
 func (c *Cache) Delete(websiteAddress string, resourceName string) error {
 	c.mu.Lock()
@@ -185,6 +248,28 @@ func (c *Cache) Delete(websiteAddress string, resourceName string) error {
 	return nil
 }
 
+// DeleteWebsite removes all the cached resources of a website, and their variants
+func (c *Cache) DeleteWebsite(websiteAddress string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Remove from RAM cache
+	for _, key := range c.ramCache.Keys() {
+		if value, ok := c.ramCache.Peek(key); ok {
+			if entry, ok := value.(*cacheEntry); ok && entry.websiteAddress == websiteAddress {
+				c.ramCache.Remove(key)
+			}
+		}
+	}
+
+	// Remove from disk cache, including the entries saved to disk when removed from RAM cache
+	if err := c.diskCache.RemoveWebsite(websiteAddress); err != nil {
+		return fmt.Errorf("failed to delete website from disk cache: %v", err)
+	}
+
+	return nil
+}
+
 // Close saves all RAM cache entries to disk and closes the database
 func (c *Cache) Close() error {
 	c.mu.Lock()
@@ -56,6 +56,16 @@ func TestCacheItems(t *testing.T) {
 			t.Errorf("Header mismatch for item %d:\nExpected: %s\nGot: %s", i, fmt.Sprintf("Value %d", i), headers["My-Header"])
 		}
 
+		// Test GetETag
+		etag, err := cache.GetETag(website, fileName)
+		if err != nil {
+			t.Fatalf("Failed to get ETag for item %d: %v", i, err)
+		}
+
+		if etag != ContentETag(expectedContent) {
+			t.Errorf("ETag mismatch for item %d:\nExpected: %s\nGot: %s", i, ContentETag(expectedContent), etag)
+		}
+
 		// Test GetHeader
 		headerValue, err := cache.GetHeader(website, fileName, "My-Header")
 		if err != nil {
@@ -76,4 +86,49 @@ func TestCacheItems(t *testing.T) {
 			t.Errorf("Headers mismatch for item %d:\nExpected: %v\nGot: %v", i, map[string]string{"My-Header": fmt.Sprintf("Value %d", i)}, headers)
 		}
 	}
+
+	// Test variants, saved next to the resource they derive from
+	modified := time.Now()
+	variant := []byte("gzip content")
+
+	if err := cache.SaveVariant(website, "file0.txt", "gzip", variant, modified); err != nil {
+		t.Fatalf("Failed to save variant: %v", err)
+	}
+
+	if content, ok := cache.ReadVariant(website, "file0.txt", "gzip", modified); !ok || !bytes.Equal(content, variant) {
+		t.Errorf("Variant mismatch:\nExpected: %s\nGot: %s", variant, content)
+	}
+
+	if content, _, err := cache.Read(website, "file0.txt"); err != nil || !bytes.Equal(content, items["file0.txt"]) {
+		t.Errorf("Saving a variant must not change the resource content, got: %s", content)
+	}
+
+	if _, ok := cache.ReadVariant(website, "file0.txt", "gzip", modified.Add(time.Second)); ok {
+		t.Errorf("Outdated variant must not be returned")
+	}
+
+	if _, ok := cache.ReadVariant(website, "file0.txt", "gzip", modified); ok {
+		t.Errorf("Outdated variant must be removed")
+	}
+
+	// Test deleting a website, whose resources are both in RAM and on disk
+	otherWebsite := "other-website.com"
+	if err := cache.Save(otherWebsite, "file0.txt", []byte("other content"), time.Now(), nil); err != nil {
+		t.Fatalf("Failed to save other website item: %v", err)
+	}
+
+	if err := cache.DeleteWebsite(website); err != nil {
+		t.Fatalf("Failed to delete website: %v", err)
+	}
+
+	for i := 0; i < numItems; i++ {
+		fileName := fmt.Sprintf("file%d.txt", i)
+		if _, _, err := cache.Read(website, fileName); err == nil {
+			t.Errorf("Item %d of deleted website must be removed", i)
+		}
+	}
+
+	if content, _, err := cache.Read(otherWebsite, "file0.txt"); err != nil || string(content) != "other content" {
+		t.Errorf("Deleting a website must not remove other websites items, got: %s, %v", content, err)
+	}
 }
@@ -17,6 +17,7 @@ const (
 	entrySubTagData    = 0x02 // Subtag for entry data
 	entrySubTagTime    = 0x03 // Subtag for entry timestamp
 	entrySubTagHeaders = 0x04 // Subtag for entry headers
+	entrySubTagETag    = 0x05 // Subtag for entry ETag
 	idCounterIndexTag  = 0x02
 
 	// Header serialization separators
@@ -52,6 +53,16 @@ func getCacheKey(websiteAddress, resourceName string) []byte {
 	return buf
 }
 
+// createWebsitePrefix returns the prefix of the keys of all the entries of a website
+func createWebsitePrefix(websiteAddress string) []byte {
+	prefix := make([]byte, 1+8+len(websiteAddress))
+	prefix[0] = entryTag
+	binary.BigEndian.PutUint64(prefix[1:], uint64(len(websiteAddress)))
+	copy(prefix[9:], websiteAddress)
+
+	return prefix
+}
+
 // createEntryPrefix returns a prefix key for a website and resource entry
 // Note: We create a new byte slice and copy data rather than using append
 // because Badger requires variables within a transaction to have stable
@@ -118,6 +129,19 @@ func createHeadersKey(entryPrefix []byte) []byte {
 	return key
 }
 
+// createETagKey returns a key for an entry's ETag
+// Note: We create a new byte slice and copy data rather than using append
+// because Badger requires variables within a transaction to have stable
+// underlying storage. Modifying slices in-place can cause bugs with Badger
+// as it may reference the memory later.
+func createETagKey(entryPrefix []byte) []byte {
+	key := make([]byte, len(entryPrefix)+1)
+	copy(key, entryPrefix)
+	key[len(entryPrefix)] = entrySubTagETag
+
+	return key
+}
+
 // createIdCounterIndexKey returns a key for an entry in the ID counter index
 // Note: We create a new byte slice and copy data rather than using append
 // because Badger requires variables within a transaction to have stable
@@ -195,6 +219,11 @@ func NewDiskCache(cacheDir string, maxEntries uint64) (*DiskCache, error) {
 	opts := badger.DefaultOptions(cacheDir)
 	opts.Logger = nil // Disable BadgerDB's logger
 
+	return openDiskCache(opts, maxEntries)
+}
+
+// openDiskCache opens the disk cache database with the given options.
+func openDiskCache(opts badger.Options, maxEntries uint64) (*DiskCache, error) {
 	db, err := badger.Open(opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open BadgerDB: %v", err)
@@ -289,6 +318,57 @@ func (d *DiskCache) GetLastModified(websiteAddress, resourceName string) (time.T
 	return modified, nil
 }
 
+// getETag retrieves the ETag of an entry from the database.
+// Entries saved without an ETag get one computed from their content.
+func (d *DiskCache) getETag(txn *badger.Txn, entryPrefix []byte) (string, error) {
+	item, err := txn.Get(createETagKey(entryPrefix))
+	if err == nil {
+		etag, err := item.ValueCopy(nil)
+		if err != nil {
+			return "", err
+		}
+
+		return string(etag), nil
+	}
+
+	if err != badger.ErrKeyNotFound {
+		return "", err
+	}
+
+	item, err = txn.Get(createDataKey(entryPrefix))
+	if err != nil {
+		return "", err
+	}
+
+	content, err := item.ValueCopy(nil)
+	if err != nil {
+		return "", err
+	}
+
+	return ContentETag(content), nil
+}
+
+// GetETag returns the ETag of a resource in the disk cache
+func (d *DiskCache) GetETag(websiteAddress, resourceName string) (string, error) {
+	var etag string
+
+	err := d.db.View(func(txn *badger.Txn) error {
+		var err error
+
+		etag, err = d.getETag(txn, createEntryPrefix(websiteAddress, resourceName))
+		if err != nil {
+			return fmt.Errorf("etag not found for website %s, resource %s: %v", websiteAddress, resourceName, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return etag, nil
+}
+
 // getHeaders retrieves and parses headers from the database
 func (d *DiskCache) getHeaders(txn *badger.Txn, entryPrefix []byte) (map[string]string, error) {
 	// Create headers key
@@ -357,6 +437,11 @@ func (d *DiskCache) deleteEntry(txn *badger.Txn, websiteAddress, resourceName st
 		return fmt.Errorf("failed to delete timestamp entry: %v", err)
 	}
 
+	// Delete the ETag entry
+	if err := txn.Delete(createETagKey(entryPrefix)); err != nil {
+		return fmt.Errorf("failed to delete ETag entry: %v", err)
+	}
+
 	// Delete the ID counter index entry
 	idCounterIndexKey := createIdCounterIndexKey(binary.BigEndian.Uint64(idCounterValue))
 	if err := txn.Delete(idCounterIndexKey); err != nil {
@@ -372,7 +457,7 @@ func (d *DiskCache) deleteEntry(txn *badger.Txn, websiteAddress, resourceName st
 }
 
 // saveEntry saves an entry to the disk cache
-func (d *DiskCache) saveEntry(txn *badger.Txn, websiteAddress, resourceName string, content []byte, headers map[string]string, modified time.Time) error {
+func (d *DiskCache) saveEntry(txn *badger.Txn, websiteAddress, resourceName string, content []byte, headers map[string]string, etag string, modified time.Time) error {
 	// Create the entry key prefix
 	entryPrefix := createEntryPrefix(websiteAddress, resourceName)
 
@@ -409,6 +494,11 @@ func (d *DiskCache) saveEntry(txn *badger.Txn, websiteAddress, resourceName stri
 		return fmt.Errorf("failed to save headers: %v", err)
 	}
 
+	// Save the ETag
+	if err := txn.Set(createETagKey(entryPrefix), []byte(etag)); err != nil {
+		return fmt.Errorf("failed to save ETag: %v", err)
+	}
+
 	// Save the ID counter index
 	idCounterIndexKey := createIdCounterIndexKey(d.idCounter)
 
@@ -474,7 +564,7 @@ func (d *DiskCache) SaveResource(entry *cacheEntry) error {
 		}
 
 		// Save the new entry
-		return d.saveEntry(txn, entry.websiteAddress, entry.resourceName, entry.content, entry.headers, entry.modified)
+		return d.saveEntry(txn, entry.websiteAddress, entry.resourceName, entry.content, entry.headers, entry.etag, entry.modified)
 	})
 }
 
@@ -485,16 +575,84 @@ func (d *DiskCache) Remove(websiteAddress, resourceName string) error {
 	})
 }
 
+// RemoveWebsite removes all the resources of a website from the disk cache.
+// The keys are deleted with a write batch, which is split into several transactions for large websites.
+func (d *DiskCache) RemoveWebsite(websiteAddress string) error {
+	prefix := createWebsitePrefix(websiteAddress)
+
+	var (
+		keys    [][]byte
+		entries uint64
+	)
+
+	err := d.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = prefix
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			key := item.KeyCopy(nil)
+			keys = append(keys, key)
+
+			// Each entry has a single ID counter key: prefix, resource length, resource name and subtag
+			if len(key) < len(prefix)+8+1 {
+				continue
+			}
+
+			resourceLen := binary.BigEndian.Uint64(key[len(prefix) : len(prefix)+8])
+			if uint64(len(key)) != uint64(len(prefix))+8+resourceLen+1 || key[len(key)-1] != entrySubTagID {
+				continue
+			}
+
+			idCounterValue, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			keys = append(keys, createIdCounterIndexKey(binary.BigEndian.Uint64(idCounterValue)))
+			entries++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list website entries: %v", err)
+	}
+
+	batch := d.db.NewWriteBatch()
+	defer batch.Cancel()
+
+	for _, key := range keys {
+		if err := batch.Delete(key); err != nil {
+			return fmt.Errorf("failed to delete website entry: %v", err)
+		}
+	}
+
+	if err := batch.Flush(); err != nil {
+		return fmt.Errorf("failed to delete website entries: %v", err)
+	}
+
+	// Update entry count in memory only
+	d.entryCount -= min(entries, d.entryCount)
+
+	return nil
+}
+
 // Close closes the database
 func (d *DiskCache) Close() error {
 	return d.db.Close()
 }
 
-// RemoveAndGet retrieves a resource from disk cache, removes it, and returns its content and timestamp
-func (d *DiskCache) RemoveAndGet(websiteAddress, resourceName string) ([]byte, time.Time, map[string]string, error) {
+// RemoveAndGet retrieves a resource from disk cache, removes it, and returns its content, timestamp, headers and ETag
+func (d *DiskCache) RemoveAndGet(websiteAddress, resourceName string) ([]byte, time.Time, map[string]string, string, error) {
 	var content []byte
 	var modified time.Time
 	var headers map[string]string
+	var etag string
 
 	err := d.db.Update(func(txn *badger.Txn) error {
 		// Create the entry key prefix
@@ -531,12 +689,18 @@ func (d *DiskCache) RemoveAndGet(websiteAddress, resourceName string) ([]byte, t
 			return fmt.Errorf("headers not found for website %s, resource %s: %v", websiteAddress, resourceName, err)
 		}
 
+		// Get the ETag
+		etag, err = d.getETag(txn, entryPrefix)
+		if err != nil {
+			return fmt.Errorf("etag not found for website %s, resource %s: %v", websiteAddress, resourceName, err)
+		}
+
 		// Remove from disk
 		return d.deleteEntry(txn, websiteAddress, resourceName)
 	})
 	if err != nil {
-		return nil, time.Time{}, nil, err
+		return nil, time.Time{}, nil, "", err
 	}
 
-	return content, modified, headers, nil
+	return content, modified, headers, etag, nil
 }
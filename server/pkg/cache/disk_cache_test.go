@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func TestRemoveWebsite(t *testing.T) {
+	// A small memory table limits the size of a transaction to a few hundred entries
+	opts := badger.DefaultOptions(t.TempDir()).WithMemTableSize(1 << 20).WithValueThreshold(1 << 10)
+	opts.Logger = nil
+
+	diskCache, err := openDiskCache(opts, 10000)
+	if err != nil {
+		t.Fatalf("Failed to open disk cache: %v", err)
+	}
+	defer diskCache.Close()
+
+	const entries = 2000
+
+	for i := range entries {
+		entry := &cacheEntry{
+			content:        []byte("content"),
+			modified:       time.Now(),
+			headers:        map[string]string{"Content-Type": "text/plain"},
+			etag:           `"etag"`,
+			websiteAddress: "AS1Large",
+			resourceName:   fmt.Sprintf("file%d.txt", i),
+		}
+
+		if err := diskCache.SaveResource(entry); err != nil {
+			t.Fatalf("Failed to save entry %d: %v", i, err)
+		}
+	}
+
+	other := &cacheEntry{content: []byte("other"), modified: time.Now(), websiteAddress: "AS1Other", resourceName: "index.html"}
+	if err := diskCache.SaveResource(other); err != nil {
+		t.Fatalf("Failed to save entry of another website: %v", err)
+	}
+
+	// Deleting every entry at once would exceed the size of a transaction.
+	// The entry count is restored, as it is updated in memory by the discarded deletions.
+	entryCount := diskCache.entryCount
+
+	err = diskCache.db.Update(func(txn *badger.Txn) error {
+		for i := range entries {
+			if err := diskCache.deleteEntry(txn, "AS1Large", fmt.Sprintf("file%d.txt", i)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	// deleteEntry doesn't wrap the errors of the transaction
+	if err == nil || !strings.Contains(err.Error(), badger.ErrTxnTooBig.Error()) {
+		t.Fatalf("Expected the website not to fit in a transaction, got %v", err)
+	}
+
+	diskCache.entryCount = entryCount
+
+	if err := diskCache.RemoveWebsite("AS1Large"); err != nil {
+		t.Fatalf("Failed to remove website: %v", err)
+	}
+
+	if diskCache.entryCount != 1 {
+		t.Errorf("Expected 1 entry left, got %d", diskCache.entryCount)
+	}
+
+	if _, err := diskCache.GetLastModified("AS1Large", "file0.txt"); err == nil {
+		t.Errorf("Expected the website entries to be removed")
+	}
+
+	if _, err := diskCache.GetLastModified("AS1Other", "index.html"); err != nil {
+		t.Errorf("Expected the other website to be kept: %v", err)
+	}
+
+	// No key of the website is left, including the ID counter index entries
+	err = diskCache.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		keys := 0
+		for it.Seek([]byte{idCounterIndexTag}); it.ValidForPrefix([]byte{idCounterIndexTag}); it.Next() {
+			keys++
+		}
+
+		if keys != 1 {
+			t.Errorf("Expected 1 ID counter index entry left, got %d", keys)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to read database: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+// Package coalesce merges concurrent calls doing the same work into a single call.
+//
+// When many requests miss the cache at the same time, for instance right after a website update,
+// only one of them fetches the data from the node and the others wait for its result.
+// Each caller can stop waiting when its own context is done, without cancelling the shared call,
+// which completes for the other callers.
+package coalesce
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Group coalesces concurrent calls with the same key.
+// The zero value is ready to use.
+type Group[T any] struct {
+	group singleflight.Group
+}
+
+// Do calls fn once for all concurrent callers with the same key, and returns its result to each of them.
+// The call is run with a context detached from the caller's one, so that it is not cancelled when the first caller
+// gives up. If ctx is done before the result is available, Do returns the context error.
+// The result is shared between callers, so it must not be modified.
+func (g *Group[T]) Do(ctx context.Context, key string, fn func(ctx context.Context) (T, error)) (T, error) {
+	results := g.group.DoChan(key, func() (interface{}, error) {
+		return fn(context.WithoutCancel(ctx))
+	})
+
+	select {
+	case <-ctx.Done():
+		var zero T
+
+		return zero, fmt.Errorf("waiting for %s: %w", key, context.Cause(ctx))
+	case result := <-results:
+		if result.Err != nil {
+			var zero T
+
+			return zero, result.Err
+		}
+
+		value, _ := result.Val.(T)
+
+		return value, nil
+	}
+}
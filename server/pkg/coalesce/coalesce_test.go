@@ -0,0 +1,114 @@
+package coalesce
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDoCoalescesConcurrentCalls(t *testing.T) {
+	var group Group[string]
+
+	var calls atomic.Int32
+
+	release := make(chan struct{})
+	fn := func(context.Context) (string, error) {
+		calls.Add(1)
+		<-release
+
+		return "result", nil
+	}
+
+	const callers = 10
+
+	var wg sync.WaitGroup
+
+	results := make([]string, callers)
+	errs := make([]error, callers)
+
+	for i := range callers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			results[i], errs[i] = group.Do(context.Background(), "key", fn)
+		}()
+	}
+
+	// Let the callers join the call before it completes
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected 1 call, got %d", calls.Load())
+	}
+
+	for i := range callers {
+		if errs[i] != nil || results[i] != "result" {
+			t.Errorf("Caller %d: expected result, got %q, %v", i, results[i], errs[i])
+		}
+	}
+}
+
+func TestDoCallerCancellation(t *testing.T) {
+	var group Group[string]
+
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (string, error) {
+		<-release
+
+		// The shared call is not cancelled by the caller giving up
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+
+		return "result", nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	cancelled := make(chan error)
+
+	go func() {
+		_, err := group.Do(ctx, "key", fn)
+		cancelled <- err
+	}()
+
+	waiting := make(chan string)
+
+	go func() {
+		result, _ := group.Do(context.Background(), "key", fn)
+		waiting <- result
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	if err := <-cancelled; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected cancelled caller to get context.Canceled, got %v", err)
+	}
+
+	close(release)
+
+	if result := <-waiting; result != "result" {
+		t.Errorf("Expected other caller to get the result, got %q", result)
+	}
+}
+
+func TestDoError(t *testing.T) {
+	var group Group[int]
+
+	expected := errors.New("node unavailable")
+
+	_, err := group.Do(context.Background(), "key", func(context.Context) (int, error) {
+		return 0, expected
+	})
+	if !errors.Is(err, expected) {
+		t.Errorf("Expected %v, got %v", expected, err)
+	}
+}
@@ -0,0 +1,131 @@
+// Package compression negotiates and applies the content encoding of served resources.
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	Gzip   = "gzip"
+	Brotli = "br"
+
+	// MinSize is the size under which resources are not worth compressing.
+	MinSize = 1024
+)
+
+// Supported lists the encodings the server can apply, by order of preference.
+var Supported = []string{Brotli, Gzip}
+
+// compressibleTypes lists the content types that benefit from compression, in addition to text/* types.
+var compressibleTypes = []string{
+	"application/javascript",
+	"application/json",
+	"application/manifest+json",
+	"application/wasm",
+	"application/xml",
+	"image/svg+xml",
+}
+
+// IsCompressible returns true if resources of the given content type benefit from compression.
+func IsCompressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(strings.ToLower(mediaType))
+
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+
+	for _, compressible := range compressibleTypes {
+		if mediaType == compressible {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Negotiate returns the preferred supported encoding accepted by the Accept-Encoding header value,
+// or an empty string if the resource must be sent without encoding.
+// Encodings are chosen by their quality value, then by the order of Supported.
+func Negotiate(acceptEncoding string) string {
+	qualities := parseAcceptEncoding(acceptEncoding)
+
+	best := ""
+	bestQuality := 0.0
+
+	for _, encoding := range Supported {
+		quality, found := qualities[encoding]
+		if !found {
+			quality, found = qualities["*"]
+		}
+
+		if found && quality > bestQuality {
+			best = encoding
+			bestQuality = quality
+		}
+	}
+
+	return best
+}
+
+// parseAcceptEncoding returns the quality value of each coding of an Accept-Encoding header value.
+func parseAcceptEncoding(acceptEncoding string) map[string]float64 {
+	qualities := make(map[string]float64)
+
+	for _, element := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(element, ";")
+
+		coding = strings.TrimSpace(strings.ToLower(coding))
+		if coding == "" {
+			continue
+		}
+
+		quality := 1.0
+
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+
+			quality = parsed
+		}
+
+		qualities[coding] = quality
+	}
+
+	return qualities
+}
+
+// Compress encodes the content with the given encoding.
+func Compress(content []byte, encoding string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	var writer io.WriteCloser
+
+	switch encoding {
+	case Gzip:
+		writer = gzip.NewWriter(&buf)
+	case Brotli:
+		writer = brotli.NewWriterLevel(&buf, brotli.DefaultCompression)
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %s", encoding)
+	}
+
+	if _, err := writer.Write(content); err != nil {
+		return nil, fmt.Errorf("compressing content with %s: %w", encoding, err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("compressing content with %s: %w", encoding, err)
+	}
+
+	return buf.Bytes(), nil
+}
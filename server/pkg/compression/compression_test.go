@@ -0,0 +1,101 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestNegotiate(t *testing.T) {
+	testCases := []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", Gzip},
+		{"gzip, deflate, br", Brotli},
+		{"br;q=0.5, gzip", Gzip},
+		{"br;q=0, gzip;q=0", ""},
+		{"GZIP", Gzip},
+		{"*", Brotli},
+		{"*;q=0.5, br;q=0", Gzip},
+		{"deflate", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.acceptEncoding, func(t *testing.T) {
+			if encoding := Negotiate(tc.acceptEncoding); encoding != tc.expected {
+				t.Errorf("Expected %q, but got %q", tc.expected, encoding)
+			}
+		})
+	}
+}
+
+func TestIsCompressible(t *testing.T) {
+	testCases := []struct {
+		contentType string
+		expected    bool
+	}{
+		{"text/html; charset=utf-8", true},
+		{"text/css", true},
+		{"application/javascript", true},
+		{"image/svg+xml", true},
+		{"image/png", false},
+		{"application/octet-stream", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.contentType, func(t *testing.T) {
+			if compressible := IsCompressible(tc.contentType); compressible != tc.expected {
+				t.Errorf("Expected %v, but got %v", tc.expected, compressible)
+			}
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	content := bytes.Repeat([]byte("<p>Hosted on Massa</p>\n"), 100)
+
+	testCases := []struct {
+		encoding  string
+		newReader func(io.Reader) (io.Reader, error)
+	}{
+		{Gzip, func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{Brotli, func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.encoding, func(t *testing.T) {
+			compressed, err := Compress(content, tc.encoding)
+			if err != nil {
+				t.Fatalf("Failed to compress content: %v", err)
+			}
+
+			if len(compressed) >= len(content) {
+				t.Errorf("Compressed content is not smaller: %d >= %d bytes", len(compressed), len(content))
+			}
+
+			reader, err := tc.newReader(bytes.NewReader(compressed))
+			if err != nil {
+				t.Fatalf("Failed to create reader: %v", err)
+			}
+
+			decompressed, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("Failed to decompress content: %v", err)
+			}
+
+			if !bytes.Equal(decompressed, content) {
+				t.Errorf("Decompressed content mismatch")
+			}
+		})
+	}
+
+	if _, err := Compress(content, "deflate"); err == nil {
+		t.Errorf("Expected an error for an unsupported encoding")
+	}
+}
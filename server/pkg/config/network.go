@@ -1,8 +1,10 @@
 package config
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/massalabs/deweb-server/pkg/nodepool"
 	"github.com/massalabs/station/pkg/logger"
 	"github.com/massalabs/station/pkg/node"
 )
@@ -16,30 +18,122 @@ const (
 )
 
 type NetworkInfos struct {
-	Name    string
+	Name string
+	// NodeURL is the URL of the main node, the first one of the pool.
 	NodeURL string
 	Version string
+	// ChainID is the chain ID of the network, it is zero if it is not known yet, see CurrentChainID.
 	ChainID uint64
+	// Nodes is the pool of nodes every call goes through, it is nil if the network was not checked.
+	Nodes *nodepool.Pool
+	// Reads are the options of the reads of website data, the default ones are used if it is nil.
+	Reads *ReadOptions
 }
 
-func NewNetworkConfig(NodeURL string) (NetworkInfos, error) {
-	client := node.NewClient(NodeURL)
+// NetworkChainID returns the chain ID of a known network name.
+func NetworkChainID(name string) (uint64, bool) {
+	switch name {
+	case MainnetName:
+		return MainnetChainID, true
+	case BuildnetName:
+		return BuildnetChainID, true
+	default:
+		return 0, false
+	}
+}
 
-	status, err := node.Status(client)
+// NewNetworkConfig checks the given nodes and returns the infos of their network.
+// Every node must report the same chain ID, unreachable ones are skipped until they answer.
+// Nodes which don't answer before ctx is done are considered unreachable.
+func NewNetworkConfig(ctx context.Context, nodeURLs ...string) (NetworkInfos, error) {
+	return NewNetworkConfigWithOptions(ctx, nodepool.DefaultOptions(), nodeURLs...)
+}
+
+// NewNetworkConfigWithOptions is NewNetworkConfig with the given retries, circuit breaker and expected chain ID options.
+// If the nodes can't be checked, the returned infos still hold the pool along with the error,
+// so that the server can start offline and use the nodes once its health checks reach them.
+func NewNetworkConfigWithOptions(ctx context.Context, opts nodepool.Options, nodeURLs ...string) (NetworkInfos, error) {
+	pool, err := nodepool.New(nodeURLs, opts)
+	if err != nil {
+		return NetworkInfos{}, fmt.Errorf("unable to create node pool: %w", err)
+	}
+
+	if err := pool.Check(ctx); err != nil {
+		offline := NetworkInfos{
+			Name:    getNetworkName(opts.ChainID),
+			NodeURL: nodeURLs[0],
+			ChainID: opts.ChainID,
+			Nodes:   pool,
+		}
+
+		return offline, fmt.Errorf("unable to check nodes: %w", err)
+	}
+
+	var status *node.State
+
+	err = pool.DoURL(ctx, func(nodeURL string) error {
+		nodeStatus, statusErr := nodepool.Status(ctx, nodeURL)
+		if statusErr != nil {
+			return statusErr
+		}
+
+		status = nodeStatus
+
+		return nil
+	})
 	if err != nil {
 		return NetworkInfos{}, fmt.Errorf("unable to get node status: %w", err)
 	}
+
 	chainID, networkName := getChainIDAndNetworkName(status)
 	nodeVersion := getNodeVersion(status)
 
 	return NetworkInfos{
 		Name:    networkName,
-		NodeURL: NodeURL,
+		NodeURL: nodeURLs[0],
 		Version: nodeVersion,
 		ChainID: chainID,
+		Nodes:   pool,
 	}, nil
 }
 
+// CurrentChainID returns the chain ID of the network. If it was not known when the network was configured,
+// it is the one reported by the nodes since then, or zero if they still could not be reached.
+func (n *NetworkInfos) CurrentChainID() uint64 {
+	if n.ChainID == 0 && n.Nodes != nil {
+		return n.Nodes.ChainID()
+	}
+
+	return n.ChainID
+}
+
+// NodeCall calls fn with a client of a node of the network and returns its result.
+// Calls go through the node pool if there is one, see nodepool.Pool.Do, otherwise to NodeURL.
+// The caller stops waiting for the node when ctx is done.
+func NodeCall[T any](ctx context.Context, network *NetworkInfos, fn func(client *node.Client) (T, error)) (T, error) {
+	if network.Nodes == nil {
+		return nodepool.WithContext(ctx, func() (T, error) {
+			return fn(node.NewClient(network.NodeURL))
+		})
+	}
+
+	return nodepool.Call(ctx, network.Nodes, fn)
+}
+
+// NodeURLCall calls fn with the URL of a node of the network, for the calls not made with a node client.
+// fn is expected to pass ctx to its request.
+func NodeURLCall(ctx context.Context, network *NetworkInfos, fn func(nodeURL string) error) error {
+	if network.Nodes == nil {
+		if err := ctx.Err(); err != nil {
+			return context.Cause(ctx)
+		}
+
+		return fn(network.NodeURL)
+	}
+
+	return network.Nodes.DoURL(ctx, fn)
+}
+
 // Returns node version from node status
 func getNodeVersion(status *node.State) string {
 	nodeVersion := "unknown"
@@ -60,15 +154,24 @@ func getChainIDAndNetworkName(status *node.State) (uint64, string) {
 
 	if status.ChainID != nil {
 		chainID = uint64(*status.ChainID)
-		switch chainID {
-		case MainnetChainID:
-			networkName = MainnetName
-		case BuildnetChainID:
-			networkName = BuildnetName
-		default:
+		networkName = getNetworkName(chainID)
+
+		if networkName == "unknown" {
 			logger.Warnf("Unknown chain ID: %d", chainID)
 		}
 	}
 
 	return chainID, networkName
 }
+
+// Returns the name of the network with the given chain ID, "unknown" if it is not a known network
+func getNetworkName(chainID uint64) string {
+	switch chainID {
+	case MainnetChainID:
+		return MainnetName
+	case BuildnetChainID:
+		return BuildnetName
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/massalabs/deweb-server/pkg/nodepool"
+)
+
+func TestNewNetworkConfigOffline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "node down", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	opts := nodepool.DefaultOptions()
+	opts.ChainID = BuildnetChainID
+
+	network, err := NewNetworkConfigWithOptions(context.Background(), opts, server.URL)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	// The pool is kept to be used once the nodes can be reached
+	if network.Nodes == nil || network.NodeURL != server.URL {
+		t.Errorf("Expected the node pool to be kept, got %+v", network)
+	}
+
+	if network.CurrentChainID() != BuildnetChainID || network.Name != BuildnetName {
+		t.Errorf("Expected the configured network, got %s with chain ID %d", network.Name, network.CurrentChainID())
+	}
+}
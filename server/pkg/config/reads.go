@@ -0,0 +1,79 @@
+package config
+
+import (
+	"context"
+	"time"
+)
+
+// Verification modes of the website data read from the nodes.
+const (
+	// VerificationOff trusts the node the data is read from.
+	VerificationOff = "off"
+	// VerificationWarn logs the data which other nodes don't return identically.
+	VerificationWarn = "warn"
+	// VerificationFail refuses to serve the data which other nodes don't return identically.
+	VerificationFail = "fail"
+)
+
+// Default options of the reads of website data.
+const (
+	DefaultLastUpdateTimeout = 5 * time.Second
+	DefaultIndexTimeout      = 30 * time.Second
+	DefaultReadTimeout       = 15 * time.Second
+	DefaultIndexMaxAge       = 60 * time.Second
+	DefaultChunkFetchWorkers = 4
+	DefaultVerificationNodes = 2
+)
+
+// ReadOptions configures how the website data is read from the nodes of a network.
+// Reads shared by several requests, such as building the index of a website, are only bounded by their timeout,
+// while the others are also given up when their context is done. A zero timeout means no timeout.
+type ReadOptions struct {
+	// LastUpdateTimeout bounds the read of the last update timestamp of a website.
+	LastUpdateTimeout time.Duration
+	// IndexTimeout bounds the build of the index of a website: its files, chunk counts, metadata and owner.
+	IndexTimeout time.Duration
+	// ReadTimeout bounds each read of file chunks or file metadata.
+	ReadTimeout time.Duration
+	// IndexMaxAge is the duration during which the index of a website is used without its version being confirmed.
+	IndexMaxAge time.Duration
+	// ChunkFetchWorkers is the maximum number of chunk batches of a file fetched concurrently.
+	ChunkFetchWorkers int
+	// VerificationMode is VerificationOff, VerificationWarn or VerificationFail.
+	VerificationMode string
+	// VerificationNodes is the number of other nodes of the pool than the one the data was read from
+	// which must return it identically for it to be verified.
+	VerificationNodes int
+}
+
+// DefaultReadOptions returns the read options used when a network has none.
+func DefaultReadOptions() ReadOptions {
+	return ReadOptions{
+		LastUpdateTimeout: DefaultLastUpdateTimeout,
+		IndexTimeout:      DefaultIndexTimeout,
+		ReadTimeout:       DefaultReadTimeout,
+		IndexMaxAge:       DefaultIndexMaxAge,
+		ChunkFetchWorkers: DefaultChunkFetchWorkers,
+		VerificationMode:  VerificationOff,
+		VerificationNodes: DefaultVerificationNodes,
+	}
+}
+
+// ReadOptions returns the read options of the network, or the default ones if it has none.
+func (n *NetworkInfos) ReadOptions() ReadOptions {
+	if n == nil || n.Reads == nil {
+		return DefaultReadOptions()
+	}
+
+	return *n.Reads
+}
+
+// WithTimeout returns a context done after timeout or when ctx is done.
+// If timeout is not positive, it is only done when ctx is done.
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
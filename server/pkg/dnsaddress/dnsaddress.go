@@ -0,0 +1,123 @@
+// Package dnsaddress encodes website addresses into DNS labels, so that websites can be served
+// from a subdomain without MNS name.
+//
+// Massa addresses are case-sensitive base58 strings while hostnames are case-insensitive,
+// so an address can't be used as a subdomain as is. The base58 payload of the address is encoded
+// in lowercase base32 instead, and prefixed with "as-". The resulting label is 63 characters long,
+// which is the maximum length of a DNS label.
+package dnsaddress
+
+import (
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	// Prefix starts every encoded smart contract address.
+	Prefix = "as-"
+
+	addressPrefix  = "AS"
+	maxLabelLength = 63
+	base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+)
+
+var (
+	ErrInvalidAddress = errors.New("invalid address")
+	ErrInvalidLabel   = errors.New("invalid encoded address")
+
+	encoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+)
+
+// Encode encodes a smart contract address into a DNS label.
+func Encode(address string) (string, error) {
+	payload, found := strings.CutPrefix(address, addressPrefix)
+	if !found {
+		return "", fmt.Errorf("%w: %s is not a smart contract address", ErrInvalidAddress, address)
+	}
+
+	decoded, err := decodeBase58(payload)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidAddress, err)
+	}
+
+	label := Prefix + encoding.EncodeToString(decoded)
+	if len(label) > maxLabelLength {
+		return "", fmt.Errorf("%w: %s is too long to be encoded in a DNS label", ErrInvalidAddress, address)
+	}
+
+	return label, nil
+}
+
+// Decode decodes a DNS label into the smart contract address it encodes.
+// The checksum of the address is not verified.
+func Decode(label string) (string, error) {
+	payload, found := strings.CutPrefix(strings.ToLower(label), Prefix)
+	if !found || payload == "" {
+		return "", fmt.Errorf("%w: %s", ErrInvalidLabel, label)
+	}
+
+	decoded, err := encoding.DecodeString(payload)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidLabel, err)
+	}
+
+	return addressPrefix + encodeBase58(decoded), nil
+}
+
+// IsEncoded returns true if the label looks like an encoded address.
+// MNS names can also start with the prefix, so the decoded address must be validated before being used.
+func IsEncoded(label string) bool {
+	return strings.HasPrefix(strings.ToLower(label), Prefix)
+}
+
+// decodeBase58 decodes a base58 string, keeping its leading zero bytes.
+func decodeBase58(input string) ([]byte, error) {
+	value := new(big.Int)
+	radix := big.NewInt(int64(len(base58Alphabet)))
+
+	for _, char := range input {
+		digit := strings.IndexRune(base58Alphabet, char)
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", char)
+		}
+
+		value.Mul(value, radix)
+		value.Add(value, big.NewInt(int64(digit)))
+	}
+
+	leadingZeros := len(input) - len(strings.TrimLeft(input, base58Alphabet[:1]))
+
+	return append(make([]byte, leadingZeros), value.Bytes()...), nil
+}
+
+// encodeBase58 encodes bytes in base58, keeping their leading zero bytes.
+func encodeBase58(input []byte) string {
+	value := new(big.Int).SetBytes(input)
+	radix := big.NewInt(int64(len(base58Alphabet)))
+	mod := new(big.Int)
+
+	var encoded []byte
+
+	for value.Sign() > 0 {
+		value.DivMod(value, radix, mod)
+		encoded = append(encoded, base58Alphabet[mod.Int64()])
+	}
+
+	for _, b := range input {
+		if b != 0 {
+			break
+		}
+
+		encoded = append(encoded, base58Alphabet[0])
+	}
+
+	// Digits were appended from the least significant one
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+
+	return string(encoded)
+}
@@ -0,0 +1,74 @@
+package dnsaddress
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var dnsLabelRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+func TestEncodeDecode(t *testing.T) {
+	addresses := []string{
+		"AS1q5hUfxLXNXLKsYQVXZLK7MPUZcWaNZZsK7e9QzqhGdAgLpUGT",
+		"AS12qKAVjU1nr66JSkQ6N4Lqu4iwuVc6rAbRTrxFoynPrPdP1sj3G",
+	}
+
+	for _, address := range addresses {
+		t.Run(address, func(t *testing.T) {
+			label, err := Encode(address)
+			if err != nil {
+				t.Fatalf("Failed to encode %s: %v", address, err)
+			}
+
+			if !dnsLabelRegex.MatchString(label) {
+				t.Errorf("%s is not a valid DNS label", label)
+			}
+
+			if !IsEncoded(label) {
+				t.Errorf("%s is not detected as an encoded address", label)
+			}
+
+			decoded, err := Decode(strings.ToUpper(label))
+			if err != nil {
+				t.Fatalf("Failed to decode %s: %v", label, err)
+			}
+
+			if decoded != address {
+				t.Errorf("Expected %s, but got %s", address, decoded)
+			}
+		})
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		address string
+	}{
+		{"User address", "AU12dG5xP1RDEB5ocdHkymNVvvSJmUL9BgHwCksDowqmGWxfpm93x"},
+		{"Invalid character", "AS1q5hUfxLXNXLKsYQVXZLK7MPUZcWaNZZsK7e9QzqhGdAgLpUG0"},
+		{"Too long", "AS" + strings.Repeat("z", 60)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Encode(tc.address); !errors.Is(err, ErrInvalidAddress) {
+				t.Errorf("Expected ErrInvalidAddress, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	testCases := []string{"mysite", "as-", "as-not!base32", "as-1"}
+
+	for _, label := range testCases {
+		t.Run(label, func(t *testing.T) {
+			if _, err := Decode(label); !errors.Is(err, ErrInvalidLabel) {
+				t.Errorf("Expected ErrInvalidLabel, but got %v", err)
+			}
+		})
+	}
+}
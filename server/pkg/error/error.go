@@ -1,9 +1,31 @@
 package error
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
 const (
 	ErrNetworkConfigCode = 1000
 )
 
+var (
+	// ErrNotFound is returned when a domain, website or resource does not exist on chain.
+	ErrNotFound = errors.New("not found")
+	// ErrNodeUnavailable is returned when the node could not be reached or failed to answer.
+	ErrNodeUnavailable = errors.New("node unavailable")
+	// ErrTimeout is returned when a node request timed out.
+	ErrTimeout = errors.New("node request timed out")
+	// ErrCorruptedChunk is returned when the data stored on chain is inconsistent.
+	ErrCorruptedChunk = errors.New("corrupted chunk")
+	// ErrNodeMismatch is returned when nodes return different data for the same datastore entries.
+	ErrNodeMismatch = errors.New("nodes returned different data")
+	// ErrUnverified is returned when data must be verified but not enough other nodes answered.
+	ErrUnverified = errors.New("not enough nodes to verify data")
+)
+
 type ServerError struct {
 	Message   string
 	ErrorCode int
@@ -16,3 +38,33 @@ func NewServerError(message string, errorCode int) *ServerError {
 func (e *ServerError) Error() string {
 	return e.Message
 }
+
+// NodeError wraps an error returned by a node call with ErrTimeout if the call timed out,
+// or with ErrNodeUnavailable otherwise. A call cancelled by the caller is returned as is, the node is not to blame,
+// as well as an error already wrapped by NodeError.
+func NodeError(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrNodeUnavailable) || errors.Is(err, ErrTimeout) {
+		return err
+	}
+
+	if IsTimeout(err) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+
+	return fmt.Errorf("%w: %w", ErrNodeUnavailable, err)
+}
+
+// IsTimeout returns true if the error was caused by a timeout.
+func IsTimeout(err error) bool {
+	var netErr net.Error
+
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// IsNetworkError returns true if the error was caused by a failure to reach the node or to get a valid response
+// from it, as opposed to an error returned by the node itself.
+func IsNetworkError(err error) bool {
+	var netErr net.Error
+
+	return errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) || errors.Is(err, ErrNodeUnavailable)
+}
@@ -0,0 +1,121 @@
+// Package headerpolicy filters the HTTP headers that websites set with their http-header metadata.
+//
+// Websites are served on the domain of the provider, so some headers must never be set by them:
+// hop-by-hop headers and framing headers would corrupt the response, and headers such as Set-Cookie or
+// Strict-Transport-Security would act on the provider domain rather than on the website only.
+// These headers are always rejected, and operators can restrict the allowed headers further.
+package headerpolicy
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+var (
+	ErrInvalidName  = errors.New("invalid header name")
+	ErrInvalidValue = errors.New("invalid header value")
+	ErrDenied       = errors.New("header not allowed")
+)
+
+// builtinDenylist holds the canonical names of the headers websites can never set.
+var builtinDenylist = map[string]struct{}{
+	// Hop-by-hop headers
+	"Connection":          {},
+	"Keep-Alive":          {},
+	"Proxy-Authenticate":  {},
+	"Proxy-Authorization": {},
+	"Proxy-Connection":    {},
+	"Te":                  {},
+	"Trailer":             {},
+	"Transfer-Encoding":   {},
+	"Upgrade":             {},
+	// Framing headers, set by the server
+	"Content-Length": {},
+	"Content-Range":  {},
+	"Date":           {},
+	// Headers acting on the provider domain
+	"Set-Cookie":                  {},
+	"Set-Cookie2":                 {},
+	"Strict-Transport-Security":   {},
+	"Public-Key-Pins":             {},
+	"Public-Key-Pins-Report-Only": {},
+	"Alt-Svc":                     {},
+	"Clear-Site-Data":             {},
+	"Service-Worker-Allowed":      {},
+	"Origin-Agent-Cluster":        {},
+}
+
+// Policy decides which headers websites can set.
+// The built-in denylist always applies.
+type Policy struct {
+	allow map[string]struct{}
+	deny  map[string]struct{}
+}
+
+// New creates a policy. If allow is not empty, websites can only set the headers it lists.
+// Websites can't set the headers listed in deny. Header names are case-insensitive.
+func New(allow, deny []string) *Policy {
+	return &Policy{
+		allow: canonicalSet(allow),
+		deny:  canonicalSet(deny),
+	}
+}
+
+// Filter returns the headers allowed by the policy.
+// The rejected headers are returned as an error joining the reason of each rejection.
+func (p *Policy) Filter(headers map[string]string) (map[string]string, error) {
+	filtered := make(map[string]string, len(headers))
+
+	var errs []error
+
+	for name, value := range headers {
+		if err := p.check(name, value); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		filtered[name] = value
+	}
+
+	return filtered, errors.Join(errs...)
+}
+
+// check returns an error if the header can't be set by websites.
+func (p *Policy) check(name, value string) error {
+	if !httpguts.ValidHeaderFieldName(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+
+	if !httpguts.ValidHeaderFieldValue(value) {
+		return fmt.Errorf("%w for %s: %q", ErrInvalidValue, name, value)
+	}
+
+	canonical := textproto.CanonicalMIMEHeaderKey(name)
+
+	if _, denied := builtinDenylist[canonical]; denied {
+		return fmt.Errorf("%w: %s", ErrDenied, name)
+	}
+
+	if _, denied := p.deny[canonical]; denied {
+		return fmt.Errorf("%w: %s", ErrDenied, name)
+	}
+
+	if _, allowed := p.allow[canonical]; len(p.allow) > 0 && !allowed {
+		return fmt.Errorf("%w: %s", ErrDenied, name)
+	}
+
+	return nil
+}
+
+// canonicalSet returns the set of the canonical header names.
+func canonicalSet(names []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		set[textproto.CanonicalMIMEHeaderKey(name)] = struct{}{}
+	}
+
+	return set
+}
@@ -0,0 +1,80 @@
+package headerpolicy
+
+import (
+	"errors"
+	"maps"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	testCases := []struct {
+		name        string
+		allow       []string
+		deny        []string
+		headers     map[string]string
+		expected    map[string]string
+		expectedErr error
+	}{
+		{
+			name:     "Allowed headers",
+			headers:  map[string]string{"Cache-Control": "max-age=60", "content-security-policy": "default-src 'self'"},
+			expected: map[string]string{"Cache-Control": "max-age=60", "content-security-policy": "default-src 'self'"},
+		},
+		{
+			name:        "Built-in denylist",
+			headers:     map[string]string{"set-cookie": "session=1", "Content-Length": "0", "Connection": "close", "X-Frame-Options": "DENY"},
+			expected:    map[string]string{"X-Frame-Options": "DENY"},
+			expectedErr: ErrDenied,
+		},
+		{
+			name:        "Headers acting on the provider domain",
+			headers:     map[string]string{"service-worker-allowed": "/", "Origin-Agent-Cluster": "?1", "Cache-Control": "no-cache"},
+			expected:    map[string]string{"Cache-Control": "no-cache"},
+			expectedErr: ErrDenied,
+		},
+		{
+			name:        "Operator denylist",
+			deny:        []string{"x-frame-options"},
+			headers:     map[string]string{"Cache-Control": "no-cache", "X-Frame-Options": "DENY"},
+			expected:    map[string]string{"Cache-Control": "no-cache"},
+			expectedErr: ErrDenied,
+		},
+		{
+			name:        "Operator allowlist",
+			allow:       []string{"Cache-Control", "Set-Cookie"},
+			headers:     map[string]string{"cache-control": "no-cache", "X-Frame-Options": "DENY", "Set-Cookie": "session=1"},
+			expected:    map[string]string{"cache-control": "no-cache"},
+			expectedErr: ErrDenied,
+		},
+		{
+			name:        "Invalid name",
+			headers:     map[string]string{"Bad Header": "value", "Cache-Control": "no-cache"},
+			expected:    map[string]string{"Cache-Control": "no-cache"},
+			expectedErr: ErrInvalidName,
+		},
+		{
+			name:        "Invalid value",
+			headers:     map[string]string{"X-Custom": "value\r\nSet-Cookie: session=1"},
+			expected:    map[string]string{},
+			expectedErr: ErrInvalidValue,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := New(tc.allow, tc.deny).Filter(tc.headers)
+
+			if tc.expectedErr == nil && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+
+			if tc.expectedErr != nil && !errors.Is(err, tc.expectedErr) {
+				t.Errorf("Expected error %v, got %v", tc.expectedErr, err)
+			}
+
+			if !maps.Equal(result, tc.expected) {
+				t.Errorf("Expected headers %v, got %v", tc.expected, result)
+			}
+		})
+	}
+}
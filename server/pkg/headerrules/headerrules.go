@@ -0,0 +1,157 @@
+// Package headerrules adds operator headers to the responses of websites.
+//
+// Rules are selected with globs on the website, matched against its MNS name or its address,
+// and on the path of the served resource. In globs, '*' matches any sequence of characters, including '/',
+// and '?' matches a single character.
+//
+// The headers of a rule are merged with the headers set by the website according to their mode:
+// they override them, they are only set if the website didn't set them, or their value is appended
+// to the website value. Rules are applied in order, so a later rule takes precedence over an earlier one.
+package headerrules
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// Mode is how a rule header is merged with the header set by the website.
+type Mode string
+
+const (
+	// ModeOverride replaces the header set by the website.
+	ModeOverride Mode = "override"
+	// ModeDefault sets the header only if the website didn't set it.
+	ModeDefault Mode = "default"
+	// ModeAppend appends the value to the header set by the website, separated by a comma.
+	// For Content-Security-Policy, this enforces the policy of the rule in addition to the one of the website.
+	ModeAppend Mode = "append"
+)
+
+var ErrInvalidHeader = errors.New("invalid header")
+
+// Header is a header added by a rule.
+type Header struct {
+	Name  string
+	Value string
+	Mode  Mode
+}
+
+// Rule adds headers to the resources of the matching websites.
+type Rule struct {
+	// Site is a glob matched against the MNS name and the address of the website. Empty matches all websites.
+	Site string
+	// Path is a glob matched against the path of the resource, starting with '/'. Empty matches all paths.
+	Path    string
+	Headers []Header
+}
+
+// Rules is an ordered list of rules.
+type Rules []Rule
+
+// Validate returns an error if a header of the rule has an invalid name, value or mode.
+func (r Rule) Validate() error {
+	for _, header := range r.Headers {
+		if !httpguts.ValidHeaderFieldName(header.Name) {
+			return fmt.Errorf("%w: name %q", ErrInvalidHeader, header.Name)
+		}
+
+		if !httpguts.ValidHeaderFieldValue(header.Value) {
+			return fmt.Errorf("%w: value %q of %s", ErrInvalidHeader, header.Value, header.Name)
+		}
+
+		switch header.Mode {
+		case ModeOverride, ModeDefault, ModeAppend:
+		default:
+			return fmt.Errorf("%w: mode %q of %s", ErrInvalidHeader, header.Mode, header.Name)
+		}
+	}
+
+	return nil
+}
+
+// Matches returns true if the rule applies to the resource at path of the website with the given name and address.
+func (r Rule) Matches(name, address, path string) bool {
+	siteMatches := r.Site == "" ||
+		(name != "" && match(strings.ToLower(r.Site), strings.ToLower(name))) ||
+		(address != "" && match(r.Site, address))
+
+	return siteMatches && (r.Path == "" || match(r.Path, path))
+}
+
+// Apply returns the website headers merged with the headers of the matching rules.
+// The website headers are left unchanged.
+func (rules Rules) Apply(headers map[string]string, name, address, path string) map[string]string {
+	merged := make(map[string]string, len(headers))
+	for key, value := range headers {
+		merged[key] = value
+	}
+
+	for _, rule := range rules {
+		if !rule.Matches(name, address, path) {
+			continue
+		}
+
+		for _, header := range rule.Headers {
+			mergeHeader(merged, header)
+		}
+	}
+
+	return merged
+}
+
+// mergeHeader merges a rule header into the headers, whose names can have any case.
+func mergeHeader(headers map[string]string, header Header) {
+	canonical := textproto.CanonicalMIMEHeaderKey(header.Name)
+
+	var values []string
+
+	for key, value := range headers {
+		if textproto.CanonicalMIMEHeaderKey(key) == canonical {
+			values = append(values, value)
+
+			delete(headers, key)
+		}
+	}
+
+	switch {
+	case len(values) == 0 || header.Mode == ModeOverride:
+		headers[canonical] = header.Value
+	case header.Mode == ModeDefault:
+		headers[canonical] = strings.Join(values, ", ")
+	case header.Mode == ModeAppend:
+		headers[canonical] = strings.Join(append(values, header.Value), ", ")
+	}
+}
+
+// match reports whether name matches the glob pattern.
+func match(pattern, name string) bool {
+	// Backtracking on the last star is enough, as a star matches any sequence
+	px, nx := 0, 0
+	starPx, starNx := -1, -1
+
+	for nx < len(name) {
+		switch {
+		case px < len(pattern) && pattern[px] == '*':
+			starPx, starNx = px, nx
+			px++
+		case px < len(pattern) && (pattern[px] == '?' || pattern[px] == name[nx]):
+			px++
+			nx++
+		case starPx >= 0:
+			starNx++
+			px, nx = starPx+1, starNx
+		default:
+			return false
+		}
+	}
+
+	for px < len(pattern) && pattern[px] == '*' {
+		px++
+	}
+
+	return px == len(pattern)
+}
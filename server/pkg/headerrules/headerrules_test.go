@@ -0,0 +1,163 @@
+package headerrules
+
+import (
+	"errors"
+	"maps"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	testCases := []struct {
+		pattern  string
+		name     string
+		expected bool
+	}{
+		{"*", "anything/at/all", true},
+		{"/docs/*", "/docs/guide/index.html", true},
+		{"/docs/*", "/blog/index.html", false},
+		{"*.html", "/index.html", true},
+		{"*.html", "/style.css", false},
+		{"blog*", "blog", true},
+		{"b?og", "blog", true},
+		{"b?og", "bloog", false},
+		{"*a*b", "xaxxb", true},
+		{"*a*b", "xaxxbc", false},
+		{"exact", "exact", true},
+		{"", "", true},
+	}
+
+	for _, tc := range testCases {
+		if result := match(tc.pattern, tc.name); result != tc.expected {
+			t.Errorf("match(%q, %q): expected %v, got %v", tc.pattern, tc.name, tc.expected, result)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	rules := Rules{
+		{
+			Headers: []Header{
+				{Name: "X-Content-Type-Options", Value: "nosniff", Mode: ModeOverride},
+				{Name: "Referrer-Policy", Value: "no-referrer", Mode: ModeDefault},
+				{Name: "Content-Security-Policy", Value: "frame-ancestors 'none'", Mode: ModeAppend},
+			},
+		},
+		{
+			Site: "shop*",
+			Path: "/api/*",
+			Headers: []Header{
+				{Name: "Access-Control-Allow-Origin", Value: "*", Mode: ModeOverride},
+			},
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		headers  map[string]string
+		site     string
+		path     string
+		expected map[string]string
+	}{
+		{
+			name:    "Website without headers",
+			headers: nil,
+			site:    "blog",
+			path:    "/index.html",
+			expected: map[string]string{
+				"X-Content-Type-Options":  "nosniff",
+				"Referrer-Policy":         "no-referrer",
+				"Content-Security-Policy": "frame-ancestors 'none'",
+			},
+		},
+		{
+			name: "Merged with website headers",
+			headers: map[string]string{
+				"x-content-type-options":  "none",
+				"referrer-policy":         "origin",
+				"content-security-policy": "default-src 'self'",
+				"Cache-Control":           "no-cache",
+			},
+			site: "blog",
+			path: "/index.html",
+			expected: map[string]string{
+				"X-Content-Type-Options":  "nosniff",
+				"Referrer-Policy":         "origin",
+				"Content-Security-Policy": "default-src 'self', frame-ancestors 'none'",
+				"Cache-Control":           "no-cache",
+			},
+		},
+		{
+			name:    "Site and path rule",
+			headers: nil,
+			site:    "shop",
+			path:    "/api/items.json",
+			expected: map[string]string{
+				"X-Content-Type-Options":      "nosniff",
+				"Referrer-Policy":             "no-referrer",
+				"Content-Security-Policy":     "frame-ancestors 'none'",
+				"Access-Control-Allow-Origin": "*",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := rules.Apply(tc.headers, tc.site, "AS1", tc.path)
+			if !maps.Equal(result, tc.expected) {
+				t.Errorf("Expected headers %v, got %v", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	testCases := []struct {
+		name     string
+		rule     Rule
+		site     string
+		address  string
+		path     string
+		expected bool
+	}{
+		{"Empty rule", Rule{}, "blog", "AS1", "/index.html", true},
+		{"Name glob", Rule{Site: "*.blog"}, "my.blog", "AS1", "/", true},
+		{"Name glob is case-insensitive", Rule{Site: "Blog"}, "blog", "AS1", "/", true},
+		{"Address glob", Rule{Site: "AS12*"}, "", "AS12abc", "/", true},
+		{"Other site", Rule{Site: "shop"}, "blog", "AS1", "/", false},
+		{"Other path", Rule{Path: "*.html"}, "blog", "AS1", "/style.css", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := tc.rule.Matches(tc.site, tc.address, tc.path); result != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		header  Header
+		wantErr bool
+	}{
+		{"Valid", Header{Name: "X-Frame-Options", Value: "DENY", Mode: ModeOverride}, false},
+		{"Invalid name", Header{Name: "X Frame", Value: "DENY", Mode: ModeOverride}, true},
+		{"Invalid value", Header{Name: "X-Frame-Options", Value: "DENY\r\n", Mode: ModeOverride}, true},
+		{"Invalid mode", Header{Name: "X-Frame-Options", Value: "DENY", Mode: "replace"}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Rule{Headers: []Header{tc.header}}.Validate()
+			if tc.wantErr != (err != nil) {
+				t.Errorf("Expected error %v, got %v", tc.wantErr, err)
+			}
+
+			if err != nil && !errors.Is(err, ErrInvalidHeader) {
+				t.Errorf("Expected ErrInvalidHeader, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+// Package hostresolver maps custom domains to the websites they serve.
+//
+// A custom domain is a host that is not a subdomain of the server domain, like docs.example.com.
+// It can be mapped to the MNS name or the address of a website with a static table, or by its owner with
+// a TXT record on the _deweb subdomain of the host:
+//
+//	_deweb.docs.example.com. 300 IN TXT "deweb=docs"
+package hostresolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/massalabs/station/pkg/logger"
+)
+
+const (
+	// TXTSubdomain is the subdomain of a custom domain holding its TXT record.
+	TXTSubdomain = "_deweb."
+	// TXTPrefix starts the value of the TXT record, followed by the MNS name or address of the website.
+	TXTPrefix = "deweb="
+
+	// DefaultDNSCacheSize is the default maximum number of hosts whose lookup result is cached.
+	DefaultDNSCacheSize = 1000
+
+	// dnsFailureCacheDuration is the duration for which failed lookups are cached,
+	// so that an unreachable DNS server is not queried for each request.
+	dnsFailureCacheDuration = 30 * time.Second
+)
+
+// ErrLookup is returned when the TXT record of a host could not be looked up.
+var ErrLookup = errors.New("TXT record lookup failed")
+
+// Resolver resolves a host to the MNS name or address of the website it serves.
+type Resolver interface {
+	// Resolve returns the website served on host, or false if the host is not mapped to a website.
+	// Lookups are given up when ctx is done.
+	Resolve(ctx context.Context, host string) (string, bool, error)
+}
+
+// NormalizeHost returns the host without port and trailing dot, in lowercase.
+func NormalizeHost(host string) string {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// Chain is a list of resolvers, tried in order until one of them maps the host.
+type Chain []Resolver
+
+func (c Chain) Resolve(ctx context.Context, host string) (string, bool, error) {
+	for _, resolver := range c {
+		website, found, err := resolver.Resolve(ctx, host)
+		if err != nil || found {
+			return website, found, err
+		}
+	}
+
+	return "", false, nil
+}
+
+// StaticResolver maps hosts to websites with a static table. Hosts are expected in lowercase.
+type StaticResolver map[string]string
+
+func (s StaticResolver) Resolve(_ context.Context, host string) (string, bool, error) {
+	website, found := s[NormalizeHost(host)]
+
+	return website, found, nil
+}
+
+// dnsResult is the cached result of a TXT record lookup.
+type dnsResult struct {
+	website string
+	found   bool
+}
+
+// DNSResolver maps hosts to websites with the TXT record of their _deweb subdomain.
+// Lookup results, including hosts without record, are cached to avoid a lookup for each request.
+// Failed lookups are cached for a shorter duration. Hosts under the server domain are never looked up.
+type DNSResolver struct {
+	resolver *net.Resolver
+	domain   string
+	// cache and failures are nil if lookups are not cached.
+	cache    *expirable.LRU[string, dnsResult]
+	failures *expirable.LRU[string, error]
+}
+
+// NewDNSResolver creates a resolver looking up TXT records with the given DNS resolver,
+// or the default one if nil. Hosts under domain, the server domain, are not looked up.
+// Lookup results are cached for ttl, they are not cached if it is not positive.
+func NewDNSResolver(resolver *net.Resolver, domain string, ttl time.Duration, size int) *DNSResolver {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	if size == 0 {
+		size = DefaultDNSCacheSize
+	}
+
+	dnsResolver := &DNSResolver{
+		resolver: resolver,
+		domain:   NormalizeHost(domain),
+	}
+
+	// The LRU never expires its entries with a zero TTL
+	if ttl > 0 {
+		dnsResolver.cache = expirable.NewLRU[string, dnsResult](size, nil, ttl)
+		dnsResolver.failures = expirable.NewLRU[string, error](size, nil, min(ttl, dnsFailureCacheDuration))
+	}
+
+	return dnsResolver
+}
+
+func (d *DNSResolver) Resolve(ctx context.Context, host string) (string, bool, error) {
+	host = NormalizeHost(host)
+
+	if d.domain != "" && (host == d.domain || strings.HasSuffix(host, "."+d.domain)) {
+		return "", false, nil
+	}
+
+	if d.cache != nil {
+		if result, ok := d.cache.Get(host); ok {
+			return result.website, result.found, nil
+		}
+
+		if err, ok := d.failures.Get(host); ok {
+			return "", false, err
+		}
+	}
+
+	records, err := d.resolver.LookupTXT(ctx, TXTSubdomain+host)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+			err = fmt.Errorf("%w for %s: %w", ErrLookup, host, err)
+
+			// A lookup cancelled by the caller says nothing about the DNS server
+			if d.failures != nil && !errors.Is(ctx.Err(), context.Canceled) {
+				d.failures.Add(host, err)
+			}
+
+			return "", false, err
+		}
+	}
+
+	result := dnsResult{}
+
+	for _, record := range records {
+		if website, found := strings.CutPrefix(strings.TrimSpace(record), TXTPrefix); found && website != "" {
+			result = dnsResult{website: website, found: true}
+			break
+		}
+	}
+
+	logger.Debugf("TXT record lookup of %s: %+v", host, result)
+
+	if d.cache != nil {
+		d.cache.Add(host, result)
+	}
+
+	return result.website, result.found, nil
+}
@@ -0,0 +1,265 @@
+package hostresolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// startDNSServer starts a local DNS server answering TXT queries from records.
+// It returns a resolver using it and the number of queries it received.
+func startDNSServer(t *testing.T, records map[string][]string) (*net.Resolver, *atomic.Int32) {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start DNS server: %v", err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	queries := &atomic.Int32{}
+
+	go func() {
+		buf := make([]byte, 512)
+
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			var query dnsmessage.Message
+			if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) != 1 {
+				continue
+			}
+
+			queries.Add(1)
+
+			answer := dnsAnswer(query, records)
+
+			response, err := answer.Pack()
+			if err != nil {
+				continue
+			}
+
+			_, _ = conn.WriteTo(response, addr)
+		}
+	}()
+
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+
+			return dialer.DialContext(ctx, "udp", conn.LocalAddr().String())
+		},
+	}
+
+	return resolver, queries
+}
+
+// dnsAnswer builds the response to a query, with a NXDOMAIN code if there is no record for the name,
+// or a SERVFAIL code if its records are nil.
+func dnsAnswer(query dnsmessage.Message, records map[string][]string) dnsmessage.Message {
+	question := query.Questions[0]
+	response := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: query.ID, Response: true, Authoritative: true, RCode: dnsmessage.RCodeNameError},
+		Questions: query.Questions,
+	}
+
+	values, ok := records[question.Name.String()]
+	if !ok {
+		return response
+	}
+
+	if values == nil {
+		response.RCode = dnsmessage.RCodeServerFailure
+
+		return response
+	}
+
+	response.RCode = dnsmessage.RCodeSuccess
+
+	if question.Type != dnsmessage.TypeTXT {
+		return response
+	}
+
+	for _, value := range values {
+		response.Answers = append(response.Answers, dnsmessage.Resource{
+			Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET, TTL: 300},
+			Body:   &dnsmessage.TXTResource{TXT: []string{value}},
+		})
+	}
+
+	return response
+}
+
+func TestDNSResolver(t *testing.T) {
+	dnsServer, queries := startDNSServer(t, map[string][]string{
+		"_deweb.docs.example.com.":  {"v=spf1 -all", "deweb=docs"},
+		"_deweb.other.example.com.": {"unrelated record"},
+	})
+
+	resolver := NewDNSResolver(dnsServer, "localhost", time.Minute, 0)
+
+	testCases := []struct {
+		name            string
+		host            string
+		expectedWebsite string
+		expectedFound   bool
+	}{
+		{"Mapped host", "docs.example.com", "docs", true},
+		{"Host with port and uppercase", "Docs.Example.com:8080", "docs", true},
+		{"Host without deweb record", "other.example.com", "", false},
+		{"Unknown host", "unknown.example.com", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			website, found, err := resolver.Resolve(context.Background(), tc.host)
+			if err != nil {
+				t.Fatalf("Failed to resolve %s: %v", tc.host, err)
+			}
+
+			if website != tc.expectedWebsite || found != tc.expectedFound {
+				t.Errorf("Expected (%q, %v), but got (%q, %v)", tc.expectedWebsite, tc.expectedFound, website, found)
+			}
+		})
+	}
+
+	queriesBefore := queries.Load()
+
+	for _, tc := range testCases {
+		if _, _, err := resolver.Resolve(context.Background(), tc.host); err != nil {
+			t.Fatalf("Failed to resolve %s: %v", tc.host, err)
+		}
+	}
+
+	if queries.Load() != queriesBefore {
+		t.Errorf("Expected cached results to be used, but %d queries were sent", queries.Load()-queriesBefore)
+	}
+}
+
+func TestChain(t *testing.T) {
+	dnsServer, _ := startDNSServer(t, map[string][]string{
+		"_deweb.docs.example.com.": {"deweb=docs-from-dns"},
+		"_deweb.blog.example.com.": {"deweb=blog"},
+	})
+
+	resolver := Chain{
+		StaticResolver{"docs.example.com": "docs"},
+		NewDNSResolver(dnsServer, "localhost", time.Minute, 0),
+	}
+
+	testCases := []struct {
+		host            string
+		expectedWebsite string
+		expectedFound   bool
+	}{
+		{"docs.example.com", "docs", true},
+		{"blog.example.com", "blog", true},
+		{"unknown.example.com", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.host, func(t *testing.T) {
+			website, found, err := resolver.Resolve(context.Background(), tc.host)
+			if err != nil {
+				t.Fatalf("Failed to resolve %s: %v", tc.host, err)
+			}
+
+			if website != tc.expectedWebsite || found != tc.expectedFound {
+				t.Errorf("Expected (%q, %v), but got (%q, %v)", tc.expectedWebsite, tc.expectedFound, website, found)
+			}
+		})
+	}
+}
+
+func TestDNSResolverFailures(t *testing.T) {
+	dnsServer, queries := startDNSServer(t, map[string][]string{
+		"_deweb.broken.example.com.": nil,
+		"_deweb.docs.deweb.example.": {"deweb=docs"},
+		"_deweb.docs.example.com.":   {"deweb=docs"},
+		"_deweb.deweb.example.":      {"deweb=docs"},
+	})
+
+	resolver := NewDNSResolver(dnsServer, "deweb.example", time.Minute, 0)
+
+	_, _, err := resolver.Resolve(context.Background(), "broken.example.com")
+	if !errors.Is(err, ErrLookup) {
+		t.Fatalf("Expected error %v, got %v", ErrLookup, err)
+	}
+
+	// Failed lookups are cached too
+	queriesBefore := queries.Load()
+
+	if _, _, err := resolver.Resolve(context.Background(), "broken.example.com"); !errors.Is(err, ErrLookup) {
+		t.Errorf("Expected cached error %v, got %v", ErrLookup, err)
+	}
+
+	if queries.Load() != queriesBefore {
+		t.Errorf("Expected the failed lookup to be cached, but %d queries were sent", queries.Load()-queriesBefore)
+	}
+
+	// Hosts under the server domain are not looked up
+	for _, host := range []string{"docs.deweb.example", "deweb.example"} {
+		website, found, err := resolver.Resolve(context.Background(), host)
+		if err != nil || found {
+			t.Errorf("Expected %s not to be mapped, got (%q, %v, %v)", host, website, found, err)
+		}
+	}
+
+	if queries.Load() != queriesBefore {
+		t.Errorf("Expected hosts under the server domain not to be looked up, but %d queries were sent", queries.Load()-queriesBefore)
+	}
+
+	// A lookup cancelled by the caller is not cached
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, _, err := resolver.Resolve(ctx, "docs.example.com"); err == nil {
+		t.Fatalf("Expected cancelled lookup to fail")
+	}
+
+	website, found, err := resolver.Resolve(context.Background(), "docs.example.com")
+	if err != nil || website != "docs" || !found {
+		t.Errorf("Expected docs.example.com to be mapped to docs, got (%q, %v, %v)", website, found, err)
+	}
+}
+
+func TestDNSResolverWithoutCache(t *testing.T) {
+	dnsServer, queries := startDNSServer(t, map[string][]string{
+		"_deweb.broken.example.com.": nil,
+		"_deweb.docs.example.com.":   {"deweb=docs"},
+	})
+
+	// A zero duration disables the caches instead of keeping the results forever
+	resolver := NewDNSResolver(dnsServer, "deweb.example", 0, 0)
+
+	var roundQueries []int32
+
+	for range 2 {
+		queriesBefore := queries.Load()
+
+		website, found, err := resolver.Resolve(context.Background(), "docs.example.com")
+		if err != nil || website != "docs" || !found {
+			t.Errorf("Expected docs.example.com to be mapped to docs, got (%q, %v, %v)", website, found, err)
+		}
+
+		if _, _, err := resolver.Resolve(context.Background(), "broken.example.com"); !errors.Is(err, ErrLookup) {
+			t.Errorf("Expected error %v, got %v", ErrLookup, err)
+		}
+
+		roundQueries = append(roundQueries, queries.Load()-queriesBefore)
+	}
+
+	if roundQueries[1] == 0 || roundQueries[1] != roundQueries[0] {
+		t.Errorf("Expected the lookups to be sent again, got %v queries", roundQueries)
+	}
+}
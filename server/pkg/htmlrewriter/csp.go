@@ -0,0 +1,142 @@
+package htmlrewriter
+
+import (
+	"slices"
+	"strings"
+)
+
+const (
+	defaultSrc   = "default-src"
+	unsafeInline = "'unsafe-inline'"
+	noneSource   = "'none'"
+)
+
+var (
+	scriptDirectives = []string{"script-src-elem", "script-src"}
+	styleDirectives  = []string{"style-src-elem", "style-src"}
+)
+
+// directive is a directive of a Content Security Policy.
+type directive struct {
+	name    string
+	sources []string
+}
+
+// AllowInCSP returns the Content Security Policy with the inline scripts and styles of the injections allowed.
+// The policy can hold several policies separated by commas, as in an HTTP header.
+func (r *Rewriter) AllowInCSP(policy string) string {
+	if len(r.scriptHashes) == 0 && len(r.styleHashes) == 0 {
+		return policy
+	}
+
+	policies := strings.Split(policy, ",")
+	for i, p := range policies {
+		directives := parseDirectives(p)
+		directives = allowHashes(directives, scriptDirectives, r.scriptHashes)
+		directives = allowHashes(directives, styleDirectives, r.styleHashes)
+		policies[i] = formatDirectives(directives)
+	}
+
+	return strings.Join(policies, ", ")
+}
+
+// allowHashes adds the hashes to the directives governing an element type.
+// If none of them is set, the element type is governed by the default-src directive, in which case
+// the first directive is added with the default sources and the hashes.
+func allowHashes(directives []directive, names []string, hashes []string) []directive {
+	if len(hashes) == 0 {
+		return directives
+	}
+
+	found := false
+
+	for i := range directives {
+		for _, name := range names {
+			if directives[i].name == name {
+				directives[i].sources = addHashes(directives[i].sources, hashes)
+				found = true
+			}
+		}
+	}
+
+	if found {
+		return directives
+	}
+
+	for _, d := range directives {
+		if d.name == defaultSrc {
+			sources := append([]string(nil), d.sources...)
+
+			return append(directives, directive{name: names[len(names)-1], sources: addHashes(sources, hashes)})
+		}
+	}
+
+	// Without any directive governing the element type, inline elements are allowed
+	return directives
+}
+
+// addHashes adds the hashes to a source list.
+// A source list allowing all inline elements is left unchanged, as hashes would make browsers ignore 'unsafe-inline'.
+func addHashes(sources []string, hashes []string) []string {
+	allowsInline := false
+	hasHashOrNonce := false
+
+	for _, source := range sources {
+		lower := strings.ToLower(source)
+
+		switch {
+		case lower == unsafeInline:
+			allowsInline = true
+		case strings.HasPrefix(lower, "'sha256-"), strings.HasPrefix(lower, "'sha384-"),
+			strings.HasPrefix(lower, "'sha512-"), strings.HasPrefix(lower, "'nonce-"):
+			hasHashOrNonce = true
+		}
+	}
+
+	if allowsInline && !hasHashOrNonce {
+		return sources
+	}
+
+	result := make([]string, 0, len(sources)+len(hashes))
+
+	// 'none' can't be combined with other sources
+	for _, source := range sources {
+		if strings.ToLower(source) != noneSource {
+			result = append(result, source)
+		}
+	}
+
+	for _, hash := range hashes {
+		if !slices.Contains(result, hash) {
+			result = append(result, hash)
+		}
+	}
+
+	return result
+}
+
+// parseDirectives parses the directives of a single policy.
+func parseDirectives(policy string) []directive {
+	var directives []directive
+
+	for _, part := range strings.Split(policy, ";") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+
+		directives = append(directives, directive{name: strings.ToLower(fields[0]), sources: fields[1:]})
+	}
+
+	return directives
+}
+
+// formatDirectives formats directives as a policy.
+func formatDirectives(directives []directive) string {
+	parts := make([]string, len(directives))
+	for i, d := range directives {
+		parts[i] = strings.Join(append([]string{d.name}, d.sources...), " ")
+	}
+
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,244 @@
+// Package htmlrewriter injects content into the HTML pages of websites.
+//
+// Pages are tokenized rather than searched for tags, so that tags in comments, scripts or attribute values
+// are not mistaken for elements, whatever their case. Tokens are written back as they were read,
+// so the rest of the page is left byte-for-byte unchanged.
+//
+// Injected inline scripts and styles would be blocked by a strict Content Security Policy.
+// The rewriter computes their hashes, which can be allowed in the policy of the page.
+// Hashes are used rather than nonces as the rewritten pages are cached and must be the same for every request.
+package htmlrewriter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Position is where an injection is inserted in a page.
+type Position int
+
+const (
+	// HeadEnd inserts the injection at the end of the head element.
+	HeadEnd Position = iota
+	// BodyStart inserts the injection at the start of the body element.
+	BodyStart
+	// BodyEnd inserts the injection at the end of the body element.
+	BodyEnd
+)
+
+// Injection is an HTML fragment to insert in pages.
+type Injection struct {
+	Position Position
+	HTML     []byte
+}
+
+// Rewriter inserts injections in pages.
+// Injections are inserted in the order they are given, at their position.
+type Rewriter struct {
+	injections   []Injection
+	scriptHashes []string
+	styleHashes  []string
+}
+
+// New creates a rewriter inserting the given injections.
+// The inline scripts and styles of the injections are hashed to be allowed in Content Security Policies.
+func New(injections ...Injection) (*Rewriter, error) {
+	rewriter := &Rewriter{injections: injections}
+
+	for _, injection := range injections {
+		scripts, styles, err := inlineHashes(injection.HTML)
+		if err != nil {
+			return nil, fmt.Errorf("hashing inline elements of injection: %w", err)
+		}
+
+		rewriter.scriptHashes = append(rewriter.scriptHashes, scripts...)
+		rewriter.styleHashes = append(rewriter.styleHashes, styles...)
+	}
+
+	return rewriter, nil
+}
+
+// Rewrite returns the page with the injections inserted.
+// The policies of Content-Security-Policy meta elements are updated to allow the injections.
+// If the page has no body element, the body injections are not inserted, and if it has no head nor body element,
+// the head injections are not inserted either. As the end tag of the body is optional, the body end injections
+// are inserted before the end of the html element, or at the end of the page.
+func (r *Rewriter) Rewrite(page []byte) ([]byte, error) {
+	var out bytes.Buffer
+
+	out.Grow(len(page) + r.injectionsSize())
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(page))
+	headDone := false
+	bodyDone := false
+	bodyEndDone := false
+
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			if errors.Is(tokenizer.Err(), io.EOF) {
+				if bodyDone && !bodyEndDone {
+					r.write(&out, BodyEnd)
+				}
+
+				return out.Bytes(), nil
+			}
+
+			return nil, fmt.Errorf("tokenizing page: %w", tokenizer.Err())
+		}
+
+		// Raw is only valid until the next call to Next, and must be read before Token
+		raw := tokenizer.Raw()
+
+		if tokenType != html.StartTagToken && tokenType != html.EndTagToken && tokenType != html.SelfClosingTagToken {
+			out.Write(raw)
+			continue
+		}
+
+		rawCopy := append([]byte(nil), raw...)
+		token := tokenizer.Token()
+
+		switch {
+		case token.DataAtom == atom.Head && tokenType == html.EndTagToken && !headDone:
+			r.write(&out, HeadEnd)
+
+			headDone = true
+		case token.DataAtom == atom.Body && tokenType == html.StartTagToken && !bodyDone:
+			// The head element can be implicit
+			if !headDone {
+				r.write(&out, HeadEnd)
+
+				headDone = true
+			}
+
+			out.Write(rawCopy)
+			r.write(&out, BodyStart)
+
+			bodyDone = true
+
+			continue
+		case (token.DataAtom == atom.Body || token.DataAtom == atom.Html) && tokenType == html.EndTagToken &&
+			bodyDone && !bodyEndDone:
+			r.write(&out, BodyEnd)
+
+			bodyEndDone = true
+		case token.DataAtom == atom.Meta && isCSPMeta(token):
+			out.WriteString(r.rewriteCSPMeta(token).String())
+
+			continue
+		}
+
+		out.Write(rawCopy)
+	}
+}
+
+// write writes the injections of the given position.
+func (r *Rewriter) write(out *bytes.Buffer, position Position) {
+	for _, injection := range r.injections {
+		if injection.Position == position {
+			out.Write(injection.HTML)
+		}
+	}
+}
+
+// injectionsSize returns the total size of the injections.
+func (r *Rewriter) injectionsSize() int {
+	size := 0
+	for _, injection := range r.injections {
+		size += len(injection.HTML)
+	}
+
+	return size
+}
+
+// inlineHashes returns the CSP hash sources of the inline scripts and styles of an HTML fragment.
+func inlineHashes(fragment []byte) ([]string, []string, error) {
+	var scripts, styles []string
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(fragment))
+
+	// inline is the element whose text is being read, if it is an inline script or style
+	inline := atom.Atom(0)
+
+	for {
+		tokenType := tokenizer.Next()
+
+		switch tokenType {
+		case html.ErrorToken:
+			if errors.Is(tokenizer.Err(), io.EOF) {
+				return scripts, styles, nil
+			}
+
+			return nil, nil, fmt.Errorf("tokenizing fragment: %w", tokenizer.Err())
+		case html.StartTagToken:
+			token := tokenizer.Token()
+			if token.DataAtom == atom.Style || (token.DataAtom == atom.Script && !hasAttribute(token, "src")) {
+				inline = token.DataAtom
+			}
+		case html.TextToken:
+			switch inline {
+			case atom.Script:
+				scripts = append(scripts, hashSource(tokenizer.Raw()))
+			case atom.Style:
+				styles = append(styles, hashSource(tokenizer.Raw()))
+			}
+
+			inline = 0
+		default:
+			inline = 0
+		}
+	}
+}
+
+// hashSource returns the CSP hash source of an inline script or style content.
+func hashSource(content []byte) string {
+	hash := sha256.Sum256(content)
+
+	return "'sha256-" + base64.StdEncoding.EncodeToString(hash[:]) + "'"
+}
+
+// hasAttribute returns true if the token has the given attribute.
+func hasAttribute(token html.Token, name string) bool {
+	for _, attr := range token.Attr {
+		if attr.Key == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isCSPMeta returns true if the meta token sets a Content Security Policy.
+func isCSPMeta(token html.Token) bool {
+	for _, attr := range token.Attr {
+		if attr.Key == "http-equiv" && strings.EqualFold(strings.TrimSpace(attr.Val), "Content-Security-Policy") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rewriteCSPMeta returns the meta token with its policy allowing the injections.
+func (r *Rewriter) rewriteCSPMeta(token html.Token) html.Token {
+	attrs := make([]html.Attribute, len(token.Attr))
+	copy(attrs, token.Attr)
+
+	for i, attr := range attrs {
+		if attr.Key == "content" {
+			attrs[i].Val = r.AllowInCSP(attr.Val)
+		}
+	}
+
+	token.Attr = attrs
+
+	return token
+}
@@ -0,0 +1,188 @@
+package htmlrewriter
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	testStyle  = "<style>.box{}</style>"
+	testBox    = "<div>box</div><script>run()</script>"
+	styleHash  = "'sha256-UuKzfCP0wMwNewFk2ZH/kHdu7JBJzY5ALQNmfFU24n4='"
+	scriptHash = "'sha256-AvyuiL0SD1mVY3NNxR+V2uo+lhk6RMFrytWmRt6CrJQ='"
+)
+
+func newTestRewriter(t *testing.T) *Rewriter {
+	t.Helper()
+
+	rewriter, err := New(
+		Injection{Position: HeadEnd, HTML: []byte(testStyle)},
+		Injection{Position: BodyStart, HTML: []byte(testBox)},
+	)
+	if err != nil {
+		t.Fatalf("Failed to create rewriter: %v", err)
+	}
+
+	return rewriter
+}
+
+func TestRewriteBodyEnd(t *testing.T) {
+	rewriter, err := New(Injection{Position: BodyEnd, HTML: []byte(testBox)})
+	if err != nil {
+		t.Fatalf("Failed to create rewriter: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		page     string
+		expected string
+	}{
+		{
+			name:     "Body end tag",
+			page:     "<html><body><p>hi</p></body></html>",
+			expected: "<html><body><p>hi</p>" + testBox + "</body></html>",
+		},
+		{
+			name:     "Implicit body end",
+			page:     "<html><body><p>hi</p></html>",
+			expected: "<html><body><p>hi</p>" + testBox + "</html>",
+		},
+		{
+			name:     "Implicit body and html end",
+			page:     "<body><p>hi</p>",
+			expected: "<body><p>hi</p>" + testBox,
+		},
+		{
+			name:     "Fragment without body",
+			page:     "<p>fragment</p>",
+			expected: "<p>fragment</p>",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := rewriter.Rewrite([]byte(tc.page))
+			if err != nil {
+				t.Fatalf("Failed to rewrite page: %v", err)
+			}
+
+			if string(result) != tc.expected {
+				t.Errorf("Unexpected result:\nExpected: %s\nGot:      %s", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	rewriter := newTestRewriter(t)
+
+	testCases := []struct {
+		name     string
+		page     string
+		expected string
+	}{
+		{
+			name:     "Simple page",
+			page:     "<html><head><title>t</title></head><body><p>hi</p></body></html>",
+			expected: "<html><head><title>t</title>" + testStyle + "</head><body>" + testBox + "<p>hi</p></body></html>",
+		},
+		{
+			name:     "Uppercase tags and attributes",
+			page:     "<HTML><HEAD></HEAD><BODY CLASS=\"main\"></BODY></HTML>",
+			expected: "<HTML><HEAD>" + testStyle + "</HEAD><BODY CLASS=\"main\">" + testBox + "</BODY></HTML>",
+		},
+		{
+			name:     "Tags in comments and scripts",
+			page:     "<head><!-- </head><body> --><script>let s = '</head><body>';</script></head><body></body>",
+			expected: "<head><!-- </head><body> --><script>let s = '</head><body>';</script>" + testStyle + "</head><body>" + testBox + "</body>",
+		},
+		{
+			name:     "Implicit head",
+			page:     "<!DOCTYPE html><body>content</body>",
+			expected: "<!DOCTYPE html>" + testStyle + "<body>" + testBox + "content</body>",
+		},
+		{
+			name:     "Fragment without head nor body",
+			page:     "<p>fragment</p>",
+			expected: "<p>fragment</p>",
+		},
+		{
+			name: "CSP meta element",
+			page: `<head><meta http-equiv="Content-Security-Policy" content="script-src 'self'"></head><body></body>`,
+			expected: `<head><meta http-equiv="Content-Security-Policy" content="script-src &#39;self&#39; ` +
+				strings.ReplaceAll(scriptHash, "'", "&#39;") + `">` + testStyle + "</head><body>" + testBox + "</body>",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := rewriter.Rewrite([]byte(tc.page))
+			if err != nil {
+				t.Fatalf("Failed to rewrite page: %v", err)
+			}
+
+			if string(result) != tc.expected {
+				t.Errorf("Unexpected result:\nExpected: %s\nGot:      %s", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestAllowInCSP(t *testing.T) {
+	rewriter := newTestRewriter(t)
+
+	testCases := []struct {
+		name     string
+		policy   string
+		expected string
+	}{
+		{
+			name:     "Script and style directives",
+			policy:   "script-src 'self'; style-src 'self' https://cdn.example.com",
+			expected: "script-src 'self' " + scriptHash + "; style-src 'self' https://cdn.example.com " + styleHash,
+		},
+		{
+			name:     "Default source only",
+			policy:   "default-src 'self'",
+			expected: "default-src 'self'; script-src 'self' " + scriptHash + "; style-src 'self' " + styleHash,
+		},
+		{
+			name:     "None source",
+			policy:   "default-src 'none'",
+			expected: "default-src 'none'; script-src " + scriptHash + "; style-src " + styleHash,
+		},
+		{
+			name:     "Unsafe inline is kept working",
+			policy:   "script-src 'self' 'unsafe-inline'; style-src 'unsafe-inline' 'nonce-abc'",
+			expected: "script-src 'self' 'unsafe-inline'; style-src 'unsafe-inline' 'nonce-abc' " + styleHash,
+		},
+		{
+			name:     "Unrelated directives",
+			policy:   "img-src *; frame-ancestors 'none'",
+			expected: "img-src *; frame-ancestors 'none'",
+		},
+		{
+			name:     "Element directives",
+			policy:   "script-src-elem 'self'; script-src 'self'",
+			expected: "script-src-elem 'self' " + scriptHash + "; script-src 'self' " + scriptHash,
+		},
+		{
+			name:     "Hashes already allowed",
+			policy:   "script-src 'self' " + scriptHash,
+			expected: "script-src 'self' " + scriptHash,
+		},
+		{
+			name:     "Several policies",
+			policy:   "script-src 'self', style-src 'none'",
+			expected: "script-src 'self' " + scriptHash + ", style-src " + styleHash,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := rewriter.AllowInCSP(tc.policy); result != tc.expected {
+				t.Errorf("Unexpected policy:\nExpected: %s\nGot:      %s", tc.expected, result)
+			}
+		})
+	}
+}
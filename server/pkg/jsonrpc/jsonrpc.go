@@ -0,0 +1,81 @@
+// Package jsonrpc calls the methods of the JSON-RPC API of a massa node which are not wrapped by station.
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	pkgErrors "github.com/massalabs/deweb-server/pkg/error"
+)
+
+// Request is a JSON-RPC request sent to the node.
+type Request struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      int    `json:"id"`
+	Method  string `json:"method"`
+	Params  []any  `json:"params"`
+}
+
+// Error is an error returned by the node itself, such as an unknown method or invalid params.
+// Another node would return the same, so it is not a sign that the node is unavailable.
+type Error struct {
+	Method  string
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s failed: %s (code %d)", e.Method, e.Message, e.Code)
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+// Call sends a JSON-RPC request to the node and decodes its result into result.
+// Failures to reach the node or to get a valid response from it are wrapped with pkgErrors.NodeError,
+// while errors returned by the node itself are returned as an *Error.
+func Call(ctx context.Context, client *http.Client, nodeURL, method string, params []any, result any) error {
+	body, err := json.Marshal(Request{JSONRPC: "2.0", ID: 1, Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s request: %w", method, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, nodeURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", method, err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return pkgErrors.NodeError(fmt.Errorf("failed to call %s: %w", method, err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return pkgErrors.NodeError(fmt.Errorf("%s returned status %s", method, resp.Status))
+	}
+
+	var rpcResp rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return pkgErrors.NodeError(fmt.Errorf("failed to decode %s response: %w", method, err))
+	}
+
+	if rpcResp.Error != nil {
+		rpcResp.Error.Method = method
+
+		return rpcResp.Error
+	}
+
+	if err := json.Unmarshal(rpcResp.Result, result); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", method, err)
+	}
+
+	return nil
+}
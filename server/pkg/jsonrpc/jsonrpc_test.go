@@ -0,0 +1,81 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pkgErrors "github.com/massalabs/deweb-server/pkg/error"
+)
+
+func TestCall(t *testing.T) {
+	var received Request
+
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"version":"MAIN.2.5"}}`))
+	}))
+	defer node.Close()
+
+	var status struct {
+		Version string `json:"version"`
+	}
+
+	if err := Call(context.Background(), node.Client(), node.URL, "get_status", []any{}, &status); err != nil {
+		t.Fatalf("Failed to call node: %v", err)
+	}
+
+	if received.Method != "get_status" || received.JSONRPC != "2.0" {
+		t.Errorf("Unexpected request: %+v", received)
+	}
+
+	if status.Version != "MAIN.2.5" {
+		t.Errorf("Expected version MAIN.2.5, got %s", status.Version)
+	}
+}
+
+func TestCallErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		response    string
+		unavailable bool
+	}{
+		{"RPC error", http.StatusOK, `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"internal error"}}`, false},
+		{"HTTP error", http.StatusBadGateway, ``, true},
+		{"Invalid response", http.StatusOK, `not json`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.response))
+			}))
+			defer node.Close()
+
+			var result any
+
+			err := Call(context.Background(), node.Client(), node.URL, "get_status", []any{}, &result)
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+
+			// Errors returned by the node itself don't mean it is unavailable
+			if errors.Is(err, pkgErrors.ErrNodeUnavailable) != tt.unavailable {
+				t.Errorf("Expected unavailable %v, got %v", tt.unavailable, err)
+			}
+
+			var rpcErr *Error
+			if errors.As(err, &rpcErr) == tt.unavailable {
+				t.Errorf("Expected a node error %v, got %v", !tt.unavailable, err)
+			}
+		})
+	}
+}
@@ -1,10 +1,12 @@
 package mns
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
 	msConfig "github.com/massalabs/deweb-server/pkg/config"
+	pkgErrors "github.com/massalabs/deweb-server/pkg/error"
 	"github.com/massalabs/station/pkg/convert"
 	"github.com/massalabs/station/pkg/logger"
 	"github.com/massalabs/station/pkg/node"
@@ -21,12 +23,15 @@ const (
 	dnsResolveMethod = "dnsResolve"
 	readOnlyCoins    = "0.1"
 	readOnlyFee      = "0.1"
+
+	// dataEntryNotFound is the error of a read only call reading a missing datastore entry,
+	// which is how the MNS contract fails to resolve a domain that is not registered.
+	dataEntryNotFound = "data entry not found"
 )
 
 // ResolveDomain resolves a domain name to its corresponding address.
-func ResolveDomain(network *msConfig.NetworkInfos, domain string) (string, error) {
-	client := node.NewClient(network.NodeURL)
-
+// It stops waiting for the node when ctx is done.
+func ResolveDomain(ctx context.Context, network *msConfig.NetworkInfos, domain string) (string, error) {
 	scAddress, err := GetSCAddress(network)
 	if err != nil {
 		return "", fmt.Errorf("could not get mns smart contract address: %w", err)
@@ -35,8 +40,18 @@ func ResolveDomain(network *msConfig.NetworkInfos, domain string) (string, error
 	params := convert.U32ToBytes(len(domain))
 	params = append(params, []byte(domain)...)
 
-	res, err := sendoperation.ReadOnlyCallSC(scAddress, dnsResolveMethod, params, readOnlyCoins, readOnlyFee, scAddress, client)
+	res, err := msConfig.NodeCall(ctx, network, func(client *node.Client) (*sendoperation.ReadOnlyCallResponse, error) {
+		return sendoperation.ReadOnlyCallSC(scAddress, dnsResolveMethod, params, readOnlyCoins, readOnlyFee, scAddress, client)
+	})
 	if err != nil {
+		if pkgErrors.IsNetworkError(err) || ctx.Err() != nil {
+			return "", fmt.Errorf("resolving domain %s: %w", domain, pkgErrors.NodeError(err))
+		}
+
+		if isDomainNotFound(err) {
+			return "", fmt.Errorf("resolving domain %s: %w: %w", domain, pkgErrors.ErrNotFound, err)
+		}
+
 		return "", fmt.Errorf("resolving domain %s: %w", domain, err)
 	}
 
@@ -45,11 +60,21 @@ func ResolveDomain(network *msConfig.NetworkInfos, domain string) (string, error
 		return "", fmt.Errorf("deserializing result: %w", err)
 	}
 
+	if resolvedDomain == "" {
+		return "", fmt.Errorf("domain %s %w", domain, pkgErrors.ErrNotFound)
+	}
+
 	logger.Debugf("Resolved domain %s to %s", domain, resolvedDomain)
 
 	return resolvedDomain, nil
 }
 
+// isDomainNotFound returns true if the read only call resolving a domain failed because the domain is not registered.
+// Other execution errors are not related to the domain.
+func isDomainNotFound(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), dataEntryNotFound)
+}
+
 // deserializeResult deserializes the result from the smart contract call.
 func deserializeResult(result []interface{}) (string, error) {
 	var target strings.Builder
@@ -68,12 +93,12 @@ func deserializeResult(result []interface{}) (string, error) {
 
 // GetSCAddress returns the smart contract address based on the network chain ID.
 func GetSCAddress(network *msConfig.NetworkInfos) (string, error) {
-	switch network.ChainID {
+	switch network.CurrentChainID() {
 	case mainnetChainID:
 		return MainnetAddress, nil
 	case buildnetChainID:
 		return BuildnetAddress, nil
 	default:
-		return "", fmt.Errorf("unsupported chain ID: %d", network.ChainID)
+		return "", fmt.Errorf("unsupported chain ID: %d", network.CurrentChainID())
 	}
 }
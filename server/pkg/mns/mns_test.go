@@ -1,6 +1,7 @@
 package mns
 
 import (
+	"errors"
 	"testing"
 
 	msConfig "github.com/massalabs/deweb-server/pkg/config"
@@ -105,3 +106,35 @@ func TestDeserializeResult(t *testing.T) {
 		})
 	}
 }
+
+func TestIsDomainNotFound(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{
+			name:     "Domain not registered",
+			err:      errors.New("readonly call failed: VM Error in ReadOnlyExecutionTarget::FunctionCall context: Runtime error: data entry not found"),
+			expected: true,
+		},
+		{
+			name:     "Out of gas",
+			err:      errors.New("readonly call failed: VM Error: Not enough gas, limit reached"),
+			expected: false,
+		},
+		{
+			name:     "Missing function",
+			err:      errors.New("readonly call failed: Missing export dnsResolve"),
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if found := isDomainNotFound(tc.err); found != tc.expected {
+				t.Errorf("Expected %v, but got %v", tc.expected, found)
+			}
+		})
+	}
+}
@@ -0,0 +1,554 @@
+// Package nodepool spreads the calls to the node over several endpoints of the same network.
+// Endpoints are checked periodically, calls go to the fastest available one and are retried on the other ones
+// when it can't be reached. An endpoint failing repeatedly is skipped for a while (circuit breaker),
+// and calls to an endpoint can be rate limited.
+package nodepool
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	pkgErrors "github.com/massalabs/deweb-server/pkg/error"
+	"github.com/massalabs/deweb-server/pkg/jsonrpc"
+	"github.com/massalabs/station/pkg/logger"
+	"github.com/massalabs/station/pkg/node"
+)
+
+const (
+	DefaultMaxRetries          = 2
+	DefaultRetryBackoff        = 100 * time.Millisecond
+	DefaultFailureThreshold    = 3
+	DefaultOpenDuration        = 30 * time.Second
+	DefaultHealthCheckInterval = 10 * time.Second
+
+	// latencyWeight is the weight of the last call duration in the latency average of an endpoint.
+	latencyWeight = 0.2
+)
+
+// ErrNoEndpoint is returned when a pool is created without endpoint.
+var ErrNoEndpoint = errors.New("no node endpoint")
+
+// ErrChainMismatch is returned when the endpoints of a pool don't report the same chain ID.
+var ErrChainMismatch = errors.New("nodes report different chain IDs")
+
+// Options configures the retries and the circuit breaker of a pool.
+type Options struct {
+	// MaxRetries is the number of times a call is retried on another endpoint when the node can't be reached.
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry, it doubles for each following retry.
+	RetryBackoff time.Duration
+	// FailureThreshold is the number of consecutive failures after which an endpoint is skipped.
+	FailureThreshold int
+	// OpenDuration is the duration during which a failing endpoint is skipped.
+	OpenDuration time.Duration
+	// RateLimit is the maximum number of calls per second to each endpoint, 0 means unlimited.
+	RateLimit int
+	// ChainID is the chain ID the endpoints must report. If it is 0, the first reported chain ID is expected.
+	ChainID uint64
+}
+
+// DefaultOptions returns the default options of a pool.
+func DefaultOptions() Options {
+	return Options{
+		MaxRetries:       DefaultMaxRetries,
+		RetryBackoff:     DefaultRetryBackoff,
+		FailureThreshold: DefaultFailureThreshold,
+		OpenDuration:     DefaultOpenDuration,
+	}
+}
+
+// endpoint holds the state of a node endpoint.
+type endpoint struct {
+	url    string
+	client *node.Client
+	// latency is the moving average of the call durations, it is zero until the first successful call.
+	latency time.Duration
+	// failures is the number of consecutive calls which could not reach the node.
+	failures int
+	// openUntil is the time until which the endpoint is skipped.
+	openUntil time.Time
+	// wrongChain is true if the node reported another chain ID than the other endpoints.
+	wrongChain bool
+	// nextCall is the time from which the next call is allowed by the rate limit.
+	nextCall time.Time
+}
+
+// Pool selects the node endpoint used for each call.
+type Pool struct {
+	mu        sync.Mutex
+	endpoints []*endpoint
+	opts      Options
+	// chainID is the chain ID expected from the nodes, it is zero until a node was checked if none is configured.
+	chainID uint64
+	// status returns the status of a node, it is Status outside tests.
+	status func(ctx context.Context, nodeURL string) (*node.State, error)
+	// sleep waits before a retry or until ctx is done, it is sleepContext outside tests.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// New creates a pool over the given node URLs.
+func New(urls []string, opts Options) (*Pool, error) {
+	if len(urls) == 0 {
+		return nil, ErrNoEndpoint
+	}
+
+	endpoints := make([]*endpoint, len(urls))
+	for i, url := range urls {
+		endpoints[i] = &endpoint{url: url, client: node.NewClient(url)}
+	}
+
+	return &Pool{
+		endpoints: endpoints,
+		opts:      opts,
+		chainID:   opts.ChainID,
+		status:    Status,
+		sleep:     sleepContext,
+	}, nil
+}
+
+// URLs returns the URLs of the endpoints of the pool.
+func (p *Pool) URLs() []string {
+	urls := make([]string, len(p.endpoints))
+	for i, e := range p.endpoints {
+		urls[i] = e.url
+	}
+
+	return urls
+}
+
+// Node is an endpoint of a pool.
+type Node struct {
+	URL    string
+	Client *node.Client
+}
+
+// Available returns up to n endpoints which are not skipped, the fastest first.
+// The endpoints with an excluded URL are not returned.
+func (p *Pool) Available(n int, excluded ...string) []Node {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+
+	available := make([]*endpoint, 0, len(p.endpoints))
+
+	for _, e := range p.endpoints {
+		if !e.wrongChain && !now.Before(e.openUntil) && !slices.Contains(excluded, e.url) {
+			available = append(available, e)
+		}
+	}
+
+	slices.SortStableFunc(available, func(a, b *endpoint) int {
+		return cmp.Compare(a.latency, b.latency)
+	})
+
+	nodes := make([]Node, 0, min(n, len(available)))
+	for _, e := range available[:min(n, len(available))] {
+		nodes = append(nodes, Node{URL: e.url, Client: e.client})
+	}
+
+	return nodes
+}
+
+// Do calls fn with a client of the best available endpoint. If the node can't be reached,
+// the call is retried on the other endpoints with an exponential backoff.
+// Errors returned by the node itself are returned as is, as another node would return the same.
+// No call is started once ctx is done, fn is expected to return when ctx is done, see WithContext.
+func (p *Pool) Do(ctx context.Context, fn func(client *node.Client) error) error {
+	return p.do(ctx, func(e *endpoint) error {
+		return fn(e.client)
+	})
+}
+
+// DoURL calls fn with the URL of the best available endpoint, see Do.
+func (p *Pool) DoURL(ctx context.Context, fn func(nodeURL string) error) error {
+	return p.do(ctx, func(e *endpoint) error {
+		return fn(e.url)
+	})
+}
+
+// DoNodeURL calls fn with the URL of a given node of the pool, such as one returned by Available.
+// Unlike DoURL, the call is not retried on another endpoint, for the callers comparing the answers of several nodes.
+// It is rate limited and its outcome is recorded like the other calls.
+func (p *Pool) DoNodeURL(ctx context.Context, nodeURL string, fn func(nodeURL string) error) error {
+	i := slices.IndexFunc(p.endpoints, func(e *endpoint) bool {
+		return e.url == nodeURL
+	})
+	if i < 0 {
+		return fmt.Errorf("%w: %s is not in the pool", pkgErrors.ErrNodeUnavailable, nodeURL)
+	}
+
+	return p.call(ctx, p.endpoints[i], func(e *endpoint) error {
+		return fn(e.url)
+	})
+}
+
+// Call calls fn with a client of the best available endpoint of the pool and returns its result, see Pool.Do.
+// The caller stops waiting for fn when ctx is done.
+func Call[T any](ctx context.Context, p *Pool, fn func(client *node.Client) (T, error)) (T, error) {
+	var result T
+
+	err := p.Do(ctx, func(client *node.Client) error {
+		var err error
+
+		result, err = WithContext(ctx, func() (T, error) {
+			return fn(client)
+		})
+
+		return err
+	})
+
+	return result, err
+}
+
+// Status gets the status of the node through a get_status call, which is cancelled when ctx is done.
+func Status(ctx context.Context, nodeURL string) (*node.State, error) {
+	var status struct {
+		Version *string `json:"version"`
+		ChainID *int    `json:"chain_id"`
+	}
+
+	if err := jsonrpc.Call(ctx, http.DefaultClient, nodeURL, "get_status", []any{}, &status); err != nil {
+		return nil, err
+	}
+
+	return &node.State{Version: status.Version, ChainID: status.ChainID}, nil
+}
+
+// WithContext calls fn and returns its result, or the error of ctx if it is done first.
+// The node client can't be cancelled, so a call given up this way completes in the background
+// and its result is dropped.
+func WithContext[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	var zero T
+
+	if err := ctx.Err(); err != nil {
+		return zero, context.Cause(ctx)
+	}
+
+	if ctx.Done() == nil {
+		return fn()
+	}
+
+	type result struct {
+		value T
+		err   error
+	}
+
+	results := make(chan result, 1)
+
+	go func() {
+		value, err := fn()
+		results <- result{value: value, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return zero, context.Cause(ctx)
+	case r := <-results:
+		return r.value, r.err
+	}
+}
+
+func (p *Pool) do(ctx context.Context, fn func(e *endpoint) error) error {
+	tried := make(map[*endpoint]bool, len(p.endpoints))
+
+	var err error
+
+	for attempt := range p.opts.MaxRetries + 1 {
+		if attempt > 0 {
+			if sleepErr := p.sleep(ctx, p.opts.RetryBackoff<<(attempt-1)); sleepErr != nil {
+				return errors.Join(err, sleepErr)
+			}
+		}
+
+		e, pickErr := p.pick(tried)
+		if pickErr != nil {
+			return pickErr
+		}
+
+		tried[e] = true
+
+		err = p.call(ctx, e, fn)
+		if ctx.Err() != nil || !pkgErrors.IsNetworkError(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// call calls fn with the endpoint within its rate limit, and records whether the node could be reached.
+func (p *Pool) call(ctx context.Context, e *endpoint, fn func(e *endpoint) error) error {
+	if wait := p.reserve(e); wait > 0 {
+		if err := p.sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+
+	start := time.Now()
+
+	err := fn(e)
+
+	// The caller gave up, the endpoint is not to blame
+	if ctx.Err() != nil {
+		return err
+	}
+
+	if !pkgErrors.IsNetworkError(err) {
+		p.succeeded(e, time.Since(start))
+	} else {
+		p.failed(e, err)
+	}
+
+	return err
+}
+
+// sleepContext waits for d, or returns the error of ctx if it is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-timer.C:
+		return nil
+	}
+}
+
+// pick returns the available endpoint not tried yet with the lowest latency.
+// Endpoints without known latency are tried first, so that their latency gets known.
+// If there is none, the one which will be available first is returned.
+// It returns an error wrapping ErrChainMismatch if every endpoint reports another chain ID than the expected one,
+// as the data of another network must never be served.
+func (p *Pool) pick(tried map[*endpoint]bool) (*endpoint, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+
+	var best, fallback *endpoint
+
+	for _, e := range p.endpoints {
+		if e.wrongChain {
+			continue
+		}
+
+		if fallback == nil || e.openUntil.Before(fallback.openUntil) {
+			fallback = e
+		}
+
+		if now.Before(e.openUntil) || tried[e] {
+			continue
+		}
+
+		if best == nil || e.latency < best.latency {
+			best = e
+		}
+	}
+
+	switch {
+	case best != nil:
+		return best, nil
+	case fallback != nil:
+		return fallback, nil
+	default:
+		return nil, fmt.Errorf("%w: %w: no node on chain %d", pkgErrors.ErrNodeUnavailable, ErrChainMismatch, p.chainID)
+	}
+}
+
+// reserve reserves a call to an endpoint within its rate limit, and returns how long to wait before calling it.
+func (p *Pool) reserve(e *endpoint) time.Duration {
+	if p.opts.RateLimit <= 0 {
+		return 0
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+
+	callTime := e.nextCall
+	if callTime.Before(now) {
+		callTime = now
+	}
+
+	e.nextCall = callTime.Add(time.Second / time.Duration(p.opts.RateLimit))
+
+	return callTime.Sub(now)
+}
+
+// succeeded records a call which reached the node.
+func (p *Pool) succeeded(e *endpoint, duration time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if e.latency == 0 {
+		e.latency = duration
+	} else {
+		e.latency = time.Duration(latencyWeight*float64(duration) + (1-latencyWeight)*float64(e.latency))
+	}
+
+	if !e.openUntil.IsZero() {
+		logger.Infof("Node %s is reachable again", e.url)
+	}
+
+	e.failures = 0
+	e.openUntil = time.Time{}
+}
+
+// failed records a call which could not reach the node, and skips the endpoint if it failed too many times.
+func (p *Pool) failed(e *endpoint, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e.failures++
+
+	if e.failures >= p.opts.FailureThreshold {
+		if e.openUntil.IsZero() {
+			logger.Warnf("Node %s failed %d times, skipping it for %v: %v", e.url, e.failures, p.opts.OpenDuration, err)
+		}
+
+		e.openUntil = time.Now().Add(p.opts.OpenDuration)
+	}
+}
+
+// Check gets the status of every endpoint, to measure their latency and detect the unreachable ones.
+// Endpoints which don't answer before ctx is done are considered unreachable.
+// It returns an error if no node can be reached, or if the nodes report different chain IDs.
+// Endpoints reporting another chain ID than the expected one are not used anymore.
+func (p *Pool) Check(ctx context.Context) error {
+	type result struct {
+		chainID  uint64
+		duration time.Duration
+		err      error
+	}
+
+	results := make([]result, len(p.endpoints))
+
+	var wg sync.WaitGroup
+
+	for i, e := range p.endpoints {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			start := time.Now()
+
+			status, err := p.status(ctx, e.url)
+			if err == nil && status.ChainID == nil {
+				err = errors.New("node status has no chain ID")
+			}
+
+			if err != nil {
+				results[i] = result{err: err}
+				return
+			}
+
+			results[i] = result{chainID: uint64(*status.ChainID), duration: time.Since(start)}
+		}()
+	}
+
+	wg.Wait()
+
+	var errs []error
+
+	var mismatches []string
+
+	for i, e := range p.endpoints {
+		r := results[i]
+		if r.err != nil {
+			p.failed(e, r.err)
+			errs = append(errs, fmt.Errorf("node %s: %w", e.url, r.err))
+
+			continue
+		}
+
+		if !p.setChainID(e, r.chainID) {
+			mismatches = append(mismatches, fmt.Sprintf("%s reports %d", e.url, r.chainID))
+
+			continue
+		}
+
+		p.succeeded(e, r.duration)
+	}
+
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%w: expected %d, %s", ErrChainMismatch, p.ChainID(), strings.Join(mismatches, ", "))
+	}
+
+	if len(errs) == len(p.endpoints) {
+		return pkgErrors.NodeError(errors.Join(errs...))
+	}
+
+	for _, err := range errs {
+		logger.Warnf("Node health check failed: %v", err)
+	}
+
+	return nil
+}
+
+// setChainID records the chain ID reported by an endpoint. Endpoints reporting another one than the configured
+// chain ID are excluded. If none is configured, the first reported chain ID is the one of the pool. It returns false if the endpoint is excluded.
+func (p *Pool) setChainID(e *endpoint, chainID uint64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.chainID == 0 {
+		p.chainID = chainID
+	}
+
+	e.wrongChain = chainID != p.chainID
+
+	return !e.wrongChain
+}
+
+// ChainID returns the chain ID expected from the nodes, it is zero until they are checked if none is configured.
+func (p *Pool) ChainID() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.chainID
+}
+
+// Run checks the endpoints at the given interval until ctx is done.
+// Each check is given up after timeout, if it is not 0.
+func (p *Pool) Run(ctx context.Context, interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.checkWithin(ctx, timeout)
+		}
+	}
+}
+
+// checkWithin checks the endpoints, giving up after timeout if it is not 0.
+func (p *Pool) checkWithin(ctx context.Context, timeout time.Duration) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if err := p.Check(ctx); err != nil {
+		logger.Errorf("Node health check failed: %v", err)
+	}
+}
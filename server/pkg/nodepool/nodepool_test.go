@@ -0,0 +1,404 @@
+package nodepool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	pkgErrors "github.com/massalabs/deweb-server/pkg/error"
+	"github.com/massalabs/station/pkg/node"
+)
+
+var errUnreachable = &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+func newTestPool(t *testing.T, urls []string, opts Options) *Pool {
+	t.Helper()
+
+	pool, err := New(urls, opts)
+	if err != nil {
+		t.Fatalf("Failed to create pool: %v", err)
+	}
+
+	pool.sleep = func(context.Context, time.Duration) error { return nil }
+
+	return pool
+}
+
+// callURLs calls the pool once with fn and returns the URLs it was called with.
+func callURLs(pool *Pool, fn func(nodeURL string) error) ([]string, error) {
+	var urls []string
+
+	err := pool.DoURL(context.Background(), func(nodeURL string) error {
+		urls = append(urls, nodeURL)
+
+		return fn(nodeURL)
+	})
+
+	return urls, err
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(nil, DefaultOptions()); !errors.Is(err, ErrNoEndpoint) {
+		t.Errorf("Expected ErrNoEndpoint, got %v", err)
+	}
+}
+
+func TestDo(t *testing.T) {
+	nodeErr := errors.New("execution error")
+
+	testCases := []struct {
+		name        string
+		unreachable []string
+		callErr     error
+		expected    []string
+		expectedErr error
+	}{
+		{
+			name:     "First node answers",
+			expected: []string{"node1"},
+		},
+		{
+			name:        "Failover to the second node",
+			unreachable: []string{"node1"},
+			expected:    []string{"node1", "node2"},
+		},
+		{
+			name:        "No node answers",
+			unreachable: []string{"node1", "node2"},
+			expected:    []string{"node1", "node2", "node1"},
+			expectedErr: errUnreachable,
+		},
+		{
+			name:        "Errors of the node are not retried",
+			callErr:     nodeErr,
+			expected:    []string{"node1"},
+			expectedErr: nodeErr,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pool := newTestPool(t, []string{"node1", "node2"}, Options{MaxRetries: 2, FailureThreshold: 10})
+
+			urls, err := callURLs(pool, func(nodeURL string) error {
+				if slices.Contains(tc.unreachable, nodeURL) {
+					return errUnreachable
+				}
+
+				return tc.callErr
+			})
+			if !errors.Is(err, tc.expectedErr) {
+				t.Errorf("Expected error %v, got %v", tc.expectedErr, err)
+			}
+
+			if !slices.Equal(urls, tc.expected) {
+				t.Errorf("Expected calls to %v, got %v", tc.expected, urls)
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	pool := newTestPool(t, []string{"node1"}, Options{MaxRetries: 3, RetryBackoff: 100 * time.Millisecond, FailureThreshold: 10})
+
+	var delays []time.Duration
+
+	pool.sleep = func(_ context.Context, d time.Duration) error {
+		delays = append(delays, d)
+
+		return nil
+	}
+
+	_, _ = callURLs(pool, func(string) error { return errUnreachable })
+
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}
+	if !slices.Equal(delays, expected) {
+		t.Errorf("Expected delays %v, got %v", expected, delays)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	pool := newTestPool(t, []string{"node1", "node2"}, Options{FailureThreshold: 2, OpenDuration: time.Hour})
+
+	down := true
+	fn := func(nodeURL string) error {
+		if nodeURL == "node1" && down {
+			return errUnreachable
+		}
+
+		return nil
+	}
+
+	// Without retries, calls fail until the circuit of the failing node opens
+	for range 2 {
+		if _, err := callURLs(pool, fn); err == nil {
+			t.Fatalf("Expected node1 to be called and fail")
+		}
+	}
+
+	urls, err := callURLs(pool, fn)
+	if err != nil || !slices.Equal(urls, []string{"node2"}) {
+		t.Errorf("Expected node1 to be skipped, got calls to %v, %v", urls, err)
+	}
+
+	// The node is used again once it answers the health check
+	down = false
+	pool.status = func(context.Context, string) (*node.State, error) {
+		chainID := 77658377
+
+		return &node.State{ChainID: &chainID}, nil
+	}
+
+	if err := pool.Check(context.Background()); err != nil {
+		t.Fatalf("Failed to check nodes: %v", err)
+	}
+
+	pool.endpoints[1].latency = time.Second
+
+	urls, _ = callURLs(pool, fn)
+	if !slices.Equal(urls, []string{"node1"}) {
+		t.Errorf("Expected node1 to be used again, got calls to %v", urls)
+	}
+}
+
+func TestPickLowestLatency(t *testing.T) {
+	pool := newTestPool(t, []string{"node1", "node2", "node3"}, DefaultOptions())
+
+	pool.endpoints[0].latency = 300 * time.Millisecond
+	pool.endpoints[1].latency = 50 * time.Millisecond
+	pool.endpoints[2].latency = 100 * time.Millisecond
+
+	urls, _ := callURLs(pool, func(string) error { return nil })
+	if !slices.Equal(urls, []string{"node2"}) {
+		t.Errorf("Expected the fastest node to be used, got calls to %v", urls)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	mainnet := 77658377
+	buildnet := 77658366
+
+	testCases := []struct {
+		name        string
+		chainIDs    map[string]*int
+		chainID     uint64
+		expectedErr error
+		excluded    []string
+	}{
+		{
+			name:     "Same chain",
+			chainIDs: map[string]*int{"node1": &mainnet, "node2": &mainnet},
+		},
+		{
+			name:     "Unreachable node",
+			chainIDs: map[string]*int{"node1": &mainnet, "node2": nil},
+		},
+		{
+			name:        "No node reachable",
+			chainIDs:    map[string]*int{"node1": nil, "node2": nil},
+			expectedErr: errUnreachable,
+		},
+		{
+			name:        "Different chains",
+			chainIDs:    map[string]*int{"node1": &mainnet, "node2": &buildnet},
+			expectedErr: ErrChainMismatch,
+			excluded:    []string{"node2"},
+		},
+		{
+			name:        "Main node on another chain than configured",
+			chainIDs:    map[string]*int{"node1": &buildnet, "node2": &mainnet},
+			chainID:     uint64(mainnet),
+			expectedErr: ErrChainMismatch,
+			excluded:    []string{"node1"},
+		},
+		{
+			name:        "Only a node on another chain than configured reachable",
+			chainIDs:    map[string]*int{"node1": nil, "node2": &buildnet},
+			chainID:     uint64(mainnet),
+			expectedErr: ErrChainMismatch,
+			excluded:    []string{"node2"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.ChainID = tc.chainID
+
+			pool := newTestPool(t, []string{"node1", "node2"}, opts)
+
+			pool.status = func(_ context.Context, nodeURL string) (*node.State, error) {
+				chainID := tc.chainIDs[nodeURL]
+				if chainID == nil {
+					return nil, errUnreachable
+				}
+
+				return &node.State{ChainID: chainID}, nil
+			}
+
+			err := pool.Check(context.Background())
+			if !errors.Is(err, tc.expectedErr) {
+				t.Errorf("Expected error %v, got %v", tc.expectedErr, err)
+			}
+
+			for _, e := range pool.endpoints {
+				if e.wrongChain != slices.Contains(tc.excluded, e.url) {
+					t.Errorf("Unexpected exclusion of %s: %v", e.url, e.wrongChain)
+				}
+			}
+		})
+	}
+}
+
+func TestDoWrongChain(t *testing.T) {
+	mainnet := 77658377
+	buildnet := 77658366
+
+	opts := DefaultOptions()
+	opts.ChainID = uint64(mainnet)
+
+	pool := newTestPool(t, []string{"node1", "node2"}, opts)
+
+	pool.status = func(context.Context, string) (*node.State, error) {
+		return &node.State{ChainID: &buildnet}, nil
+	}
+
+	if err := pool.Check(context.Background()); !errors.Is(err, ErrChainMismatch) {
+		t.Fatalf("Expected error %v, got %v", ErrChainMismatch, err)
+	}
+
+	// No node of another network is called, the data it returns must never be served
+	urls, err := callURLs(pool, func(string) error { return nil })
+	if !errors.Is(err, ErrChainMismatch) || !errors.Is(err, pkgErrors.ErrNodeUnavailable) {
+		t.Errorf("Expected error %v, got %v", ErrChainMismatch, err)
+	}
+
+	if len(urls) != 0 {
+		t.Errorf("Expected no call, got calls to %v", urls)
+	}
+}
+
+func TestAvailable(t *testing.T) {
+	pool := newTestPool(t, []string{"node1", "node2", "node3", "node4"}, DefaultOptions())
+
+	pool.endpoints[0].latency = 300 * time.Millisecond
+	pool.endpoints[1].latency = 50 * time.Millisecond
+	pool.endpoints[2].openUntil = time.Now().Add(time.Hour)
+	pool.endpoints[3].latency = 100 * time.Millisecond
+
+	testCases := []struct {
+		n        int
+		excluded []string
+		expected []string
+	}{
+		{n: 2, expected: []string{"node2", "node4"}},
+		{n: 5, expected: []string{"node2", "node4", "node1"}},
+		{n: 2, excluded: []string{"node2"}, expected: []string{"node4", "node1"}},
+	}
+
+	for _, tc := range testCases {
+		var urls []string
+		for _, n := range pool.Available(tc.n, tc.excluded...) {
+			urls = append(urls, n.URL)
+		}
+
+		if !slices.Equal(urls, tc.expected) {
+			t.Errorf("Expected %v available nodes, got %v", tc.expected, urls)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	pool := newTestPool(t, []string{"node1"}, Options{RateLimit: 10, FailureThreshold: 10})
+
+	var delays []time.Duration
+
+	pool.sleep = func(_ context.Context, d time.Duration) error {
+		delays = append(delays, d)
+
+		return nil
+	}
+
+	for range 3 {
+		_, _ = callURLs(pool, func(string) error { return nil })
+	}
+
+	// The first call is not delayed, the following ones are spaced by 100ms
+	if len(delays) != 2 {
+		t.Fatalf("Expected 2 delayed calls, got %v", delays)
+	}
+
+	for i, expected := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond} {
+		if delays[i] > expected || delays[i] < expected-10*time.Millisecond {
+			t.Errorf("Expected call %d to be delayed by %v, got %v", i+1, expected, delays[i])
+		}
+	}
+}
+
+func TestDoNodeURL(t *testing.T) {
+	pool := newTestPool(t, []string{"node1", "node2"}, Options{MaxRetries: 2, FailureThreshold: 1, OpenDuration: time.Hour})
+
+	var urls []string
+
+	err := pool.DoNodeURL(context.Background(), "node2", func(nodeURL string) error {
+		urls = append(urls, nodeURL)
+
+		return errUnreachable
+	})
+
+	// The call is not retried on another node, but the failure is recorded
+	if !errors.Is(err, errUnreachable) || !slices.Equal(urls, []string{"node2"}) {
+		t.Errorf("Expected a single failed call to node2, got calls to %v, %v", urls, err)
+	}
+
+	if available := pool.Available(2); len(available) != 1 || available[0].URL != "node1" {
+		t.Errorf("Expected node2 to be skipped, got %v", available)
+	}
+
+	err = pool.DoNodeURL(context.Background(), "node3", func(string) error { return nil })
+	if !errors.Is(err, pkgErrors.ErrNodeUnavailable) {
+		t.Errorf("Expected ErrNodeUnavailable for a node out of the pool, got %v", err)
+	}
+}
+
+func TestCallContext(t *testing.T) {
+	pool := newTestPool(t, []string{"node1", "node2"}, Options{MaxRetries: 1, FailureThreshold: 1, OpenDuration: time.Hour})
+
+	// A call is not started once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+
+	err := pool.DoURL(ctx, func(string) error {
+		calls++
+
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || calls != 0 {
+		t.Errorf("Expected no call and context.Canceled, got %d calls, %v", calls, err)
+	}
+
+	// A blocked call is given up at the deadline, without blaming the node
+	release := make(chan struct{})
+	defer close(release)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = Call(ctx, pool, func(*node.Client) (string, error) {
+		<-release
+
+		return "status", nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if available := pool.Available(2); len(available) != 2 {
+		t.Errorf("Expected both nodes to stay available, got %v", available)
+	}
+}
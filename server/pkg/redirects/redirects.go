@@ -0,0 +1,302 @@
+// Package redirects parses and evaluates the redirect and rewrite rules of a website.
+//
+// Rules are read from the _redirects file at the root of the website, one rule per line:
+//
+//	# Redirect a page that moved
+//	/old-page       /new-page               301
+//	# Placeholders and splats are replaced in the target
+//	/blog/:year/*   /news/:year/:splat      302
+//	# Serve another resource of the website without redirecting
+//	/app/*          /app/index.html         200
+//	# Serve a resource of another website, identified by its MNS name
+//	/docs/*         mns://documentation/:splat  200
+//	# Serve a page with a 404 status
+//	/private/*      /404.html               404
+//
+// The status is optional and defaults to 301. Rules are evaluated in order and the first matching one is applied.
+// Like files shadow rules on most static hosts, a rule only applies if the requested resource does not exist,
+// unless its status is followed by an exclamation mark, for example "200!".
+package redirects
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// FileName is the name of the file holding the rules, at the root of the website.
+	FileName = "_redirects"
+
+	// MNSScheme is the scheme of targets proxying to another website.
+	MNSScheme = "mns://"
+
+	splatPlaceholder = ":splat"
+	defaultStatus    = http.StatusMovedPermanently
+)
+
+// Rule is a redirect or rewrite rule.
+type Rule struct {
+	// From is the path pattern matched against the request path.
+	From string
+	// To is the target of the rule, it can contain placeholders.
+	To string
+	// Status is the HTTP status of the response: a redirect status, 200 for rewrites or 404.
+	Status int
+	// Force applies the rule even if the requested resource exists.
+	Force bool
+
+	segments []string
+	splat    bool
+}
+
+// Match is the result of a matching rule.
+type Match struct {
+	Rule *Rule
+	// Target is the target of the rule, with its placeholders replaced.
+	Target string
+}
+
+// IsRedirect returns true if the match must be answered with a redirect.
+func (m *Match) IsRedirect() bool {
+	return m.Rule.Status >= 300 && m.Rule.Status < 400
+}
+
+// MNSTarget returns the MNS name and path targeted by a proxy rule, or false if the target is not another website.
+func (m *Match) MNSTarget() (string, string, bool) {
+	target, found := strings.CutPrefix(m.Target, MNSScheme)
+	if !found {
+		return "", "", false
+	}
+
+	name, path, _ := strings.Cut(target, "/")
+
+	return name, "/" + path, true
+}
+
+// Rules is an ordered list of rules.
+type Rules []*Rule
+
+// Parse parses the content of a _redirects file.
+// Invalid lines are skipped and reported in the returned error, along with the valid rules.
+func Parse(content []byte) (Rules, error) {
+	var (
+		rules Rules
+		errs  []error
+	)
+
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule, err := parseRule(line)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", i+1, err))
+			continue
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, errors.Join(errs...)
+}
+
+// parseRule parses a single rule line.
+func parseRule(line string) (*Rule, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || len(fields) > 3 {
+		return nil, fmt.Errorf("expected 'from to [status]', got %q", line)
+	}
+
+	rule := &Rule{
+		From:   fields[0],
+		To:     fields[1],
+		Status: defaultStatus,
+	}
+
+	if !strings.HasPrefix(rule.From, "/") {
+		return nil, fmt.Errorf("path %q must start with '/'", rule.From)
+	}
+
+	if len(fields) == 3 {
+		status, force := strings.CutSuffix(fields[2], "!")
+
+		code, err := strconv.Atoi(status)
+		if err != nil {
+			return nil, fmt.Errorf("invalid status %q: %w", fields[2], err)
+		}
+
+		rule.Status = code
+		rule.Force = force
+	}
+
+	if err := validateTarget(rule); err != nil {
+		return nil, err
+	}
+
+	rule.segments = splitPath(rule.From)
+	if len(rule.segments) > 0 && rule.segments[len(rule.segments)-1] == "*" {
+		rule.splat = true
+		rule.segments = rule.segments[:len(rule.segments)-1]
+	}
+
+	return rule, nil
+}
+
+// validateTarget checks that the target of a rule can be used with its status.
+func validateTarget(rule *Rule) error {
+	if !isSafeTarget(rule.To) {
+		return fmt.Errorf("target %q would be read as another host", rule.To)
+	}
+
+	switch rule.Status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		if strings.HasPrefix(rule.To, "/") {
+			return nil
+		}
+
+		if target, err := url.Parse(rule.To); err == nil && (target.Scheme == "http" || target.Scheme == "https") {
+			return nil
+		}
+
+		return fmt.Errorf("redirect target %q must be a path or an http(s) URL", rule.To)
+	case http.StatusOK:
+		if strings.HasPrefix(rule.To, "/") {
+			return nil
+		}
+
+		if name, _, _ := strings.Cut(strings.TrimPrefix(rule.To, MNSScheme), "/"); strings.HasPrefix(rule.To, MNSScheme) && name != "" {
+			return nil
+		}
+
+		return fmt.Errorf("rewrite target %q must be a path or an %sname/path target", rule.To, MNSScheme)
+	case http.StatusNotFound:
+		if strings.HasPrefix(rule.To, "/") {
+			return nil
+		}
+
+		return fmt.Errorf("not found target %q must be a path", rule.To)
+	default:
+		return fmt.Errorf("unsupported status %d", rule.Status)
+	}
+}
+
+// Match returns the first rule matching the given request path, with its target resolved.
+func (rules Rules) Match(path string) (*Match, bool) {
+	segments := splitPath(path)
+
+	for _, rule := range rules {
+		placeholders, ok := rule.match(segments)
+		if !ok {
+			continue
+		}
+
+		target := replacePlaceholders(rule.To, placeholders)
+		if !isSafeTarget(target) {
+			continue
+		}
+
+		return &Match{Rule: rule, Target: target}, true
+	}
+
+	return nil, false
+}
+
+// isSafeTarget returns false if a path target starts with "//" or "/\", which browsers read as another host.
+func isSafeTarget(target string) bool {
+	return !strings.HasPrefix(target, "//") && !strings.HasPrefix(target, "/\\")
+}
+
+// match matches the rule against the segments of a path and returns the placeholders values.
+func (r *Rule) match(segments []string) (map[string]string, bool) {
+	if len(segments) < len(r.segments) || (!r.splat && len(segments) != len(r.segments)) {
+		return nil, false
+	}
+
+	placeholders := make(map[string]string)
+
+	for i, segment := range r.segments {
+		if strings.HasPrefix(segment, ":") {
+			placeholders[segment] = segments[i]
+			continue
+		}
+
+		if segment != segments[i] {
+			return nil, false
+		}
+	}
+
+	if r.splat {
+		placeholders[splatPlaceholder] = strings.Join(segments[len(r.segments):], "/")
+	}
+
+	return placeholders, true
+}
+
+// replacePlaceholders replaces the placeholders of the target by their values, in a single pass so that
+// the values are not scanned for placeholders. The longest placeholder is used at each position,
+// so that ":page" doesn't replace the beginning of ":pageId".
+// Values come from the request path, each of their segments is escaped.
+func replacePlaceholders(target string, placeholders map[string]string) string {
+	var result strings.Builder
+
+	for i := 0; i < len(target); {
+		name := longestPlaceholder(target[i:], placeholders)
+		if name == "" {
+			result.WriteByte(target[i])
+			i++
+
+			continue
+		}
+
+		result.WriteString(escapeSegments(placeholders[name]))
+		i += len(name)
+	}
+
+	return result.String()
+}
+
+// longestPlaceholder returns the longest placeholder at the start of s, or an empty string if there is none.
+func longestPlaceholder(s string, placeholders map[string]string) string {
+	if !strings.HasPrefix(s, ":") {
+		return ""
+	}
+
+	longest := ""
+
+	for name := range placeholders {
+		if len(name) > len(longest) && strings.HasPrefix(s, name) {
+			longest = name
+		}
+	}
+
+	return longest
+}
+
+// escapeSegments escapes each segment of a path.
+func escapeSegments(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// splitPath splits a path into its non empty segments.
+func splitPath(path string) []string {
+	var segments []string
+
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+
+	return segments
+}
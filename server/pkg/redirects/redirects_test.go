@@ -0,0 +1,184 @@
+package redirects
+
+import (
+	"net/http"
+	"testing"
+)
+
+const testRules = `
+# Moved pages
+/old-page       /new-page                301
+/blog/:year/*   /news/:year/:splat       302
+/external       https://massa.net
+
+/app/*          /app/index.html          200
+/docs/:page     mns://documentation/:page  200!
+/private/*      /404.html                404
+`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		wantRules int
+		wantErr   bool
+	}{
+		{"valid rules", testRules, 6, false},
+		{"empty file", "", 0, false},
+		{"missing target", "/old-page", 0, true},
+		{"relative path", "old-page /new-page", 0, true},
+		{"invalid status", "/old-page /new-page moved", 0, true},
+		{"unsupported status", "/old-page /new-page 500", 0, true},
+		{"rewrite to URL", "/old-page https://massa.net 200", 0, true},
+		{"invalid line is skipped", "/a /b\n/c /d 500\n/e /f", 2, true},
+		{"protocol relative target", "/old-page //evil.com", 0, true},
+		{"backslash target", "/old-page /\\evil.com 302", 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules, err := Parse([]byte(test.content))
+			if (err != nil) != test.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, test.wantErr)
+			}
+
+			if len(rules) != test.wantRules {
+				t.Errorf("Parse() returned %d rules, want %d", len(rules), test.wantRules)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	rules, err := Parse([]byte(testRules))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantMatch  bool
+		wantTarget string
+		wantStatus int
+		wantForce  bool
+	}{
+		{"exact path", "/old-page", true, "/new-page", http.StatusMovedPermanently, false},
+		{"trailing slash", "/old-page/", true, "/new-page", http.StatusMovedPermanently, false},
+		{"placeholder and splat", "/blog/2024/01/hello", true, "/news/2024/01/hello", http.StatusFound, false},
+		{"empty splat", "/blog/2024", true, "/news/2024/", http.StatusFound, false},
+		{"default status", "/external", true, "https://massa.net", http.StatusMovedPermanently, false},
+		{"rewrite", "/app/settings", true, "/app/index.html", http.StatusOK, false},
+		{"forced proxy", "/docs/intro", true, "mns://documentation/intro", http.StatusOK, true},
+		{"not found", "/private/key", true, "/404.html", http.StatusNotFound, false},
+		{"no match", "/new-page", false, "", 0, false},
+		{"placeholder needs a segment", "/docs", false, "", 0, false},
+		{"extra segment", "/old-page/child", false, "", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			match, ok := rules.Match(test.path)
+			if ok != test.wantMatch {
+				t.Fatalf("Match(%q) matched = %v, want %v", test.path, ok, test.wantMatch)
+			}
+
+			if !ok {
+				return
+			}
+
+			if match.Target != test.wantTarget {
+				t.Errorf("Match(%q) target = %q, want %q", test.path, match.Target, test.wantTarget)
+			}
+
+			if match.Rule.Status != test.wantStatus {
+				t.Errorf("Match(%q) status = %d, want %d", test.path, match.Rule.Status, test.wantStatus)
+			}
+
+			if match.Rule.Force != test.wantForce {
+				t.Errorf("Match(%q) force = %v, want %v", test.path, match.Rule.Force, test.wantForce)
+			}
+		})
+	}
+}
+
+func TestMatchPlaceholderValues(t *testing.T) {
+	rules, err := Parse([]byte(`
+/go/*          /:splat                302
+/users/:name/* /people/:name/:splat   302
+/pages/:id     /page/:id              200
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantMatch  bool
+		wantTarget string
+	}{
+		{"backslash is escaped", "/go/\\evil.com", true, "/%5Cevil.com"},
+		{"empty segments are dropped", "/go//evil.com", true, "/evil.com"},
+		{"segments are escaped", "/go/a b/c?d", true, "/a%20b/c%3Fd"},
+		{"values are not scanned for placeholders", "/users/alice/:name", true, "/people/alice/:name"},
+		{"rewrite value is escaped", "/pages/a b", true, "/page/a%20b"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			match, ok := rules.Match(test.path)
+			if ok != test.wantMatch {
+				t.Fatalf("Match(%q) matched = %v, want %v", test.path, ok, test.wantMatch)
+			}
+
+			if ok && match.Target != test.wantTarget {
+				t.Errorf("Match(%q) target = %q, want %q", test.path, match.Target, test.wantTarget)
+			}
+		})
+	}
+}
+
+func TestMatchUnsafeTarget(t *testing.T) {
+	rules, err := Parse([]byte(`
+/go/* /:splat/index.html 302
+/go/* /fallback          302
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+
+	// An empty splat would resolve the first rule to "//index.html", read by browsers as another host
+	match, ok := rules.Match("/go")
+	if !ok {
+		t.Fatal("Expected /go to match the fallback rule")
+	}
+
+	if match.Target != "/fallback" {
+		t.Errorf("Expected target /fallback, got %q", match.Target)
+	}
+}
+
+func TestMNSTarget(t *testing.T) {
+	tests := []struct {
+		target   string
+		wantName string
+		wantPath string
+		wantOk   bool
+	}{
+		{"mns://documentation/intro", "documentation", "/intro", true},
+		{"mns://documentation", "documentation", "/", true},
+		{"/intro", "", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.target, func(t *testing.T) {
+			match := &Match{Rule: &Rule{Status: http.StatusOK}, Target: test.target}
+
+			name, path, ok := match.MNSTarget()
+			if name != test.wantName || path != test.wantPath || ok != test.wantOk {
+				t.Errorf("MNSTarget() = (%q, %q, %v), want (%q, %q, %v)", name, path, ok, test.wantName, test.wantPath, test.wantOk)
+			}
+		})
+	}
+}
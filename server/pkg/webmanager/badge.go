@@ -0,0 +1,95 @@
+package webmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/massalabs/deweb-server/pkg/cache"
+	msConfig "github.com/massalabs/deweb-server/pkg/config"
+	pkgErrors "github.com/massalabs/deweb-server/pkg/error"
+	"github.com/massalabs/deweb-server/pkg/website"
+)
+
+// BadgeModeMinimal is the value of the BADGE global metadata requesting the minimal badge.
+const BadgeModeMinimal = "minimal"
+
+// BadgeInfo holds the facts about a website shown in its badge.
+type BadgeInfo struct {
+	Owner string
+	// LastUpdate is the last update of the website, it is zero if unknown.
+	LastUpdate time.Time
+	// Minimal is true if the website requests the minimal badge.
+	Minimal bool
+}
+
+// badgeInfoCache is a thread-safe cache of badge infos, indexed by website address.
+// Only the infos of the most recently requested websites are kept, see websiteCacheSize.
+type badgeInfoCache struct {
+	cache *expirable.LRU[string, *BadgeInfo]
+}
+
+var globalBadgeInfoCache = &badgeInfoCache{
+	cache: expirable.NewLRU[string, *BadgeInfo](websiteCacheSize, nil, websiteCacheTTL),
+}
+
+// get returns the badge info of a website if it was fetched for the given update.
+func (c *badgeInfoCache) get(websiteAddress string, lastUpdated time.Time) (*BadgeInfo, bool) {
+	info, exists := c.cache.Get(websiteAddress)
+	if !exists || !info.LastUpdate.Equal(lastUpdated) {
+		return nil, false
+	}
+
+	return info, true
+}
+
+// set stores the badge info of a website.
+func (c *badgeInfoCache) set(websiteAddress string, info *BadgeInfo) {
+	c.cache.Add(websiteAddress, info)
+}
+
+// GetBadgeInfo returns the facts about a website shown in its badge.
+// They are only fetched once per website update.
+func GetBadgeInfo(
+	ctx context.Context,
+	network *msConfig.NetworkInfos,
+	websiteAddress string,
+	cacheInstance *cache.Cache,
+) (*BadgeInfo, error) {
+	lastUpdated, err := getLastUpdate(ctx, network, websiteAddress, cacheInstance)
+	if err != nil && !errors.Is(err, pkgErrors.ErrNotFound) {
+		return nil, fmt.Errorf("failed to get last update timestamp: %w", err)
+	}
+
+	if lastUpdated != nil {
+		if info, ok := globalBadgeInfoCache.get(websiteAddress, *lastUpdated); ok {
+			return info, nil
+		}
+	}
+
+	owner, err := website.GetOwner(ctx, network, websiteAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get owner: %w", err)
+	}
+
+	mode, err := website.GetBadgeMode(ctx, network, websiteAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get badge mode: %w", err)
+	}
+
+	info := &BadgeInfo{
+		Owner:   owner,
+		Minimal: strings.EqualFold(strings.TrimSpace(mode), BadgeModeMinimal),
+	}
+
+	// Without update timestamp, the info can't be kept as there is no way to know when it becomes outdated
+	if lastUpdated != nil {
+		info.LastUpdate = *lastUpdated
+		globalBadgeInfoCache.set(websiteAddress, info)
+	}
+
+	return info, nil
+}
@@ -0,0 +1,62 @@
+package webmanager
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	pkgErrors "github.com/massalabs/deweb-server/pkg/error"
+	"github.com/massalabs/station/pkg/logger"
+)
+
+// nodeHealth tracks whether the node answers. While it doesn't, the server is degraded:
+// cached resources are served without checking whether their website was updated.
+type nodeHealth struct {
+	mu sync.Mutex
+	// unreachableSince is the time of the first failed call since the last successful one, it is zero if the node answers.
+	unreachableSince time.Time
+}
+
+var globalNodeHealth nodeHealth
+
+// report records the result of a node call. Errors returned by the node itself, such as missing entries,
+// mean that it answered.
+func (h *nodeHealth) report(err error) {
+	// A cancelled request tells nothing about the node
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch {
+	case isNodeError(err) && h.unreachableSince.IsZero():
+		logger.Warnf("Node is unreachable, serving cached websites without checking for updates: %v", err)
+
+		h.unreachableSince = time.Now()
+	case !isNodeError(err) && !h.unreachableSince.IsZero():
+		logger.Infof("Node is reachable again after %v", time.Since(h.unreachableSince).Round(time.Second))
+
+		h.unreachableSince = time.Time{}
+	}
+}
+
+// degraded returns true if the last node call failed.
+func (h *nodeHealth) degraded() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return !h.unreachableSince.IsZero()
+}
+
+// IsDegraded returns true if the node can't be reached, in which case cached resources are served as is.
+func IsDegraded() bool {
+	return globalNodeHealth.degraded()
+}
+
+// isNodeError returns true if the error is caused by the node not answering.
+func isNodeError(err error) bool {
+	return errors.Is(err, pkgErrors.ErrNodeUnavailable) || errors.Is(err, pkgErrors.ErrTimeout)
+}
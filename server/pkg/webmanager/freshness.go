@@ -0,0 +1,219 @@
+package webmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/massalabs/deweb-server/pkg/cache"
+	"github.com/massalabs/deweb-server/pkg/coalesce"
+	msConfig "github.com/massalabs/deweb-server/pkg/config"
+	pkgErrors "github.com/massalabs/deweb-server/pkg/error"
+	"github.com/massalabs/deweb-server/pkg/website"
+	"github.com/massalabs/station/pkg/logger"
+)
+
+// DefaultLastUpdateTTL is the default duration during which the last update timestamp of a website is used
+// without being checked on the node.
+const DefaultLastUpdateTTL = 10 * time.Second
+
+const (
+	// websiteCacheSize is the maximum number of websites whose last update timestamp, redirect rules
+	// or badge info are kept in memory. The least recently used websites are dropped first.
+	websiteCacheSize = 10000
+	// websiteCacheTTL is the duration after which these entries are dropped if they were not stored again.
+	websiteCacheTTL = time.Hour
+)
+
+// freshnessEntry holds the last update timestamp of a website.
+type freshnessEntry struct {
+	// lastUpdated is nil if the website has no last update timestamp.
+	lastUpdated *time.Time
+	checked     time.Time
+	refreshing  bool
+}
+
+// freshnessTracker caches the last update timestamp of websites, so that it is not fetched for each resource.
+// Once its TTL has expired, the cached timestamp is still used while it is refreshed in the background,
+// so that requests are served without waiting for the node (stale-while-revalidate).
+// When the timestamp of a website changes, all its cached resources are removed at once.
+// Only the timestamps of the most recently requested websites are kept, see websiteCacheSize.
+type freshnessTracker struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries *expirable.LRU[string, *freshnessEntry]
+	fetches coalesce.Group[*time.Time]
+	// fetch returns the last update timestamp of a website, it is website.GetLastUpdateTimestamp outside tests.
+	fetch func(ctx context.Context, network *msConfig.NetworkInfos, websiteAddress string) (*time.Time, error)
+}
+
+var globalFreshnessTracker = newFreshnessTracker(DefaultLastUpdateTTL, website.GetLastUpdateTimestamp)
+
+func newFreshnessTracker(
+	ttl time.Duration,
+	fetch func(ctx context.Context, network *msConfig.NetworkInfos, websiteAddress string) (*time.Time, error),
+) *freshnessTracker {
+	return &freshnessTracker{
+		ttl:     ttl,
+		entries: expirable.NewLRU[string, *freshnessEntry](websiteCacheSize, nil, websiteCacheTTL),
+		fetch:   fetch,
+	}
+}
+
+// SetLastUpdateTTL sets the duration during which the last update timestamp of a website is used
+// without being checked on the node. If it is 0, the timestamp is checked for every resource.
+func SetLastUpdateTTL(ttl time.Duration) {
+	globalFreshnessTracker.mu.Lock()
+	defer globalFreshnessTracker.mu.Unlock()
+
+	globalFreshnessTracker.ttl = ttl
+}
+
+// getLastUpdate returns the last update timestamp of a website.
+// It returns an error wrapping pkgErrors.ErrNotFound if the website has no last update timestamp.
+func getLastUpdate(
+	ctx context.Context,
+	network *msConfig.NetworkInfos,
+	websiteAddress string,
+	cacheInstance *cache.Cache,
+) (*time.Time, error) {
+	if watcher := globalUpdateWatcher.Load(); watcher != nil {
+		watcher.watch(websiteAddress)
+	}
+
+	return globalFreshnessTracker.lastUpdate(ctx, network, websiteAddress, cacheInstance)
+}
+
+// lastUpdate returns the cached last update timestamp of a website, or fetches it if it is unknown.
+// A timestamp older than the TTL is returned as is, and refreshed in the background.
+func (t *freshnessTracker) lastUpdate(
+	ctx context.Context,
+	network *msConfig.NetworkInfos,
+	websiteAddress string,
+	cacheInstance *cache.Cache,
+) (*time.Time, error) {
+	t.mu.Lock()
+
+	entry, exists := t.entries.Get(websiteAddress)
+	if exists && t.ttl > 0 {
+		lastUpdated := entry.lastUpdated
+
+		if time.Since(entry.checked) >= t.ttl && !entry.refreshing {
+			entry.refreshing = true
+
+			go t.refresh(network, websiteAddress, cacheInstance)
+		}
+
+		t.mu.Unlock()
+
+		return lastUpdateResult(lastUpdated)
+	}
+
+	t.mu.Unlock()
+
+	// While the node can't be reached, requests don't wait for it, it is only checked in the background
+	if globalNodeHealth.degraded() {
+		go t.refresh(network, websiteAddress, cacheInstance)
+
+		return nil, fmt.Errorf("last update timestamp not checked: %w", pkgErrors.ErrNodeUnavailable)
+	}
+
+	lastUpdated, err := t.fetches.Do(ctx, websiteAddress, func(ctx context.Context) (*time.Time, error) {
+		return t.fetchAndStore(ctx, network, websiteAddress, cacheInstance)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return lastUpdateResult(lastUpdated)
+}
+
+// refresh fetches the last update timestamp of a website in the background.
+// If it can't be fetched, the cached timestamp is kept and the refresh is retried once the TTL has expired again.
+func (t *freshnessTracker) refresh(network *msConfig.NetworkInfos, websiteAddress string, cacheInstance *cache.Cache) {
+	_, err := t.fetches.Do(context.Background(), websiteAddress, func(ctx context.Context) (*time.Time, error) {
+		return t.fetchAndStore(ctx, network, websiteAddress, cacheInstance)
+	})
+	if err != nil {
+		logger.Warnf("Failed to refresh last update timestamp of website %s: %v", websiteAddress, err)
+
+		t.mu.Lock()
+		if entry, exists := t.entries.Peek(websiteAddress); exists {
+			entry.refreshing = false
+			entry.checked = time.Now()
+		}
+		t.mu.Unlock()
+	}
+}
+
+// fetchAndStore fetches the last update timestamp of a website and stores it.
+// If it has changed, the cached resources of the website are removed, otherwise its index is kept.
+// The returned timestamp is nil if the website has no last update timestamp.
+func (t *freshnessTracker) fetchAndStore(
+	ctx context.Context,
+	network *msConfig.NetworkInfos,
+	websiteAddress string,
+	cacheInstance *cache.Cache,
+) (*time.Time, error) {
+	lastUpdated, err := t.fetch(ctx, network, websiteAddress)
+	globalNodeHealth.report(err)
+
+	if err != nil && !errors.Is(err, pkgErrors.ErrNotFound) {
+		return nil, err
+	}
+
+	t.store(websiteAddress, lastUpdated, cacheInstance)
+
+	return lastUpdated, nil
+}
+
+// store stores the last update timestamp of a website, read from the node.
+// If it has changed, the cached resources of the website are removed, otherwise its index is kept.
+func (t *freshnessTracker) store(websiteAddress string, lastUpdated *time.Time, cacheInstance *cache.Cache) {
+	t.mu.Lock()
+	previous, existed := t.entries.Peek(websiteAddress)
+	t.entries.Add(websiteAddress, &freshnessEntry{lastUpdated: lastUpdated, checked: time.Now()})
+	t.mu.Unlock()
+
+	if existed && !sameTimestamp(previous.lastUpdated, lastUpdated) {
+		logger.Infof("Website %s has been updated, removing its cached resources", websiteAddress)
+
+		deleteCachedWebsite(websiteAddress, cacheInstance)
+	}
+
+	website.ConfirmSiteIndex(websiteAddress, lastUpdated)
+}
+
+// deleteCachedWebsite removes the index and the cached resources of a website.
+func deleteCachedWebsite(websiteAddress string, cacheInstance *cache.Cache) {
+	website.InvalidateSiteIndex(websiteAddress)
+
+	if cacheInstance != nil {
+		if err := cacheInstance.DeleteWebsite(websiteAddress); err != nil {
+			logger.Warnf("Failed to remove cached resources of website %s: %v", websiteAddress, err)
+		}
+	}
+}
+
+// lastUpdateResult returns a copy of the timestamp, or an error if the website has no last update timestamp.
+func lastUpdateResult(lastUpdated *time.Time) (*time.Time, error) {
+	if lastUpdated == nil {
+		return nil, fmt.Errorf("last update timestamp %w", pkgErrors.ErrNotFound)
+	}
+
+	result := *lastUpdated
+
+	return &result, nil
+}
+
+// sameTimestamp returns true if both timestamps are unknown or equal.
+func sameTimestamp(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Equal(*b)
+}
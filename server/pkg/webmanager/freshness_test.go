@@ -0,0 +1,206 @@
+package webmanager
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	msConfig "github.com/massalabs/deweb-server/pkg/config"
+	pkgErrors "github.com/massalabs/deweb-server/pkg/error"
+)
+
+// fakeLastUpdates is a node returning configurable last update timestamps.
+type fakeLastUpdates struct {
+	mu        sync.Mutex
+	timestamp *time.Time
+	err       error
+	calls     int
+	fetched   chan struct{}
+}
+
+func (f *fakeLastUpdates) fetch(context.Context, *msConfig.NetworkInfos, string) (*time.Time, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls++
+
+	if f.fetched != nil {
+		defer func() { f.fetched <- struct{}{} }()
+	}
+
+	return f.timestamp, f.err
+}
+
+func (f *fakeLastUpdates) set(timestamp *time.Time, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.timestamp, f.err = timestamp, err
+}
+
+func (f *fakeLastUpdates) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls
+}
+
+func TestFreshnessTracker(t *testing.T) {
+	first := time.Unix(1000, 0)
+	second := time.Unix(2000, 0)
+
+	node := &fakeLastUpdates{timestamp: &first, fetched: make(chan struct{}, 10)}
+	tracker := newFreshnessTracker(time.Hour, node.fetch)
+	network := &msConfig.NetworkInfos{}
+
+	lastUpdate := func() *time.Time {
+		t.Helper()
+
+		lastUpdated, err := tracker.lastUpdate(context.Background(), network, "AS1", nil)
+		if err != nil {
+			t.Fatalf("Failed to get last update: %v", err)
+		}
+
+		return lastUpdated
+	}
+
+	// The first lookup fetches the timestamp, the next ones use the cached one
+	if lastUpdated := lastUpdate(); !lastUpdated.Equal(first) {
+		t.Errorf("Expected %v, got %v", first, lastUpdated)
+	}
+
+	<-node.fetched
+
+	lastUpdate()
+
+	if node.callCount() != 1 {
+		t.Errorf("Expected 1 fetch while the timestamp is fresh, got %d", node.callCount())
+	}
+
+	// Once expired, the cached timestamp is returned while it is refreshed in the background
+	node.set(&second, nil)
+
+	tracker.mu.Lock()
+	entry, _ := tracker.entries.Peek("AS1")
+	entry.checked = time.Now().Add(-2 * time.Hour)
+	tracker.mu.Unlock()
+
+	if lastUpdated := lastUpdate(); !lastUpdated.Equal(first) {
+		t.Errorf("Expected stale timestamp %v, got %v", first, lastUpdated)
+	}
+
+	select {
+	case <-node.fetched:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the timestamp to be refreshed in the background")
+	}
+
+	// Wait for the refreshed timestamp to be stored
+	deadline := time.Now().Add(time.Second)
+	for !lastUpdate().Equal(second) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected refreshed timestamp %v", second)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFreshnessTrackerErrors(t *testing.T) {
+	network := &msConfig.NetworkInfos{}
+
+	// A website without timestamp is remembered as such
+	node := &fakeLastUpdates{err: pkgErrors.ErrNotFound}
+	tracker := newFreshnessTracker(time.Hour, node.fetch)
+
+	for range 2 {
+		if _, err := tracker.lastUpdate(context.Background(), network, "AS1", nil); !errors.Is(err, pkgErrors.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	}
+
+	if node.callCount() != 1 {
+		t.Errorf("Expected 1 fetch, got %d", node.callCount())
+	}
+
+	// Node errors are not cached, but once the node is known to be unreachable, requests don't wait for it
+	defer globalNodeHealth.report(nil)
+
+	nodeErr := pkgErrors.NodeError(errors.New("connection refused"))
+	node = &fakeLastUpdates{err: nodeErr, fetched: make(chan struct{}, 10)}
+	tracker = newFreshnessTracker(time.Hour, node.fetch)
+
+	for range 2 {
+		if _, err := tracker.lastUpdate(context.Background(), network, "AS1", nil); !errors.Is(err, pkgErrors.ErrNodeUnavailable) {
+			t.Errorf("Expected node error, got %v", err)
+		}
+
+		<-node.fetched
+	}
+
+	if node.callCount() != 2 || !IsDegraded() {
+		t.Errorf("Expected 2 fetches with the node unreachable, got %d, degraded: %v", node.callCount(), IsDegraded())
+	}
+
+	// The node is checked in the background until it answers again
+	timestamp := time.Unix(1000, 0)
+	node.set(&timestamp, nil)
+
+	if _, err := tracker.lastUpdate(context.Background(), network, "AS1", nil); !errors.Is(err, pkgErrors.ErrNodeUnavailable) {
+		t.Errorf("Expected node error, got %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for IsDegraded() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the node to be reachable again")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	lastUpdated, err := tracker.lastUpdate(context.Background(), network, "AS1", nil)
+	if err != nil || !lastUpdated.Equal(timestamp) {
+		t.Errorf("Expected %v once the node answers, got %v, %v", timestamp, lastUpdated, err)
+	}
+}
+
+func TestFreshnessTrackerStore(t *testing.T) {
+	const websiteAddress = "AS1Store"
+
+	first := time.Unix(1000, 0)
+	second := time.Unix(2000, 0)
+
+	cacheInstance := testCache(t)
+	tracker := newFreshnessTracker(time.Hour, nil)
+	tracker.store(websiteAddress, &first, cacheInstance)
+
+	// Deploy tools set LAST_UPDATE along with every change, including file deletions and metadata only changes,
+	// and deleting a whole website removes it
+	tests := []struct {
+		name       string
+		lastUpdate *time.Time
+		kept       bool
+	}{
+		{"Same last update", &first, true},
+		{"Files deleted or metadata updated", &second, false},
+		{"Website deleted", nil, false},
+		{"Website without last update", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := cacheInstance.Save(websiteAddress, "index.html", []byte("<html></html>"), first, nil); err != nil {
+				t.Fatalf("Failed to cache resource: %v", err)
+			}
+
+			tracker.store(websiteAddress, tt.lastUpdate, cacheInstance)
+
+			if _, _, err := cacheInstance.Read(websiteAddress, "index.html"); (err == nil) != tt.kept {
+				t.Errorf("Expected resource to be kept: %v, got error %v", tt.kept, err)
+			}
+		})
+	}
+}
@@ -1,61 +1,112 @@
 package webmanager
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/massalabs/deweb-server/pkg/cache"
+	"github.com/massalabs/deweb-server/pkg/coalesce"
+	"github.com/massalabs/deweb-server/pkg/compression"
 	msConfig "github.com/massalabs/deweb-server/pkg/config"
 	"github.com/massalabs/deweb-server/pkg/website"
 	"github.com/massalabs/station/pkg/logger"
 )
 
+// ResourceInfo holds the metadata of a website resource needed to serve it.
+type ResourceInfo struct {
+	HttpHeaders map[string]string
+	// LastModified is the last update of the website, it is zero if unknown.
+	LastModified time.Time
+	// ETag is a strong validator of the resource, it is empty if unknown.
+	// It is the hash of the content, or identifies the website update for resources streamed from the node
+	// before their content is known, see OpenWebsiteResource.
+	ETag string
+	// Stale is true if the resource was read from the cache while the node can't be reached,
+	// in which case it may be outdated.
+	Stale bool
+}
+
+// fileRequest is the result of a file request, shared between concurrent callers.
+type fileRequest struct {
+	content []byte
+	info    ResourceInfo
+}
+
+// fileRequests coalesces concurrent requests of the same resource.
+var fileRequests coalesce.Group[*fileRequest]
+
 // getWebsiteResource fetches a resource from a website and returns its content.
-func GetWebsiteResource(network *msConfig.NetworkInfos, websiteAddress, resourceName string, cache *cache.Cache) ([]byte, map[string]string, error) {
+func GetWebsiteResource(
+	ctx context.Context,
+	network *msConfig.NetworkInfos,
+	websiteAddress, resourceName string,
+	cache *cache.Cache,
+) ([]byte, *ResourceInfo, error) {
 	logger.Debugf("Getting website %s resource %s", websiteAddress, resourceName)
 
-	content, httpHeaders, err := RequestFile(websiteAddress, network, resourceName, cache)
+	content, info, err := RequestFile(ctx, websiteAddress, network, resourceName, cache)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get file %s from website %s: %w", resourceName, websiteAddress, err)
 	}
 
 	logger.Debugf("Resource %s from %s successfully retrieved", resourceName, websiteAddress)
 
-	return content, httpHeaders, nil
+	return content, info, nil
 }
 
 // RequestFile fetches a website and caches it, or retrieves it from the cache if already present.
-func RequestFile(scAddress string, networkInfo *msConfig.NetworkInfos, resourceName string, cache *cache.Cache) ([]byte, map[string]string, error) {
-	// Get the last update timestamp from the website
-	// FIXME: We shouldn't fetch the last update timestamp for each resource. It should be cached and fetched once per period.
-	// https://github.com/massalabs/DeWeb/issues/280
-	lastUpdated, err := website.GetLastUpdateTimestamp(networkInfo, scAddress)
-	if err != nil {
-		logger.Warnf("Failed to get last update timestamp: %v", err)
-	} else if cache != nil {
-		lastModified, err := cache.GetLastModified(scAddress, resourceName)
+// Concurrent requests of the same resource, common when a popular website has just been updated,
+// share a single fetch. The returned content is shared between them and must not be modified.
+func RequestFile(
+	ctx context.Context,
+	scAddress string,
+	networkInfo *msConfig.NetworkInfos,
+	resourceName string,
+	cacheInstance *cache.Cache,
+) ([]byte, *ResourceInfo, error) {
+	result, err := fileRequests.Do(ctx, scAddress+"/"+resourceName, func(ctx context.Context) (*fileRequest, error) {
+		content, info, err := requestFile(ctx, scAddress, networkInfo, resourceName, cacheInstance)
 		if err != nil {
-			logger.Debugf("Resource %s from %s not in cache", resourceName, scAddress)
-		} else if !lastModified.Before(*lastUpdated) {
-			content, headers, err := cache.Read(scAddress, resourceName)
-			if err != nil {
-				logger.Warnf("Failed to read cached resource %s from %s: %v", resourceName, scAddress, err)
-			} else {
-				logger.Debugf("RequestFile: Cache hit for %s", resourceName)
-				return content, headers, nil
-			}
-		} else {
-			if err = cache.Delete(scAddress, resourceName); err != nil {
-				logger.Warnf("Failed to delete outdated resource %s from %s: %v", resourceName, scAddress, err)
-			}
-
-			logger.Warnf("website %s is outdated, fetching...", resourceName)
+			return nil, err
 		}
+
+		return &fileRequest{content: content, info: *info}, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Each caller gets its own info, which it can modify
+	info := result.info
+
+	return result.content, &info, nil
+}
+
+// requestFile fetches a website resource and caches it, or retrieves it from the cache if already present.
+func requestFile(
+	ctx context.Context,
+	scAddress string,
+	networkInfo *msConfig.NetworkInfos,
+	resourceName string,
+	cacheInstance *cache.Cache,
+) ([]byte, *ResourceInfo, error) {
+	// Get the last update timestamp from the website, it is only checked on the node once per period
+	lastUpdated, err := getLastUpdate(ctx, networkInfo, scAddress, cacheInstance)
+	if content, info, ok := readCachedResource(scAddress, resourceName, lastUpdated, err, cacheInstance); ok {
+		return content, info, nil
 	}
 
 	logger.Debugf("Website %s not found in cache or not up to date, fetching...", scAddress)
 
 	// Fetch the website content
-	websiteBytes, err := website.Fetch(networkInfo, scAddress, resourceName)
+	websiteBytes, err := website.Fetch(ctx, networkInfo, scAddress, resourceName)
+	globalNodeHealth.report(err)
+
 	if err != nil {
 		logger.Debugf("RequestFile failed")
 		return nil, nil, fmt.Errorf("failed to fetch %s from %s: %w", resourceName, scAddress, err)
@@ -63,16 +114,25 @@ func RequestFile(scAddress string, networkInfo *msConfig.NetworkInfos, resourceN
 
 	logger.Debugf("%s: %s successfully fetched with size: %d bytes", scAddress, resourceName, len(websiteBytes))
 
-	httpHeaders, err := website.GetHttpHeaders(networkInfo, scAddress, resourceName)
+	httpHeaders, err := website.GetHttpHeaders(ctx, networkInfo, scAddress, resourceName)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to fetch http header metadata: %w", err)
 	}
 
 	logger.Debugf("RequestFile: Headers for %s successfully fetched: %v", resourceName, httpHeaders)
 
+	info := &ResourceInfo{
+		HttpHeaders: httpHeaders,
+		ETag:        cache.ContentETag(websiteBytes),
+	}
+
+	if lastUpdated != nil {
+		info.LastModified = *lastUpdated
+	}
+
 	// Save to cache if available
-	if cache != nil {
-		err = cache.Save(scAddress, resourceName, websiteBytes, *lastUpdated, httpHeaders)
+	if cacheInstance != nil && lastUpdated != nil {
+		err = cacheInstance.Save(scAddress, resourceName, websiteBytes, *lastUpdated, httpHeaders)
 		if err != nil {
 			logger.Warnf("Failed to save %s to %s cache: %v", resourceName, scAddress, err)
 		} else {
@@ -82,16 +142,257 @@ func RequestFile(scAddress string, networkInfo *msConfig.NetworkInfos, resourceN
 
 	logger.Debugf("RequestFile completed")
 
-	return websiteBytes, httpHeaders, nil
+	return websiteBytes, info, nil
+}
+
+// OpenWebsiteResource returns a seekable reader over a website resource and its metadata.
+// If the resource is cached and up to date, it is read from the cache. Otherwise its chunks are streamed
+// from the node as the reader is consumed, so that the resource is never buffered before being served and
+// reading a byte range only fetches the chunks covering it.
+// Once the resource has been read entirely from its start, it is saved to the cache in the background,
+// unless it is larger than the maximum cached file size, see SetMaxCachedFileSize.
+// The ETag of a resource streamed from the node identifies the website update, as its content is not known yet.
+func OpenWebsiteResource(
+	ctx context.Context,
+	network *msConfig.NetworkInfos,
+	websiteAddress, resourceName string,
+	cacheInstance *cache.Cache,
+) (io.ReadSeeker, *ResourceInfo, error) {
+	lastUpdated, err := getLastUpdate(ctx, network, websiteAddress, cacheInstance)
+	if content, info, ok := readCachedResource(websiteAddress, resourceName, lastUpdated, err, cacheInstance); ok {
+		if info.ETag == "" {
+			info.ETag = versionETag(info.LastModified)
+		}
+
+		return bytes.NewReader(content), info, nil
+	}
+
+	chunkReader, err := website.NewChunkReader(ctx, network, websiteAddress, resourceName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open %s from %s: %w", resourceName, websiteAddress, err)
+	}
+
+	httpHeaders, err := website.GetHttpHeaders(ctx, network, websiteAddress, resourceName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch http header metadata: %w", err)
+	}
+
+	info := &ResourceInfo{HttpHeaders: httpHeaders}
+
+	if lastUpdated != nil {
+		info.LastModified = *lastUpdated
+		info.ETag = versionETag(*lastUpdated)
+	}
+
+	if lastUpdated == nil || cacheInstance == nil || chunkReader.Size() > maxCachedFileSize.Load() {
+		return chunkReader, info, nil
+	}
+
+	reader := newCachingReader(chunkReader, chunkReader.Size(), func(content []byte) {
+		if err := cacheInstance.Save(websiteAddress, resourceName, content, *lastUpdated, httpHeaders); err != nil {
+			logger.Warnf("Failed to save %s to %s cache: %v", resourceName, websiteAddress, err)
+		} else {
+			logger.Debugf("%s: %s successfully written to cache", websiteAddress, resourceName)
+		}
+	})
+
+	return reader, info, nil
+}
+
+// GetResourceInfo returns the metadata of a resource opened with OpenWebsiteResource, without fetching its content.
+// The ETag is the hash of the content of the resource if it is cached and up to date,
+// and identifies the website update otherwise.
+func GetResourceInfo(
+	ctx context.Context,
+	network *msConfig.NetworkInfos,
+	websiteAddress, resourceName string,
+	cacheInstance *cache.Cache,
+) (*ResourceInfo, error) {
+	lastUpdated, err := getLastUpdate(ctx, network, websiteAddress, cacheInstance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last update timestamp: %w", err)
+	}
+
+	if _, info, ok := readCachedFile(websiteAddress, resourceName, *lastUpdated, cacheInstance); ok && info.ETag != "" {
+		return info, nil
+	}
+
+	httpHeaders, err := website.GetHttpHeaders(ctx, network, websiteAddress, resourceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch http header metadata: %w", err)
+	}
+
+	return &ResourceInfo{HttpHeaders: httpHeaders, LastModified: *lastUpdated, ETag: versionETag(*lastUpdated)}, nil
+}
+
+// versionETag returns a strong ETag identifying the resources of a website update, as their content
+// only changes with the website. It is only used when the hash of the content is not known.
+func versionETag(lastUpdated time.Time) string {
+	return `"` + strconv.FormatInt(lastUpdated.UnixNano(), 36) + `"`
 }
 
-func ResourceExistsOnChain(network *msConfig.NetworkInfos, websiteAddress, filePath string) (bool, error) {
+// readCachedResource returns the cached content and metadata of a resource if it is up to date with the last update
+// of its website, as returned by getLastUpdate. If the last update can't be checked because the node can't be reached,
+// the cached resource is returned as is and marked stale.
+func readCachedResource(
+	scAddress, resourceName string,
+	lastUpdated *time.Time,
+	lastUpdateErr error,
+	cache *cache.Cache,
+) ([]byte, *ResourceInfo, bool) {
+	switch {
+	case lastUpdateErr == nil:
+		content, info, ok := readCachedFile(scAddress, resourceName, *lastUpdated, cache)
+		if ok {
+			// The timestamp may not have been checked on the node for a while
+			info.Stale = IsDegraded()
+		}
+
+		return content, info, ok
+	case isNodeError(lastUpdateErr):
+		logger.Warnf("Failed to get last update timestamp, serving cached %s from %s: %v", resourceName, scAddress, lastUpdateErr)
+
+		return readStaleFile(scAddress, resourceName, cache)
+	default:
+		logger.Warnf("Failed to get last update timestamp: %v", lastUpdateErr)
+
+		return nil, nil, false
+	}
+}
+
+// readCachedFile returns the cached content and metadata of a resource if it is up to date with lastUpdated.
+// Outdated entries are removed from the cache.
+func readCachedFile(scAddress, resourceName string, lastUpdated time.Time, cache *cache.Cache) ([]byte, *ResourceInfo, bool) {
+	if cache == nil {
+		return nil, nil, false
+	}
+
+	lastModified, err := cache.GetLastModified(scAddress, resourceName)
+	if err != nil {
+		logger.Debugf("Resource %s from %s not in cache", resourceName, scAddress)
+		return nil, nil, false
+	}
+
+	if lastModified.Before(lastUpdated) {
+		if err = cache.Delete(scAddress, resourceName); err != nil {
+			logger.Warnf("Failed to delete outdated resource %s from %s: %v", resourceName, scAddress, err)
+		}
+
+		logger.Warnf("website %s is outdated, fetching...", resourceName)
+
+		return nil, nil, false
+	}
+
+	return readCacheEntry(scAddress, resourceName, lastUpdated, cache)
+}
+
+// readStaleFile returns the cached content and metadata of a resource without checking whether it is up to date.
+func readStaleFile(scAddress, resourceName string, cache *cache.Cache) ([]byte, *ResourceInfo, bool) {
+	if cache == nil {
+		return nil, nil, false
+	}
+
+	lastModified, err := cache.GetLastModified(scAddress, resourceName)
+	if err != nil {
+		logger.Debugf("Resource %s from %s not in cache", resourceName, scAddress)
+		return nil, nil, false
+	}
+
+	content, info, ok := readCacheEntry(scAddress, resourceName, lastModified, cache)
+	if ok {
+		info.Stale = true
+	}
+
+	return content, info, ok
+}
+
+// readCacheEntry reads the content and metadata of a cached resource.
+func readCacheEntry(scAddress, resourceName string, lastModified time.Time, cache *cache.Cache) ([]byte, *ResourceInfo, bool) {
+	content, headers, err := cache.Read(scAddress, resourceName)
+	if err != nil {
+		logger.Warnf("Failed to read cached resource %s from %s: %v", resourceName, scAddress, err)
+		return nil, nil, false
+	}
+
+	// A missing ETag only prevents conditional requests from being answered without fetching the resource
+	etag, _ := cache.GetETag(scAddress, resourceName)
+
+	logger.Debugf("Cache hit for %s", resourceName)
+
+	return content, &ResourceInfo{HttpHeaders: headers, LastModified: lastModified, ETag: etag}, true
+}
+
+// EncodeResource returns the content of a resource compressed with the given encoding.
+// The compressed content is cached next to the resource, so that each version of a website is compressed only once.
+// The cached variant is keyed on the content, as the served content of a resource may change without a website update,
+// for instance when the 'Hosted by Massa' box is injected in an HTML page.
+func EncodeResource(websiteAddress, resourceName, encoding string, content []byte, lastModified time.Time, cacheInstance *cache.Cache) ([]byte, error) {
+	// Without modification time, a cached variant can't be known to be up to date
+	canCache := cacheInstance != nil && !lastModified.IsZero()
+	variant := encoding + ":" + strings.Trim(cache.ContentETag(content), `"`)
+
+	if canCache {
+		if encoded, ok := cacheInstance.ReadVariant(websiteAddress, resourceName, variant, lastModified); ok {
+			logger.Debugf("Cache hit for %s encoded with %s", resourceName, encoding)
+			return encoded, nil
+		}
+	}
+
+	encoded, err := compression.Compress(content, encoding)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s from %s: %w", resourceName, websiteAddress, err)
+	}
+
+	logger.Debugf("%s: %s encoded with %s from %d to %d bytes", websiteAddress, resourceName, encoding, len(content), len(encoded))
+
+	if canCache {
+		if err := cacheInstance.SaveVariant(websiteAddress, resourceName, variant, encoded, lastModified); err != nil {
+			logger.Warnf("Failed to save %s encoded with %s to %s cache: %v", resourceName, encoding, websiteAddress, err)
+		}
+	}
+
+	return encoded, nil
+}
+
+// ResourceExistsOnChain returns whether a website has a file at the given path.
+// If the node can't be reached, the answer comes from the cache alone: cached resources are considered present
+// and the other ones missing.
+func ResourceExistsOnChain(
+	ctx context.Context,
+	network *msConfig.NetworkInfos,
+	websiteAddress, filePath string,
+	cacheInstance *cache.Cache,
+) (bool, error) {
 	logger.Debugf("Checking if file %s exists on chain for website %s", filePath, websiteAddress)
 
-	isPresent, err := website.FilePathExists(network, websiteAddress, filePath)
+	isPresent, err := website.FilePathExists(ctx, network, websiteAddress, filePath)
 	if err != nil {
+		if isNodeError(err) && cacheInstance != nil {
+			_, cacheErr := cacheInstance.GetLastModified(websiteAddress, filePath)
+			logger.Warnf("Failed to check if file %s exists for website %s, using the cache: %v", filePath, websiteAddress, err)
+
+			return cacheErr == nil, nil
+		}
+
 		return false, fmt.Errorf("checking if file is present on chain: %w", err)
 	}
 
 	return isPresent, nil
 }
+
+// GetSPAFallback returns whether the single page app fallback is enabled for the website,
+// and whether the website defines this setting. If the node can't be reached, the setting is considered undefined,
+// as the presence of the pages it depends on comes from the cache, see ResourceExistsOnChain.
+func GetSPAFallback(ctx context.Context, network *msConfig.NetworkInfos, websiteAddress string) (bool, bool, error) {
+	enabled, defined, err := website.GetSPAFallback(ctx, network, websiteAddress)
+	if err != nil {
+		if isNodeError(err) {
+			logger.Warnf("Failed to get SPA fallback setting of website %s, considering it undefined: %v", websiteAddress, err)
+			return false, false, nil
+		}
+
+		return false, false, fmt.Errorf("getting SPA fallback setting: %w", err)
+	}
+
+	return enabled, defined, nil
+}
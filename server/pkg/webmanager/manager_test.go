@@ -0,0 +1,97 @@
+package webmanager
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/massalabs/deweb-server/pkg/cache"
+)
+
+// testCacheDir is the directory of the cache shared by the tests of the package, as it can only be created once.
+var testCacheDir = sync.OnceValues(func() (string, error) {
+	return os.MkdirTemp("", "deweb-webmanager-test")
+})
+
+// testCache returns the cache shared by the tests of the package.
+func testCache(t *testing.T) *cache.Cache {
+	t.Helper()
+
+	dir, err := testCacheDir()
+	if err != nil {
+		t.Fatalf("Failed to create cache directory: %v", err)
+	}
+
+	cacheInstance, err := cache.NewCache(dir, 10, 100)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	return cacheInstance
+}
+
+func TestEncodeResource(t *testing.T) {
+	cacheInstance := testCache(t)
+
+	lastModified := time.Now()
+	page := []byte("<html><body>Hello</body></html>")
+	injected := []byte("<html><body>Hello<div>Hosted by Massa</div></body></html>")
+
+	// The same version of a resource may be served with different contents, each has its own encoded variant
+	for _, content := range [][]byte{page, injected, page} {
+		encoded, err := EncodeResource("AS1", "index.html", "gzip", content, lastModified, cacheInstance)
+		if err != nil {
+			t.Fatalf("Failed to encode resource: %v", err)
+		}
+
+		reader, err := gzip.NewReader(bytes.NewReader(encoded))
+		if err != nil {
+			t.Fatalf("Failed to read gzip content: %v", err)
+		}
+
+		decoded, err := io.ReadAll(reader)
+		if err != nil || !bytes.Equal(decoded, content) {
+			t.Errorf("Expected %q, got %q (%v)", content, decoded, err)
+		}
+	}
+}
+
+func TestResourceETag(t *testing.T) {
+	defer func(previous *freshnessTracker) { globalFreshnessTracker = previous }(globalFreshnessTracker)
+
+	const websiteAddress = "AS1ETag"
+
+	first := time.Unix(1700000000, 0)
+	node := &fakeLastUpdates{timestamp: &first}
+	globalFreshnessTracker = newFreshnessTracker(time.Hour, node.fetch)
+
+	cacheInstance := testCache(t)
+	content := []byte("body { color: red; }")
+
+	if err := cacheInstance.Save(websiteAddress, "style.css", content, first, nil); err != nil {
+		t.Fatalf("Failed to cache resource: %v", err)
+	}
+
+	// A cached resource has the same ETag whether it is read entirely, streamed or only validated
+	expected := cache.ContentETag(content)
+
+	info, err := GetResourceInfo(context.Background(), nil, websiteAddress, "style.css", cacheInstance)
+	if err != nil || info.ETag != expected {
+		t.Errorf("Expected validator ETag %s, got %v (%v)", expected, info, err)
+	}
+
+	_, info, err = OpenWebsiteResource(context.Background(), nil, websiteAddress, "style.css", cacheInstance)
+	if err != nil || info.ETag != expected {
+		t.Errorf("Expected streamed ETag %s, got %v (%v)", expected, info, err)
+	}
+
+	_, info, err = RequestFile(context.Background(), websiteAddress, nil, "style.css", cacheInstance)
+	if err != nil || info.ETag != expected {
+		t.Errorf("Expected buffered ETag %s, got %v (%v)", expected, info, err)
+	}
+}
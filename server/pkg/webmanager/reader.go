@@ -0,0 +1,86 @@
+package webmanager
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// DefaultMaxCachedFileSize is the default size above which resources are not saved to the cache.
+const DefaultMaxCachedFileSize = 10 << 20
+
+// maxCachedFileSize is the size above which resources read from the chain are not saved to the cache,
+// as their content is held in memory until it is saved.
+var maxCachedFileSize atomic.Int64
+
+func init() {
+	maxCachedFileSize.Store(DefaultMaxCachedFileSize)
+}
+
+// SetMaxCachedFileSize sets the size above which resources are served from the chain without being cached.
+func SetMaxCachedFileSize(size int64) {
+	maxCachedFileSize.Store(size)
+}
+
+// MaxCachedFileSize returns the size above which resources are served from the chain without being cached.
+func MaxCachedFileSize() int64 {
+	return maxCachedFileSize.Load()
+}
+
+// cachingReader wraps the reader of a resource fetched from the chain and calls save with the resource
+// content once it has been read entirely. save is called in its own goroutine, so that the response
+// is not delayed by the cache.
+// Only contiguous reads from the start of the resource are recorded, so that serving a byte range
+// does not save a partial content.
+type cachingReader struct {
+	reader  io.ReadSeeker
+	size    int64
+	offset  int64
+	content []byte
+	save    func(content []byte)
+	saved   bool
+}
+
+func newCachingReader(reader io.ReadSeeker, size int64, save func(content []byte)) *cachingReader {
+	return &cachingReader{
+		reader: reader,
+		size:   size,
+		save:   save,
+	}
+}
+
+// Read implements io.Reader.
+func (c *cachingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+
+	if !c.saved && c.offset == int64(len(c.content)) {
+		if c.content == nil {
+			c.content = make([]byte, 0, c.size)
+		}
+
+		c.content = append(c.content, p[:n]...)
+
+		if int64(len(c.content)) == c.size {
+			c.saved = true
+
+			go c.save(c.content)
+
+			c.content = nil
+		}
+	}
+
+	c.offset += int64(n)
+
+	return n, err
+}
+
+// Seek implements io.Seeker.
+func (c *cachingReader) Seek(offset int64, whence int) (int64, error) {
+	newOffset, err := c.reader.Seek(offset, whence)
+	if err != nil {
+		return 0, err
+	}
+
+	c.offset = newOffset
+
+	return newOffset, nil
+}
@@ -0,0 +1,99 @@
+package webmanager
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestCachingReader(t *testing.T) {
+	content := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+
+	testCases := []struct {
+		name      string
+		read      func(r io.ReadSeeker) error
+		wantSaved bool
+	}{
+		{
+			name: "Whole content",
+			read: func(r io.ReadSeeker) error {
+				_, err := io.Copy(io.Discard, r)
+				return err
+			},
+			wantSaved: true,
+		},
+		{
+			name: "Size lookup before reading the whole content",
+			read: func(r io.ReadSeeker) error {
+				if _, err := r.Seek(0, io.SeekEnd); err != nil {
+					return err
+				}
+
+				if _, err := r.Seek(0, io.SeekStart); err != nil {
+					return err
+				}
+
+				_, err := io.Copy(io.Discard, r)
+
+				return err
+			},
+			wantSaved: true,
+		},
+		{
+			name: "Range from the middle",
+			read: func(r io.ReadSeeker) error {
+				if _, err := r.Seek(10, io.SeekStart); err != nil {
+					return err
+				}
+
+				_, err := io.Copy(io.Discard, r)
+
+				return err
+			},
+			wantSaved: false,
+		},
+		{
+			name: "Range from the start",
+			read: func(r io.ReadSeeker) error {
+				_, err := io.CopyN(io.Discard, r, 10)
+				return err
+			},
+			wantSaved: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			saved := make(chan []byte, 1)
+
+			reader := newCachingReader(bytes.NewReader(content), int64(len(content)), func(c []byte) {
+				saved <- c
+			})
+
+			if err := tc.read(reader); err != nil {
+				t.Fatalf("Failed to read: %v", err)
+			}
+
+			// The content is saved in the background once it has been read
+			if !tc.wantSaved {
+				select {
+				case c := <-saved:
+					t.Errorf("Expected no saved content, but got %s", c)
+				case <-time.After(10 * time.Millisecond):
+				}
+
+				return
+			}
+
+			select {
+			case c := <-saved:
+				if !bytes.Equal(c, content) {
+					t.Errorf("Expected saved content %s, but got %s", content, c)
+				}
+			case <-time.After(time.Second):
+				t.Error("Expected the content to be saved")
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package webmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/massalabs/deweb-server/pkg/cache"
+	msConfig "github.com/massalabs/deweb-server/pkg/config"
+	pkgErrors "github.com/massalabs/deweb-server/pkg/error"
+	"github.com/massalabs/deweb-server/pkg/redirects"
+	"github.com/massalabs/station/pkg/logger"
+)
+
+// redirectRulesEntry holds the parsed rules of a website for a given update.
+type redirectRulesEntry struct {
+	lastUpdated time.Time
+	rules       redirects.Rules
+}
+
+// redirectRulesCache is a thread-safe cache of parsed redirect rules, indexed by website address.
+// Only the rules of the most recently requested websites are kept, see websiteCacheSize.
+type redirectRulesCache struct {
+	cache *expirable.LRU[string, *redirectRulesEntry]
+}
+
+var globalRedirectRulesCache = &redirectRulesCache{
+	cache: expirable.NewLRU[string, *redirectRulesEntry](websiteCacheSize, nil, websiteCacheTTL),
+}
+
+// get returns the rules of a website if they were parsed for the given update.
+func (c *redirectRulesCache) get(websiteAddress string, lastUpdated time.Time) (redirects.Rules, bool) {
+	entry, exists := c.cache.Get(websiteAddress)
+	if !exists || !entry.lastUpdated.Equal(lastUpdated) {
+		return nil, false
+	}
+
+	return entry.rules, true
+}
+
+// set stores the rules of a website for the given update.
+func (c *redirectRulesCache) set(websiteAddress string, lastUpdated time.Time, rules redirects.Rules) {
+	c.cache.Add(websiteAddress, &redirectRulesEntry{lastUpdated: lastUpdated, rules: rules})
+}
+
+// GetRedirectRules returns the redirect and rewrite rules of a website.
+// The rules file is only fetched and parsed once per website update. A website without rules file has no rules.
+func GetRedirectRules(
+	ctx context.Context,
+	network *msConfig.NetworkInfos,
+	websiteAddress string,
+	cacheInstance *cache.Cache,
+) (redirects.Rules, error) {
+	// If the node can't be reached, the rules are read from the cached rules file
+	lastUpdated, err := getLastUpdate(ctx, network, websiteAddress, cacheInstance)
+	if err != nil && !errors.Is(err, pkgErrors.ErrNotFound) && !isNodeError(err) {
+		return nil, fmt.Errorf("failed to get last update timestamp: %w", err)
+	}
+
+	// Without update timestamp, the rules can't be kept as there is no way to know when they become outdated
+	if lastUpdated != nil {
+		if rules, ok := globalRedirectRulesCache.get(websiteAddress, *lastUpdated); ok {
+			return rules, nil
+		}
+	}
+
+	rules, err := fetchRedirectRules(ctx, network, websiteAddress, cacheInstance)
+	if err != nil {
+		return nil, err
+	}
+
+	if lastUpdated != nil {
+		globalRedirectRulesCache.set(websiteAddress, *lastUpdated, rules)
+	}
+
+	return rules, nil
+}
+
+// fetchRedirectRules fetches and parses the rules file of a website.
+func fetchRedirectRules(
+	ctx context.Context,
+	network *msConfig.NetworkInfos,
+	websiteAddress string,
+	cacheInstance *cache.Cache,
+) (redirects.Rules, error) {
+	exists, err := ResourceExistsOnChain(ctx, network, websiteAddress, redirects.FileName, cacheInstance)
+	if err != nil {
+		return nil, fmt.Errorf("checking if %s exists: %w", redirects.FileName, err)
+	}
+
+	if !exists {
+		return nil, nil
+	}
+
+	content, _, err := RequestFile(ctx, websiteAddress, network, redirects.FileName, cacheInstance)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", redirects.FileName, err)
+	}
+
+	rules, err := redirects.Parse(content)
+	if err != nil {
+		// Invalid rules are skipped, the valid ones are still applied
+		logger.Warnf("Invalid rules in %s of %s: %v", redirects.FileName, websiteAddress, err)
+	}
+
+	logger.Debugf("Parsed %d redirect rules for %s", len(rules), websiteAddress)
+
+	return rules, nil
+}
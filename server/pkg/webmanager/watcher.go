@@ -0,0 +1,121 @@
+package webmanager
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/massalabs/deweb-server/pkg/cache"
+	msConfig "github.com/massalabs/deweb-server/pkg/config"
+	"github.com/massalabs/deweb-server/pkg/website"
+	"github.com/massalabs/station/pkg/logger"
+)
+
+var globalUpdateWatcher atomic.Pointer[UpdateWatcher]
+
+// UpdateWatcher polls the last update timestamps of recently served websites, and removes their cached resources
+// as soon as they are modified, without waiting for the TTL of their timestamp to expire.
+// The timestamps of all the watched websites are read with a few batched datastore calls per poll,
+// so that it works with every deployed website contract.
+// Changes are seen through the LAST_UPDATE global metadata only: the deploy tools set it along with every change,
+// including file deletions and metadata only changes, and deleting a whole website removes it.
+type UpdateWatcher struct {
+	mu      sync.Mutex
+	network *msConfig.NetworkInfos
+	// retention is the duration during which a website is watched after it was last served.
+	retention time.Duration
+	// sites holds the time at which each watched website was last served.
+	sites map[string]time.Time
+	// fetch returns the last update timestamps of websites, it is website.GetLastUpdateTimestamps outside tests.
+	fetch func(ctx context.Context, network *msConfig.NetworkInfos, websiteAddresses []string) ([]*time.Time, error)
+	// store handles the last update timestamp read for a website, removing its cached resources if it has changed.
+	store func(websiteAddress string, lastUpdated *time.Time)
+}
+
+// NewUpdateWatcher creates a watcher removing the cached resources of modified websites from cacheInstance.
+func NewUpdateWatcher(network *msConfig.NetworkInfos, cacheInstance *cache.Cache, retention time.Duration) *UpdateWatcher {
+	return newUpdateWatcher(network, retention, website.GetLastUpdateTimestamps, func(websiteAddress string, lastUpdated *time.Time) {
+		globalFreshnessTracker.store(websiteAddress, lastUpdated, cacheInstance)
+	})
+}
+
+func newUpdateWatcher(
+	network *msConfig.NetworkInfos,
+	retention time.Duration,
+	fetch func(ctx context.Context, network *msConfig.NetworkInfos, websiteAddresses []string) ([]*time.Time, error),
+	store func(websiteAddress string, lastUpdated *time.Time),
+) *UpdateWatcher {
+	return &UpdateWatcher{
+		network:   network,
+		retention: retention,
+		sites:     make(map[string]time.Time),
+		fetch:     fetch,
+		store:     store,
+	}
+}
+
+// Run registers the watcher so that served websites are watched, and polls their last update timestamps
+// at the given interval until ctx is done.
+func (w *UpdateWatcher) Run(ctx context.Context, interval time.Duration) {
+	globalUpdateWatcher.Store(w)
+	defer globalUpdateWatcher.CompareAndSwap(w, nil)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.poll(ctx)
+		}
+	}
+}
+
+// watch starts watching a website, or extends the duration during which it is watched.
+func (w *UpdateWatcher) watch(websiteAddress string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.sites[websiteAddress] = time.Now()
+}
+
+// watchedSites returns the watched websites, after removing the ones which were not served recently.
+func (w *UpdateWatcher) watchedSites() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	addresses := make([]string, 0, len(w.sites))
+
+	for address, lastServed := range w.sites {
+		if time.Since(lastServed) > w.retention {
+			delete(w.sites, address)
+			continue
+		}
+
+		addresses = append(addresses, address)
+	}
+
+	return addresses
+}
+
+// poll reads the last update timestamps of the watched websites, and stores them.
+// While the node can't be reached, the websites are not polled, as the freshness tracker already retries.
+func (w *UpdateWatcher) poll(ctx context.Context) {
+	addresses := w.watchedSites()
+	if len(addresses) == 0 || globalNodeHealth.degraded() {
+		return
+	}
+
+	timestamps, err := w.fetch(ctx, w.network, addresses)
+	if err != nil {
+		logger.Warnf("Failed to poll last update timestamps of %d websites: %v", len(addresses), err)
+		return
+	}
+
+	for i, address := range addresses {
+		w.store(address, timestamps[i])
+	}
+}
@@ -0,0 +1,98 @@
+package webmanager
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	msConfig "github.com/massalabs/deweb-server/pkg/config"
+)
+
+// fakeTimestamps is a node returning configurable last update timestamps of several websites.
+type fakeTimestamps struct {
+	mu         sync.Mutex
+	timestamps map[string]*time.Time
+	calls      int
+}
+
+func (f *fakeTimestamps) fetch(_ context.Context, _ *msConfig.NetworkInfos, websiteAddresses []string) ([]*time.Time, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls++
+
+	timestamps := make([]*time.Time, len(websiteAddresses))
+	for i, address := range websiteAddresses {
+		timestamps[i] = f.timestamps[address]
+	}
+
+	return timestamps, nil
+}
+
+func TestUpdateWatcher(t *testing.T) {
+	first := time.Unix(1000, 0)
+	second := time.Unix(2000, 0)
+
+	node := &fakeTimestamps{timestamps: map[string]*time.Time{"AS1": &first, "AS2": &first, "AS3": &first}}
+
+	var stored map[string]*time.Time
+
+	watcher := newUpdateWatcher(&msConfig.NetworkInfos{}, time.Hour, node.fetch, func(websiteAddress string, lastUpdated *time.Time) {
+		stored[websiteAddress] = lastUpdated
+	})
+
+	// Nothing is read until a website is served
+	watcher.poll(context.Background())
+
+	if node.calls != 0 {
+		t.Fatalf("Expected no call without watched website, got %d", node.calls)
+	}
+
+	watcher.watch("AS1")
+	watcher.watch("AS2")
+
+	tests := []struct {
+		name       string
+		timestamps map[string]*time.Time
+		stored     map[string]*time.Time
+	}{
+		{"No update", map[string]*time.Time{}, map[string]*time.Time{"AS1": &first, "AS2": &first}},
+		{"Website updated", map[string]*time.Time{"AS1": &second}, map[string]*time.Time{"AS1": &second, "AS2": &first}},
+		{"Unwatched website updated", map[string]*time.Time{"AS3": &second}, map[string]*time.Time{"AS1": &second, "AS2": &first}},
+		{"Last update removed", map[string]*time.Time{"AS2": nil}, map[string]*time.Time{"AS1": &second, "AS2": nil}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored = map[string]*time.Time{}
+			calls := node.calls
+
+			maps.Copy(node.timestamps, tt.timestamps)
+			watcher.poll(context.Background())
+
+			// Every watched website is checked with a single call
+			if node.calls != calls+1 {
+				t.Errorf("Expected a single call, got %d", node.calls-calls)
+			}
+
+			if !maps.EqualFunc(stored, tt.stored, sameTimestamp) {
+				t.Errorf("Expected timestamps %v to be stored, got %v", tt.stored, stored)
+			}
+		})
+	}
+}
+
+func TestUpdateWatcherRetention(t *testing.T) {
+	watcher := newUpdateWatcher(&msConfig.NetworkInfos{}, time.Minute, nil, nil)
+
+	watcher.watch("AS1")
+	watcher.watch("AS2")
+	watcher.sites["AS1"] = time.Now().Add(-2 * time.Minute)
+
+	if sites := watcher.watchedSites(); !slices.Equal(sites, []string{"AS2"}) {
+		t.Errorf("Expected only recently served website to be watched, got %v", sites)
+	}
+}
@@ -0,0 +1,388 @@
+package website
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/massalabs/deweb-server/pkg/coalesce"
+	msConfig "github.com/massalabs/deweb-server/pkg/config"
+	pkgErrors "github.com/massalabs/deweb-server/pkg/error"
+	"github.com/massalabs/deweb-server/pkg/website/storagekeys"
+	"github.com/massalabs/station/pkg/convert"
+)
+
+// SiteIndex holds what is needed to serve a version of a website, except the content of its files,
+// so that it is read from the node once per LAST_UPDATE of the website instead of for each resource.
+// The metadata of a file are read the first time they are needed.
+type SiteIndex struct {
+	// LastUpdate is the LAST_UPDATE timestamp of the website when the index was built, nil if it has none.
+	LastUpdate *time.Time
+	Owner      string
+
+	// files holds the number of chunks of each file, indexed by path.
+	files map[string]int32
+	// globalMetadata holds the global metadata of the website, indexed by key.
+	globalMetadata map[string]string
+	// expiration is the time after which the index is built again if its version was not confirmed.
+	expiration time.Time
+	// maxAge is the duration during which the index is used without its version being confirmed.
+	maxAge time.Duration
+
+	mu sync.Mutex
+	// fileMetadata holds the metadata of each file read so far, indexed by the hash of its path.
+	fileMetadata map[[32]byte]map[string]string
+}
+
+// siteIndexCache is a thread-safe cache of website indexes
+type siteIndexCache struct {
+	mu      sync.Mutex
+	indexes map[string]*SiteIndex
+}
+
+var (
+	globalSiteIndexCache = &siteIndexCache{
+		indexes: make(map[string]*SiteIndex),
+	}
+
+	// siteIndexRequests coalesces concurrent builds of the index of a website.
+	siteIndexRequests coalesce.Group[*SiteIndex]
+)
+
+// get returns the index of a website if it exists and is not expired.
+func (c *siteIndexCache) get(websiteAddress string) (*SiteIndex, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	index, exists := c.indexes[websiteAddress]
+	if !exists || time.Now().After(index.expiration) {
+		return nil, false
+	}
+
+	return index, true
+}
+
+// set stores the index of a website, after removing the expired ones.
+func (c *siteIndexCache) set(websiteAddress string, index *SiteIndex) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for key, entry := range c.indexes {
+		if now.After(entry.expiration) {
+			delete(c.indexes, key)
+		}
+	}
+
+	c.indexes[websiteAddress] = index
+}
+
+// delete removes the index of a website.
+func (c *siteIndexCache) delete(websiteAddress string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.indexes, websiteAddress)
+}
+
+// InvalidateSiteIndex removes the index of a website, so that it is built again on the next request.
+func InvalidateSiteIndex(websiteAddress string) {
+	globalSiteIndexCache.delete(websiteAddress)
+}
+
+// ConfirmSiteIndex is called with the LAST_UPDATE timestamp of a website each time it is read from the node.
+// The index of the website is kept while it matches the timestamp, and removed as soon as it doesn't.
+func ConfirmSiteIndex(websiteAddress string, lastUpdate *time.Time) {
+	c := globalSiteIndexCache
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	index, exists := c.indexes[websiteAddress]
+	if !exists {
+		return
+	}
+
+	if !sameTimestamp(index.LastUpdate, lastUpdate) {
+		delete(c.indexes, websiteAddress)
+		return
+	}
+
+	index.expiration = time.Now().Add(index.maxAge)
+}
+
+// GetSiteIndex returns the index of a website, building it if it is not cached.
+// A cached index is used until a different LAST_UPDATE timestamp is confirmed, see ConfirmSiteIndex,
+// or for the index max age of the network if its timestamp is not confirmed.
+// The build is shared by concurrent callers, so it is not cancelled with ctx but given up after the index timeout.
+func GetSiteIndex(ctx context.Context, network *msConfig.NetworkInfos, websiteAddress string) (*SiteIndex, error) {
+	if index, exists := globalSiteIndexCache.get(websiteAddress); exists {
+		return index, nil
+	}
+
+	return siteIndexRequests.Do(ctx, websiteAddress, func(ctx context.Context) (*SiteIndex, error) {
+		ctx, cancel := msConfig.WithTimeout(ctx, network.ReadOptions().IndexTimeout)
+		defer cancel()
+
+		index, err := buildSiteIndex(ctx, network, websiteAddress)
+		if err != nil {
+			return nil, fmt.Errorf("indexing website %s: %w", websiteAddress, err)
+		}
+
+		globalSiteIndexCache.set(websiteAddress, index)
+
+		return index, nil
+	})
+}
+
+// buildSiteIndex reads the index of a website from the node.
+// The owner and last update timestamp are read first, so that an update during the build makes the index
+// outdated rather than labelled with the new version.
+func buildSiteIndex(ctx context.Context, network *msConfig.NetworkInfos, websiteAddress string) (*SiteIndex, error) {
+	versionValues, err := readValues(ctx, network, websiteAddress, [][]byte{
+		convert.ToBytes(ownerKey),
+		storagekeys.GlobalMetadataKey(lastUpdateTimestampKey),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading website owner and last update timestamp: %w", err)
+	}
+
+	lastUpdate, err := parseLastUpdateTimestamp(versionValues[1])
+	if err != nil && !errors.Is(err, pkgErrors.ErrNotFound) {
+		return nil, err
+	}
+
+	paths, err := readFilePaths(ctx, network, websiteAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	chunkCounts, err := readChunkCounts(ctx, network, websiteAddress, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	globalMetadata, err := readMetadata(ctx, network, websiteAddress, storagekeys.GlobalMetadataTag())
+	if err != nil {
+		return nil, fmt.Errorf("reading global metadata: %w", err)
+	}
+
+	files := make(map[string]int32, len(paths))
+	for i, path := range paths {
+		files[path] = chunkCounts[i]
+	}
+
+	maxAge := network.ReadOptions().IndexMaxAge
+
+	return &SiteIndex{
+		LastUpdate:     lastUpdate,
+		Owner:          string(versionValues[0]),
+		files:          files,
+		globalMetadata: globalMetadata,
+		expiration:     time.Now().Add(maxAge),
+		maxAge:         maxAge,
+		fileMetadata:   make(map[[32]byte]map[string]string),
+	}, nil
+}
+
+// readFilePaths reads the paths of the files of a website.
+// The paths are verified like the number of chunks, as a node could otherwise hide or add files.
+func readFilePaths(ctx context.Context, network *msConfig.NetworkInfos, websiteAddress string) ([]string, error) {
+	keys, err := listDatastoreKeys(ctx, network, websiteAddress, storagekeys.FileLocationTag())
+	if err != nil {
+		return nil, fmt.Errorf("listing file location keys: %w", err)
+	}
+
+	// The tag alone is not the location of a file
+	keys = slices.DeleteFunc(keys, func(key []byte) bool {
+		return len(key) == len(storagekeys.FileLocationTag())
+	})
+
+	values, err := readVerifiedValues(ctx, network, websiteAddress, keys)
+	if err != nil {
+		return nil, fmt.Errorf("reading file paths: %w", err)
+	}
+
+	paths := make([]string, len(values))
+	for i, value := range values {
+		paths[i] = string(value)
+	}
+
+	return paths, nil
+}
+
+// readChunkCounts reads the number of chunks of each file, it is 0 for files without chunks.
+func readChunkCounts(ctx context.Context, network *msConfig.NetworkInfos, websiteAddress string, paths []string) ([]int32, error) {
+	keys := make([][]byte, len(paths))
+
+	for i, path := range paths {
+		pathHash := sha256.Sum256([]byte(path))
+		keys[i] = storagekeys.FileChunkCountKey(pathHash[:])
+	}
+
+	values, err := readVerifiedValues(ctx, network, websiteAddress, keys)
+	if err != nil {
+		return nil, fmt.Errorf("reading number of chunks: %w", err)
+	}
+
+	counts := make([]int32, len(values))
+
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+
+		counts[i], err = convert.BytesToI32(value)
+		if err != nil {
+			return nil, fmt.Errorf("converting number of chunks of '%s': %w: %w", paths[i], pkgErrors.ErrCorruptedChunk, err)
+		}
+	}
+
+	return counts, nil
+}
+
+// readMetadata reads the metadata whose keys start with prefix, and indexes them by key without the prefix.
+// The values are verified as they may be http headers.
+func readMetadata(ctx context.Context, network *msConfig.NetworkInfos, websiteAddress string, prefix []byte) (map[string]string, error) {
+	keys, err := listDatastoreKeys(ctx, network, websiteAddress, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("listing metadata keys: %w", err)
+	}
+
+	values, err := readVerifiedValues(ctx, network, websiteAddress, keys)
+	if err != nil {
+		return nil, fmt.Errorf("reading metadata values: %w", err)
+	}
+
+	metadata := make(map[string]string, len(keys))
+	for i, key := range keys {
+		metadata[string(key[len(prefix):])] = string(values[i])
+	}
+
+	return metadata, nil
+}
+
+// readValues reads the final values of the given keys by datastore batches.
+func readValues(ctx context.Context, network *msConfig.NetworkInfos, websiteAddress string, keys [][]byte) ([][]byte, error) {
+	values := make([][]byte, 0, len(keys))
+
+	for batch := range slices.Chunk(keys, datastoreBatchSize) {
+		batchValues, _, err := readDatastore(ctx, network, websiteAddress, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, batchValues...)
+	}
+
+	return values, nil
+}
+
+// readVerifiedValues is readValues, with each batch verified against other nodes than the one it was read from.
+func readVerifiedValues(ctx context.Context, network *msConfig.NetworkInfos, websiteAddress string, keys [][]byte) ([][]byte, error) {
+	values := make([][]byte, 0, len(keys))
+
+	for batch := range slices.Chunk(keys, datastoreBatchSize) {
+		batchValues, servedBy, err := readDatastore(ctx, network, websiteAddress, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := verifyEntries(ctx, network, websiteAddress, servedBy, batch, batchValues); err != nil {
+			return nil, err
+		}
+
+		values = append(values, batchValues...)
+	}
+
+	return values, nil
+}
+
+// HasFile returns whether the website has a file at the given path.
+func (i *SiteIndex) HasFile(filePath string) bool {
+	_, exists := i.files[filePath]
+
+	return exists
+}
+
+// Files returns the paths of the files of the website, sorted.
+func (i *SiteIndex) Files() []string {
+	return slices.Sorted(maps.Keys(i.files))
+}
+
+// ChunkCount returns the number of chunks of a file, and whether the file exists.
+func (i *SiteIndex) ChunkCount(filePath string) (int32, bool) {
+	count, exists := i.files[filePath]
+
+	return count, exists
+}
+
+// GlobalMetadata returns a global metadata of the website, and whether it is defined.
+func (i *SiteIndex) GlobalMetadata(key string) (string, bool) {
+	value, exists := i.globalMetadata[key]
+
+	return value, exists
+}
+
+// HttpHeaders returns the http headers of a file, its own headers overriding the global ones.
+// The metadata of the file are read from the node the first time.
+func (i *SiteIndex) HttpHeaders(ctx context.Context, network *msConfig.NetworkInfos, websiteAddress, filePath string) (map[string]string, error) {
+	fileMetadata, err := i.fileMetadataOf(ctx, network, websiteAddress, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string]string)
+
+	for _, metadata := range []map[string]string{i.globalMetadata, fileMetadata} {
+		for key, value := range metadata {
+			if name, ok := strings.CutPrefix(key, httpHeaderPrefix); ok {
+				headers[name] = value
+			}
+		}
+	}
+
+	return headers, nil
+}
+
+// fileMetadataOf returns the metadata of a file, reading them if they are not indexed yet.
+func (i *SiteIndex) fileMetadataOf(ctx context.Context, network *msConfig.NetworkInfos, websiteAddress, filePath string) (map[string]string, error) {
+	fileHash := sha256.Sum256([]byte(filePath))
+
+	i.mu.Lock()
+	metadata, exists := i.fileMetadata[fileHash]
+	i.mu.Unlock()
+
+	if exists {
+		return metadata, nil
+	}
+
+	ctx, cancel := msConfig.WithTimeout(ctx, network.ReadOptions().ReadTimeout)
+	defer cancel()
+
+	metadata, err := readMetadata(ctx, network, websiteAddress, storagekeys.FileMetadataKey(fileHash, ""))
+	if err != nil {
+		return nil, fmt.Errorf("reading metadata of file %s: %w", filePath, err)
+	}
+
+	i.mu.Lock()
+	i.fileMetadata[fileHash] = metadata
+	i.mu.Unlock()
+
+	return metadata, nil
+}
+
+// sameTimestamp returns true if both timestamps are nil or equal.
+func sameTimestamp(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Equal(*b)
+}
@@ -0,0 +1,349 @@
+package website
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	msConfig "github.com/massalabs/deweb-server/pkg/config"
+	pkgErrors "github.com/massalabs/deweb-server/pkg/error"
+	"github.com/massalabs/deweb-server/pkg/website/storagekeys"
+)
+
+// fakeKeysNode serves get_addresses_datastore_keys and get_datastore_entries from a datastore.
+type fakeKeysNode struct {
+	mu       sync.Mutex
+	entries  map[string][]byte
+	requests []datastoreKeysRequest
+}
+
+func (n *fakeKeysNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var result any
+
+	switch req.Method {
+	case "get_addresses_datastore_keys":
+		var queries []datastoreKeysRequest
+		if err := json.Unmarshal(req.Params[0], &queries); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result = []datastoreKeysResponse{n.listKeys(queries[0])}
+	case "get_datastore_entries":
+		var queries []struct {
+			Address string         `json:"address"`
+			Key     datastoreBytes `json:"key"`
+		}
+		if err := json.Unmarshal(req.Params[0], &queries); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		entries := make([]datastoreEntryResponse, len(queries))
+		for i, query := range queries {
+			entries[i] = datastoreEntryResponse{FinalValue: n.entries[string(query.Key)]}
+		}
+
+		result = entries
+	default:
+		http.Error(w, "unknown method "+req.Method, http.StatusBadRequest)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"result":  result,
+	})
+}
+
+// listKeys returns the keys selected by query, and records it.
+func (n *fakeKeysNode) listKeys(query datastoreKeysRequest) datastoreKeysResponse {
+	n.requests = append(n.requests, query)
+
+	keys := []datastoreBytes{}
+
+	for _, key := range slices.Sorted(maps.Keys(n.entries)) {
+		if len(keys) == query.Count {
+			break
+		}
+
+		if !bytes.HasPrefix([]byte(key), query.Prefix) {
+			continue
+		}
+
+		if query.StartKey != nil && key <= string(query.StartKey) {
+			continue
+		}
+
+		keys = append(keys, datastoreBytes(key))
+	}
+
+	return datastoreKeysResponse{Address: query.Address, IsFinal: true, Keys: keys}
+}
+
+func newFakeKeysNode(t *testing.T, entries map[string][]byte) (*fakeKeysNode, *msConfig.NetworkInfos) {
+	t.Helper()
+
+	fake := &fakeKeysNode{entries: entries}
+	server := httptest.NewServer(fake)
+
+	t.Cleanup(server.Close)
+
+	return fake, &msConfig.NetworkInfos{NodeURL: server.URL}
+}
+
+func TestListDatastoreKeys(t *testing.T) {
+	entries := make(map[string][]byte)
+
+	for i := range datastoreKeysPageSize + 10 {
+		entries[string(storagekeys.FileLocationTag())+fmt.Sprintf("%04d", i)] = nil
+	}
+
+	entries[string(storagekeys.GlobalMetadataKey("OWNER"))] = nil
+
+	fake, network := newFakeKeysNode(t, entries)
+
+	keys, err := listDatastoreKeys(context.Background(), network, "AS1", storagekeys.FileLocationTag())
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+
+	if len(keys) != datastoreKeysPageSize+10 {
+		t.Errorf("Expected %d keys, got %d", datastoreKeysPageSize+10, len(keys))
+	}
+
+	if len(fake.requests) != 2 {
+		t.Fatalf("Expected 2 pages, got %d", len(fake.requests))
+	}
+
+	if !bytes.Equal(fake.requests[1].StartKey, keys[datastoreKeysPageSize-1]) || fake.requests[1].InclusiveStartKey {
+		t.Errorf("Expected the second page to start after the last key of the first one")
+	}
+}
+
+func TestReadDatastoreErrors(t *testing.T) {
+	testCases := []struct {
+		name        string
+		status      int
+		response    string
+		unavailable bool
+	}{
+		{"RPC error", http.StatusOK, `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"invalid params"}}`, false},
+		{"HTTP error", http.StatusBadGateway, ``, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tc.status)
+				w.Write([]byte(tc.response))
+			}))
+			defer server.Close()
+
+			network := &msConfig.NetworkInfos{NodeURL: server.URL}
+
+			_, _, err := readDatastore(context.Background(), network, "AS1", [][]byte{[]byte("key")})
+			if errors.Is(err, pkgErrors.ErrNodeUnavailable) != tc.unavailable {
+				t.Errorf("Expected unavailable %v, got %v", tc.unavailable, err)
+			}
+
+			_, err = listDatastoreKeys(context.Background(), network, "AS1", nil)
+			if errors.Is(err, pkgErrors.ErrNodeUnavailable) != tc.unavailable {
+				t.Errorf("Expected unavailable %v, got %v", tc.unavailable, err)
+			}
+		})
+	}
+}
+
+func TestGetLastUpdateTimestamps(t *testing.T) {
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+
+		var req struct {
+			Params [][]datastoreEntryRequest `json:"params"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Websites with an even address have a last update timestamp
+		entries := make([]datastoreEntryResponse, len(req.Params[0]))
+		for i, query := range req.Params[0] {
+			var index int
+
+			fmt.Sscanf(query.Address, "AS%d", &index)
+
+			if index%2 == 0 {
+				entries[i].FinalValue = []byte(fmt.Sprint(1000 + index))
+			}
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": 1, "result": entries})
+	}))
+	defer server.Close()
+
+	addresses := make([]string, datastoreBatchSize+1)
+	for i := range addresses {
+		addresses[i] = fmt.Sprintf("AS%d", i)
+	}
+
+	timestamps, err := GetLastUpdateTimestamps(context.Background(), &msConfig.NetworkInfos{NodeURL: server.URL}, addresses)
+	if err != nil {
+		t.Fatalf("Failed to get timestamps: %v", err)
+	}
+
+	if calls.Load() != 2 {
+		t.Errorf("Expected 2 batches, got %d calls", calls.Load())
+	}
+
+	if len(timestamps) != len(addresses) {
+		t.Fatalf("Expected %d timestamps, got %d", len(addresses), len(timestamps))
+	}
+
+	for i, timestamp := range timestamps {
+		switch {
+		case i%2 == 1 && timestamp != nil:
+			t.Errorf("Expected no timestamp for %s, got %v", addresses[i], timestamp)
+		case i%2 == 0 && (timestamp == nil || timestamp.Unix() != int64(1000+i)):
+			t.Errorf("Expected timestamp %d for %s, got %v", 1000+i, addresses[i], timestamp)
+		}
+	}
+}
+
+func TestGetHttpHeaders(t *testing.T) {
+	fileHash := sha256.Sum256([]byte("index.html"))
+	otherFileHash := sha256.Sum256([]byte("script.js"))
+
+	fake, network := newFakeKeysNode(t, map[string][]byte{
+		string(storagekeys.FileLocationTag()) + "index.html":                            []byte("index.html"),
+		string(storagekeys.GlobalMetadataKey(httpHeaderPrefix + "Cache-Control")):       []byte("max-age=60"),
+		string(storagekeys.GlobalMetadataKey(httpHeaderPrefix + "X-Frame-Options")):     []byte("DENY"),
+		string(storagekeys.FileMetadataKey(fileHash, httpHeaderPrefix+"Cache-Control")): []byte("no-cache"),
+		string(storagekeys.FileMetadataKey(otherFileHash, httpHeaderPrefix+"X-Other")):  []byte("other"),
+		string(storagekeys.FileChunkKey(fileHash[:], 0)):                                []byte("<html></html>"),
+	})
+
+	t.Cleanup(func() { InvalidateSiteIndex("AS1") })
+
+	headers, err := GetHttpHeaders(context.Background(), network, "AS1", "index.html")
+	if err != nil {
+		t.Fatalf("Failed to get headers: %v", err)
+	}
+
+	expected := map[string]string{"Cache-Control": "no-cache", "X-Frame-Options": "DENY"}
+	if !maps.Equal(headers, expected) {
+		t.Errorf("Expected headers %v, got %v", expected, headers)
+	}
+
+	// The file list is read from the same index
+	files, err := GetFilesPathList(context.Background(), network, "AS1")
+	if err != nil {
+		t.Fatalf("Failed to get files: %v", err)
+	}
+
+	if !slices.Equal(files, []string{"index.html"}) {
+		t.Errorf("Expected files [index.html], got %v", files)
+	}
+
+	for _, request := range fake.requests {
+		if len(request.Prefix) == 0 {
+			t.Errorf("Expected every key listing to be filtered by prefix")
+		}
+	}
+
+	if len(fake.requests) != 3 {
+		t.Errorf("Expected the location, global headers and file headers prefixes to be listed once, got %d listings", len(fake.requests))
+	}
+}
+
+func TestSiteIndexVersion(t *testing.T) {
+	fileHash := sha256.Sum256([]byte("index.html"))
+
+	fake, network := newFakeKeysNode(t, map[string][]byte{
+		"OWNER": []byte("AU1owner"),
+		string(storagekeys.GlobalMetadataKey(lastUpdateTimestampKey)): []byte("1000"),
+		string(storagekeys.GlobalMetadataKey(badgeKey)):               []byte("show"),
+		string(storagekeys.FileLocationTag()) + "index.html":          []byte("index.html"),
+		string(storagekeys.FileChunkCountKey(fileHash[:])):            {2, 0, 0, 0},
+	})
+
+	t.Cleanup(func() { InvalidateSiteIndex("AS1") })
+
+	index, err := GetSiteIndex(context.Background(), network, "AS1")
+	if err != nil {
+		t.Fatalf("Failed to index website: %v", err)
+	}
+
+	if index.Owner != "AU1owner" || index.LastUpdate == nil || index.LastUpdate.Unix() != 1000 {
+		t.Errorf("Unexpected owner %q or last update %v", index.Owner, index.LastUpdate)
+	}
+
+	if count, exists := index.ChunkCount("index.html"); !exists || count != 2 {
+		t.Errorf("Expected 2 chunks, got %d", count)
+	}
+
+	// Every request of the same version answers from the index
+	listings := len(fake.requests)
+
+	sameVersion := time.Unix(1000, 0)
+	ConfirmSiteIndex("AS1", &sameVersion)
+
+	if exists, err := FilePathExists(context.Background(), network, "AS1", "index.html"); err != nil || !exists {
+		t.Errorf("Expected index.html to exist, got %v, %v", exists, err)
+	}
+
+	if mode, err := GetBadgeMode(context.Background(), network, "AS1"); err != nil || mode != "show" {
+		t.Errorf("Expected badge mode show, got %q, %v", mode, err)
+	}
+
+	if len(fake.requests) != listings {
+		t.Errorf("Expected no listing for the same version, got %d", len(fake.requests)-listings)
+	}
+
+	// A new version builds a new index
+	fake.mu.Lock()
+	fake.entries[string(storagekeys.GlobalMetadataKey(lastUpdateTimestampKey))] = []byte("2000")
+	fake.entries[string(storagekeys.FileLocationTag())+"about.html"] = []byte("about.html")
+	fake.mu.Unlock()
+
+	newVersion := time.Unix(2000, 0)
+	ConfirmSiteIndex("AS1", &newVersion)
+
+	files, err := GetFilesPathList(context.Background(), network, "AS1")
+	if err != nil {
+		t.Fatalf("Failed to get files: %v", err)
+	}
+
+	if !slices.Equal(files, []string{"about.html", "index.html"}) {
+		t.Errorf("Expected the files of the new version, got %v", files)
+	}
+}
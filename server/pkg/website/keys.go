@@ -0,0 +1,177 @@
+package website
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	msConfig "github.com/massalabs/deweb-server/pkg/config"
+	pkgErrors "github.com/massalabs/deweb-server/pkg/error"
+	"github.com/massalabs/deweb-server/pkg/jsonrpc"
+)
+
+// datastoreKeysPageSize is the number of keys requested at once, below the default limit of the nodes.
+const datastoreKeysPageSize = 500
+
+// datastoreBytes is a datastore key, encoded by the node API as an array of bytes.
+type datastoreBytes []byte
+
+func (b datastoreBytes) MarshalJSON() ([]byte, error) {
+	if b == nil {
+		return []byte("null"), nil
+	}
+
+	values := make([]int, len(b))
+	for i, v := range b {
+		values[i] = int(v)
+	}
+
+	return json.Marshal(values)
+}
+
+func (b *datastoreBytes) UnmarshalJSON(data []byte) error {
+	var values []uint8
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+
+	*b = values
+
+	return nil
+}
+
+// datastoreKeysRequest selects the keys returned by get_addresses_datastore_keys.
+type datastoreKeysRequest struct {
+	Address           string         `json:"address"`
+	IsFinal           bool           `json:"is_final"`
+	Prefix            datastoreBytes `json:"prefix"`
+	StartKey          datastoreBytes `json:"start_key"`
+	InclusiveStartKey bool           `json:"inclusive_start_key"`
+	EndKey            datastoreBytes `json:"end_key"`
+	InclusiveEndKey   bool           `json:"inclusive_end_key"`
+	Count             int            `json:"count"`
+}
+
+type datastoreKeysResponse struct {
+	Address string           `json:"address"`
+	IsFinal bool             `json:"is_final"`
+	Keys    []datastoreBytes `json:"keys"`
+}
+
+// listDatastoreKeys returns the final datastore keys of the website starting with prefix, in the order of the node.
+// The keys are read by pages, so that websites with many files don't hit the response limits of the node.
+func listDatastoreKeys(ctx context.Context, network *msConfig.NetworkInfos, websiteAddress string, prefix []byte) ([][]byte, error) {
+	var keys [][]byte
+
+	request := datastoreKeysRequest{
+		Address: websiteAddress,
+		IsFinal: true,
+		Prefix:  prefix,
+		Count:   datastoreKeysPageSize,
+	}
+
+	for {
+		var responses []datastoreKeysResponse
+
+		err := msConfig.NodeURLCall(ctx, network, func(nodeURL string) error {
+			return jsonrpc.Call(ctx, http.DefaultClient, nodeURL, "get_addresses_datastore_keys", []any{[]datastoreKeysRequest{request}}, &responses)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("listing datastore keys: %w", nodeCallError(err))
+		}
+
+		if len(responses) != 1 {
+			return nil, fmt.Errorf("listing datastore keys: expected 1 response, got %d", len(responses))
+		}
+
+		page := responses[0].Keys
+		for _, key := range page {
+			keys = append(keys, key)
+		}
+
+		if len(page) < datastoreKeysPageSize {
+			return keys, nil
+		}
+
+		request.StartKey = page[len(page)-1]
+		request.InclusiveStartKey = false
+	}
+}
+
+// datastoreEntryRequest selects an entry returned by get_datastore_entries.
+type datastoreEntryRequest struct {
+	Address string         `json:"address"`
+	Key     datastoreBytes `json:"key"`
+}
+
+type datastoreEntryResponse struct {
+	FinalValue     datastoreBytes `json:"final_value"`
+	CandidateValue datastoreBytes `json:"candidate_value"`
+}
+
+// getDatastoreEntries returns the final values of the given datastore keys of the website from the node at nodeURL,
+// nil for missing entries. The request is cancelled when ctx is done.
+func getDatastoreEntries(ctx context.Context, nodeURL, websiteAddress string, keys [][]byte) ([][]byte, error) {
+	requests := make([]datastoreEntryRequest, len(keys))
+	for i, key := range keys {
+		requests[i] = datastoreEntryRequest{Address: websiteAddress, Key: key}
+	}
+
+	return getEntries(ctx, nodeURL, requests)
+}
+
+// getEntries returns the final values of the requested datastore entries, which may belong to different addresses.
+func getEntries(ctx context.Context, nodeURL string, requests []datastoreEntryRequest) ([][]byte, error) {
+	var responses []datastoreEntryResponse
+
+	if err := jsonrpc.Call(ctx, http.DefaultClient, nodeURL, "get_datastore_entries", []any{requests}, &responses); err != nil {
+		return nil, err
+	}
+
+	if len(responses) != len(requests) {
+		return nil, fmt.Errorf("get_datastore_entries: expected %d entries, got %d", len(requests), len(responses))
+	}
+
+	values := make([][]byte, len(responses))
+	for i, response := range responses {
+		values[i] = response.FinalValue
+	}
+
+	return values, nil
+}
+
+// readDatastore returns the final values of the given datastore keys of the website from a node of the network,
+// along with the URL of the node which answered.
+func readDatastore(ctx context.Context, network *msConfig.NetworkInfos, websiteAddress string, keys [][]byte) ([][]byte, string, error) {
+	var (
+		values   [][]byte
+		servedBy string
+	)
+
+	err := msConfig.NodeURLCall(ctx, network, func(nodeURL string) error {
+		var err error
+
+		values, err = readEntries(ctx, nodeURL, websiteAddress, keys)
+		servedBy = nodeURL
+
+		return err
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("calling get_datastore_entries: %w", nodeCallError(err))
+	}
+
+	return values, servedBy, nil
+}
+
+// nodeCallError wraps the error of a call to the nodes with pkgErrors.NodeError, unless it was returned
+// by a node itself as another node would return the same, see jsonrpc.Call.
+func nodeCallError(err error) error {
+	var rpcErr *jsonrpc.Error
+	if errors.As(err, &rpcErr) {
+		return err
+	}
+
+	return pkgErrors.NodeError(err)
+}
@@ -1,354 +1,171 @@
 package website
 
 import (
-	"bytes"
-	"crypto/sha256"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"slices"
 	"strconv"
-	"sync"
 	"time"
 
-	"github.com/massalabs/deweb-server/int/api/config"
 	msConfig "github.com/massalabs/deweb-server/pkg/config"
+	pkgErrors "github.com/massalabs/deweb-server/pkg/error"
 	"github.com/massalabs/deweb-server/pkg/website/storagekeys"
-	"github.com/massalabs/station/pkg/convert"
 	"github.com/massalabs/station/pkg/logger"
-	"github.com/massalabs/station/pkg/node"
 )
 
 const (
 	datastoreBatchSize     = 64
 	notFoundErrorTemplate  = "no chunks found for file %s"
 	lastUpdateTimestampKey = "LAST_UPDATE"
+	spaFallbackKey         = "SPA_FALLBACK"
+	badgeKey               = "BADGE"
 	httpHeaderPrefix       = "http-header:"
 )
 
-// filePathListCacheEntry represents a cached file path list with its expiration time
-type filePathListCacheEntry struct {
-	files      map[string]struct{} // Using map for O(1) lookups
-	expiration time.Time
-}
-
-// filePathListCache is a thread-safe cache for file path lists
-type filePathListCache struct {
-	mu    sync.RWMutex
-	cache map[string]*filePathListCacheEntry
-}
-
-var (
-	globalFilePathListCache = &filePathListCache{
-		cache: make(map[string]*filePathListCacheEntry),
-	}
-	serverConfig *config.ServerConfig
-)
-
-// SetConfig sets the server configuration for the website package
-func SetConfig(config *config.ServerConfig) {
-	serverConfig = config
-}
-
-// get retrieves the file path list from cache if it exists and is not expired
-func (c *filePathListCache) get(websiteAddress string) (map[string]struct{}, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	entry, exists := c.cache[websiteAddress]
-	if !exists {
-		return nil, false
-	}
-
-	if time.Now().After(entry.expiration) {
-		return nil, false
-	}
-
-	return entry.files, true
-}
-
-// set stores the file path list in the cache with an expiration time based on config
-func (c *filePathListCache) set(websiteAddress string, files []string) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	// Clean up expired entries before adding new ones
-	now := time.Now()
-	for key, entry := range c.cache {
-		if now.After(entry.expiration) {
-			delete(c.cache, key)
-		}
-	}
-
-	// Convert slice to map for O(1) lookups
-	filesMap := make(map[string]struct{}, len(files))
-	for _, file := range files {
-		filesMap[file] = struct{}{}
-	}
-
-	// Get expiration duration from config or use default
-	var cacheDuration time.Duration
-	if serverConfig != nil {
-		cacheDuration = time.Duration(serverConfig.CacheConfig.FileListCacheDurationSeconds) * time.Second
-	} else {
-		cacheDuration = time.Duration(config.DefaultFileListCachePeriod) * time.Second
-	}
-
-	c.cache[websiteAddress] = &filePathListCacheEntry{
-		files:      filesMap,
-		expiration: now.Add(cacheDuration),
-	}
-}
+// readEntries reads datastore entries from a single node, it is getDatastoreEntries outside tests.
+var readEntries = getDatastoreEntries
 
 // Fetch retrieves the complete data of a website as bytes.
-func Fetch(network *msConfig.NetworkInfos, websiteAddress string, filePath string) ([]byte, error) {
-	client := node.NewClient(network.NodeURL)
-
-	isPresent, err := FilePathExists(network, websiteAddress, filePath)
+// Prefer NewChunkReader to stream the file without holding it entirely in memory.
+func Fetch(ctx context.Context, network *msConfig.NetworkInfos, websiteAddress string, filePath string) ([]byte, error) {
+	reader, err := NewChunkReader(ctx, network, websiteAddress, filePath)
 	if err != nil {
-		return nil, fmt.Errorf("checking if file is present on chain: %w", err)
+		return nil, err
 	}
 
-	if isPresent {
-		logger.Debugf("File '%s' is present on chain", filePath)
-	} else {
-		return nil, fmt.Errorf("file '%s' not found on chain", filePath)
-	}
+	content := make([]byte, reader.Size())
 
-	chunkNumber, err := GetNumberOfChunks(client, websiteAddress, filePath)
-	if err != nil {
-		return nil, fmt.Errorf("fetching number of chunks: %w", err)
-	}
-
-	logger.Debugf("Number of chunks for file '%s': %d", filePath, chunkNumber)
-
-	if chunkNumber == 0 {
-		return nil, fmt.Errorf("no chunks found for file '%s'", filePath)
-	}
-
-	dataStore, err := fetchAllChunks(client, websiteAddress, filePath, chunkNumber)
-	if err != nil {
+	if _, err := io.ReadFull(reader, content); err != nil {
 		return nil, fmt.Errorf("fetching all chunks: %w", err)
 	}
 
-	return dataStore, nil
+	return content, nil
 }
 
-// Fetch retrieves the complete data of a website as bytes.
-func GetHttpHeaders(network *msConfig.NetworkInfos, websiteAddress string, filePath string) (map[string]string, error) {
-	client := node.NewClient(network.NodeURL)
-
-	globalMetadataKeyFilter := storagekeys.GlobalMetadataKey(httpHeaderPrefix)
-
-	fileHash := sha256.Sum256([]byte(filePath))
-
-	fileMetadataKeyFilter := storagekeys.FileMetadataKey(fileHash, httpHeaderPrefix)
-
-	addressInfo, err := node.Addresses(client, []string{websiteAddress})
+// GetHttpHeaders returns the http headers of a website file, its own headers overriding the global ones.
+func GetHttpHeaders(ctx context.Context, network *msConfig.NetworkInfos, websiteAddress string, filePath string) (map[string]string, error) {
+	index, err := GetSiteIndex(ctx, network, websiteAddress)
 	if err != nil {
-		return nil, fmt.Errorf("calling get_addresses '%+v': %w", []string{websiteAddress}, err)
+		return nil, err
 	}
 
-	datastoreKeys := addressInfo[0].FinalDatastoreKeys
-
-	headersRecord := make(map[string]string)
-
-	var httpHeaderKeys [][]byte
-
-	for _, key := range datastoreKeys {
-		var parsedKey string
-		if bytes.HasPrefix(key, globalMetadataKeyFilter) {
-			parsedKey = string(key[len(globalMetadataKeyFilter):])
-
-			// to avoid duplicate http headers append global one only if not present
-			if _, exists := headersRecord[parsedKey]; !exists {
-				headersRecord[parsedKey] = ""
-
-				httpHeaderKeys = append(httpHeaderKeys, key)
-			}
-		}
-
-		// file headers should override global ones
-		if bytes.HasPrefix(key, fileMetadataKeyFilter) {
-			httpHeaderKeys = append(httpHeaderKeys, key)
-			parsedKey = string(key[len(fileMetadataKeyFilter):])
-			headersRecord[parsedKey] = ""
-		}
-	}
-
-	httpHeaderValues, err := node.ContractDatastoreEntries(client, websiteAddress, httpHeaderKeys)
-	if err != nil {
-		return nil, fmt.Errorf("fetching http header metadata values: %w", err)
-	}
-
-	for idx, val := range httpHeaderValues {
-		var parsedKey string
-		if bytes.HasPrefix(httpHeaderKeys[idx], fileMetadataKeyFilter) {
-			parsedKey = string(httpHeaderKeys[idx][len(fileMetadataKeyFilter):])
-		}
-
-		if bytes.HasPrefix(httpHeaderKeys[idx], globalMetadataKeyFilter) {
-			parsedKey = string(httpHeaderKeys[idx][len(globalMetadataKeyFilter):])
-		}
-
-		headersRecord[parsedKey] = string(val.FinalValue)
-	}
-
-	return headersRecord, nil
+	return index.HttpHeaders(ctx, network, websiteAddress, filePath)
 }
 
-// GetNumberOfChunks fetches and returns the number of chunks for the website.
-func GetNumberOfChunks(client *node.Client, websiteAddress string, filePath string) (int32, error) {
-	filePathHash := sha256.Sum256([]byte(filePath))
-	nbChunkKey := storagekeys.FileChunkCountKey(filePathHash[:])
-
-	nbChunkResponse, err := node.FetchDatastoreEntry(client, websiteAddress, nbChunkKey)
+// GetNumberOfChunks returns the number of chunks of a website file.
+func GetNumberOfChunks(ctx context.Context, network *msConfig.NetworkInfos, websiteAddress string, filePath string) (int32, error) {
+	index, err := GetSiteIndex(ctx, network, websiteAddress)
 	if err != nil {
-		return 0, fmt.Errorf("fetching website number of chunks: %w", err)
+		return 0, err
 	}
 
-	if nbChunkResponse.FinalValue == nil {
+	chunkNumber, exists := index.ChunkCount(filePath)
+	if !exists || chunkNumber == 0 {
 		// TODO: Check if there is a better way to handle this case, for example with CandidateValue
-		return 0, fmt.Errorf(notFoundErrorTemplate, filePath)
-	}
-
-	chunkNumber, err := convert.BytesToI32(nbChunkResponse.FinalValue)
-	if err != nil {
-		return 0, fmt.Errorf("converting fetched data for key '%s': %w", nbChunkKey, err)
+		return 0, fmt.Errorf(notFoundErrorTemplate+": %w", filePath, pkgErrors.ErrNotFound)
 	}
 
 	return chunkNumber, nil
 }
 
-// GetFilesPathList fetches and returns the list of files for the website.
+// GetFilesPathList returns the list of files of the website.
 func GetFilesPathList(
-	client *node.Client,
+	ctx context.Context,
+	network *msConfig.NetworkInfos,
 	websiteAddress string,
 ) ([]string, error) {
-	// Try to get from cache first
-	if files, exists := globalFilePathListCache.get(websiteAddress); exists {
-		// Convert map back to slice
-		result := make([]string, 0, len(files))
-		for file := range files {
-			result = append(result, file)
-		}
-
-		return result, nil
-	}
-
-	filteredKeys, err := getFileLocationKeys(client, websiteAddress)
+	index, err := GetSiteIndex(ctx, network, websiteAddress)
 	if err != nil {
-		return nil, fmt.Errorf("fetching website file location keys: %w", err)
+		return nil, err
 	}
 
-	filesPathListResponse, err := node.ContractDatastoreEntries(client, websiteAddress, filteredKeys)
-	if err != nil {
-		return nil, fmt.Errorf("fetching website files path list: %w", err)
-	}
-
-	filesPathList := make([]string, len(filesPathListResponse))
-
-	for i, entry := range filesPathListResponse {
-		filesPathList[i] = string(entry.FinalValue)
-	}
-
-	// Store in cache
-	globalFilePathListCache.set(websiteAddress, filesPathList)
-
-	return filesPathList, nil
+	return index.Files(), nil
 }
 
-// getFileLocationKeys fetches and returns the keys for the file locations.
-func getFileLocationKeys(client *node.Client, websiteAddress string) ([][]byte, error) {
-	addressesInfo, err := node.Addresses(client, []string{websiteAddress})
+// GetOwner retrieves the owner of the website.
+func GetOwner(ctx context.Context, network *msConfig.NetworkInfos, websiteAddress string) (string, error) {
+	index, err := GetSiteIndex(ctx, network, websiteAddress)
 	if err != nil {
-		return nil, fmt.Errorf("converting website address: %w", err)
+		return "", err
 	}
 
-	addressInfo := addressesInfo[0]
-	keys := addressInfo.FinalDatastoreKeys
-
-	var filteredKeys [][]byte
-
-	for _, key := range keys {
-		if len(key) > len(storagekeys.FileLocationTag()) && bytes.Equal(key[:len(storagekeys.FileLocationTag())], storagekeys.FileLocationTag()) {
-			filteredKeys = append(filteredKeys, key)
-		}
-	}
-
-	return filteredKeys, nil
+	return index.Owner, nil
 }
 
-// fetchAllChunks retrieves all chunks of data for the website.
-func fetchAllChunks(client *node.Client, websiteAddress string, filePath string, chunkNumber int32) ([]byte, error) {
-	filePathHash := sha256.Sum256([]byte(filePath))
+// GetLastUpdateTimestamp retrieves the last update timestamp of the website.
+// It is given up after the last update timeout, or when ctx is done.
+func GetLastUpdateTimestamp(ctx context.Context, network *msConfig.NetworkInfos, websiteAddress string) (*time.Time, error) {
+	ctx, cancel := msConfig.WithTimeout(ctx, network.ReadOptions().LastUpdateTimeout)
+	defer cancel()
 
-	keys := make([][]byte, chunkNumber)
-	for i := 0; i < int(chunkNumber); i++ {
-		keys[i] = storagekeys.FileChunkKey(filePathHash[:], i)
+	values, _, err := readDatastore(ctx, network, websiteAddress, [][]byte{storagekeys.GlobalMetadataKey(lastUpdateTimestampKey)})
+	if err != nil {
+		return nil, fmt.Errorf("fetching website last update timestamp: %w", err)
 	}
 
-	var dataStore []byte
-	totalBatches := (int(chunkNumber) + datastoreBatchSize - 1) / datastoreBatchSize
+	return parseLastUpdateTimestamp(values[0])
+}
 
-	for batch := 0; batch < totalBatches; batch++ {
-		start := batch * datastoreBatchSize
+// GetLastUpdateTimestamps retrieves the last update timestamps of several websites, by datastore batches
+// so that many websites are checked with a few calls. The timestamp of a website without one is nil.
+// Each batch is given up after the last update timeout, or when ctx is done.
+func GetLastUpdateTimestamps(ctx context.Context, network *msConfig.NetworkInfos, websiteAddresses []string) ([]*time.Time, error) {
+	timestamps := make([]*time.Time, 0, len(websiteAddresses))
 
-		end := start + datastoreBatchSize
-		if end > int(chunkNumber) {
-			end = int(chunkNumber)
+	for batch := range slices.Chunk(websiteAddresses, datastoreBatchSize) {
+		requests := make([]datastoreEntryRequest, len(batch))
+		for i, address := range batch {
+			requests[i] = datastoreEntryRequest{Address: address, Key: storagekeys.GlobalMetadataKey(lastUpdateTimestampKey)}
 		}
 
-		batchKeys := keys[start:end]
-
-		response, err := node.ContractDatastoreEntries(client, websiteAddress, batchKeys)
+		values, err := readLastUpdateBatch(ctx, network, requests)
 		if err != nil {
-			return nil, fmt.Errorf("calling get_datastore_entries '%+v': %w", batchKeys, err)
+			return nil, fmt.Errorf("fetching websites last update timestamps: %w", err)
 		}
 
-		if len(response) != len(batchKeys) {
-			return nil, fmt.Errorf("expected %d entries, got %d", len(batchKeys), len(response))
-		}
-
-		for _, entry := range response {
-			if len(entry.FinalValue) == 0 {
-				return nil, fmt.Errorf("empty chunk")
+		for i, value := range values {
+			timestamp, err := parseLastUpdateTimestamp(value)
+			if err != nil && !errors.Is(err, pkgErrors.ErrNotFound) {
+				return nil, fmt.Errorf("website %s: %w", batch[i], err)
 			}
 
-			dataStore = append(dataStore, entry.FinalValue...)
+			timestamps = append(timestamps, timestamp)
 		}
-
-		logger.Debugf("Processed batch %d/%d", batch+1, totalBatches)
 	}
 
-	return dataStore, nil
+	return timestamps, nil
 }
 
-// GetOwner retrieves the owner of the website.
-func GetOwner(network *msConfig.NetworkInfos, websiteAddress string) (string, error) {
-	client := node.NewClient(network.NodeURL)
+// readLastUpdateBatch reads a batch of last update timestamps within the last update timeout.
+func readLastUpdateBatch(ctx context.Context, network *msConfig.NetworkInfos, requests []datastoreEntryRequest) ([][]byte, error) {
+	ctx, cancel := msConfig.WithTimeout(ctx, network.ReadOptions().LastUpdateTimeout)
+	defer cancel()
 
-	ownerResponse, err := node.FetchDatastoreEntry(client, websiteAddress, convert.ToBytes(ownerKey))
-	if err != nil {
-		return "", fmt.Errorf("fetching website owner: %w", err)
-	}
+	var values [][]byte
 
-	return string(ownerResponse.FinalValue), nil
-}
+	err := msConfig.NodeURLCall(ctx, network, func(nodeURL string) error {
+		var err error
 
-// GetLastUpdateTimestamp retrieves the last update timestamp of the website.
-func GetLastUpdateTimestamp(network *msConfig.NetworkInfos, websiteAddress string) (*time.Time, error) {
-	client := node.NewClient(network.NodeURL)
+		values, err = getEntries(ctx, nodeURL, requests)
 
-	lastUpdateTimestampResponse, err := node.FetchDatastoreEntry(client, websiteAddress, storagekeys.GlobalMetadataKey(lastUpdateTimestampKey))
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("fetching website last update timestamp: %w", err)
+		return nil, nodeCallError(err)
 	}
 
-	if lastUpdateTimestampResponse.FinalValue == nil {
-		return nil, fmt.Errorf("last update timestamp not found")
-	}
+	return values, nil
+}
 
-	timestampStr := string(lastUpdateTimestampResponse.FinalValue)
+// parseLastUpdateTimestamp parses the value of the LAST_UPDATE global metadata.
+func parseLastUpdateTimestamp(value []byte) (*time.Time, error) {
+	if value == nil {
+		return nil, fmt.Errorf("last update timestamp %w", pkgErrors.ErrNotFound)
+	}
 
-	castedLUTimestamp, err := strconv.ParseUint(timestampStr, 10, 64)
+	castedLUTimestamp, err := strconv.ParseUint(string(value), 10, 64)
 	if err != nil {
 		return nil, fmt.Errorf("converting website last update timestamp: %w", err)
 	}
@@ -358,33 +175,50 @@ func GetLastUpdateTimestamp(network *msConfig.NetworkInfos, websiteAddress strin
 	return &timestamp, nil
 }
 
-// Check if the requested filePath exists in the SC FilesPathList
-func FilePathExists(network *msConfig.NetworkInfos, websiteAddress string, filePath string) (bool, error) {
-	// Try to get from cache first
-	if files, exists := globalFilePathListCache.get(websiteAddress); exists {
-		_, exists := files[filePath]
-		return exists, nil
+// GetSPAFallback retrieves the single page app fallback setting of the website, from its SPA_FALLBACK global metadata.
+// It returns whether the fallback is enabled, and whether the setting is defined by the website,
+// an invalid setting being considered undefined.
+func GetSPAFallback(ctx context.Context, network *msConfig.NetworkInfos, websiteAddress string) (bool, bool, error) {
+	index, err := GetSiteIndex(ctx, network, websiteAddress)
+	if err != nil {
+		return false, false, err
 	}
 
-	// If not in cache, fetch from chain
-	client := node.NewClient(network.NodeURL)
-	if client == nil {
-		return false, fmt.Errorf("failed to create node client")
+	spaFallback, defined := index.GlobalMetadata(spaFallbackKey)
+	if !defined {
+		return false, false, nil
 	}
 
-	files, err := GetFilesPathList(client, websiteAddress)
+	// An invalid setting must not make every missing path fail, the website default behavior is used instead
+	enabled, err := strconv.ParseBool(spaFallback)
 	if err != nil {
-		return false, fmt.Errorf("failed to get files path list: %w", err)
+		logger.Warnf("Invalid SPA fallback setting %q of website %s, considering it undefined", spaFallback, websiteAddress)
+		return false, false, nil
 	}
 
-	// Store in cache for future use
-	globalFilePathListCache.set(websiteAddress, files)
+	return enabled, true, nil
+}
 
-	// Return the result from cache
-	if files, exists := globalFilePathListCache.get(websiteAddress); exists {
-		_, exists := files[filePath]
-		return exists, nil
+// GetBadgeMode retrieves the badge mode requested by the website, from its BADGE global metadata.
+// It returns an empty string if the website doesn't define it.
+func GetBadgeMode(ctx context.Context, network *msConfig.NetworkInfos, websiteAddress string) (string, error) {
+	index, err := GetSiteIndex(ctx, network, websiteAddress)
+	if err != nil {
+		return "", err
+	}
+
+	mode, _ := index.GlobalMetadata(badgeKey)
+
+	return mode, nil
+}
+
+// FilePathExists checks if the requested filePath is a file of the website, from its index.
+// Concurrent requests to a website without index share a single build of the index.
+func FilePathExists(ctx context.Context, network *msConfig.NetworkInfos, websiteAddress string, filePath string) (bool, error) {
+	index, err := GetSiteIndex(ctx, network, websiteAddress)
+	if err != nil {
+		return false, fmt.Errorf("failed to get files path list: %w", err)
 	}
 
-	return false, nil
+	return index.HasFile(filePath), nil
 }
@@ -0,0 +1,286 @@
+package website
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	msConfig "github.com/massalabs/deweb-server/pkg/config"
+	pkgErrors "github.com/massalabs/deweb-server/pkg/error"
+	"github.com/massalabs/deweb-server/pkg/website/storagekeys"
+	"github.com/massalabs/station/pkg/logger"
+	"golang.org/x/sync/errgroup"
+)
+
+// ChunkReader reads a website file from the chain chunk by chunk.
+// It implements io.ReadSeeker so that a byte range of the file can be served
+// by fetching only the chunks covering it.
+//
+// Files are usually split into chunks of ChunkSize bytes, but the uploader can use another size.
+// The size of the first chunk is used as the size of every chunk except the last one.
+type ChunkReader struct {
+	// ctx is the context of the request the file is read for, the chunks are not fetched once it is done.
+	ctx            context.Context
+	network        *msConfig.NetworkInfos
+	websiteAddress string
+	filePathHash   []byte
+
+	chunkCount int
+	chunkSize  int64
+	size       int64
+	offset     int64
+
+	// chunks holds the last fetched chunks, indexed by their position in the file.
+	chunks map[int][]byte
+	// lastChunk is kept apart as it is fetched when opening the file.
+	lastChunk []byte
+	// nextIndex is the index following the last fetched chunks, used to detect sequential reads.
+	nextIndex int
+	// readAhead is the number of chunks fetched at once, it grows while the file is read sequentially.
+	readAhead int
+	// workers is the maximum number of datastore batches fetched concurrently.
+	workers int
+}
+
+// NewChunkReader returns a reader over the given website file.
+// The first and last chunks of the file are fetched to compute its size.
+// The reader fetches the chunks for the request of ctx, reads fail once it is done.
+func NewChunkReader(ctx context.Context, network *msConfig.NetworkInfos, websiteAddress string, filePath string) (*ChunkReader, error) {
+	index, err := GetSiteIndex(ctx, network, websiteAddress)
+	if err != nil {
+		return nil, fmt.Errorf("checking if file is present on chain: %w", err)
+	}
+
+	chunkNumber, isPresent := index.ChunkCount(filePath)
+	if !isPresent {
+		return nil, fmt.Errorf("file '%s' %w on chain", filePath, pkgErrors.ErrNotFound)
+	}
+
+	if chunkNumber <= 0 {
+		return nil, fmt.Errorf("no chunks found for file '%s': %w", filePath, pkgErrors.ErrNotFound)
+	}
+
+	filePathHash := sha256.Sum256([]byte(filePath))
+
+	reader, err := openChunkReader(ctx, network, websiteAddress, filePathHash[:], int(chunkNumber))
+	if err != nil {
+		return nil, fmt.Errorf("opening file '%s': %w", filePath, err)
+	}
+
+	logger.Debugf("File '%s' has %d chunks of %d bytes, total size: %d bytes", filePath, reader.chunkCount, reader.chunkSize, reader.size)
+
+	return reader, nil
+}
+
+// openChunkReader returns a reader over the file made of chunkCount chunks stored under filePathHash.
+func openChunkReader(
+	ctx context.Context,
+	network *msConfig.NetworkInfos,
+	websiteAddress string,
+	filePathHash []byte,
+	chunkCount int,
+) (*ChunkReader, error) {
+	reader := &ChunkReader{
+		ctx:            ctx,
+		network:        network,
+		websiteAddress: websiteAddress,
+		filePathHash:   filePathHash,
+		chunkCount:     chunkCount,
+		nextIndex:      1,
+		// Reading the file from its start is the most common case, so it is streamed by full datastore batches.
+		readAhead: datastoreBatchSize,
+		workers:   max(network.ReadOptions().ChunkFetchWorkers, 1),
+	}
+
+	lastIndex := reader.chunkCount - 1
+
+	indexes := []int{0}
+	if lastIndex > 0 {
+		indexes = append(indexes, lastIndex)
+	}
+
+	fetchCtx, cancel := msConfig.WithTimeout(ctx, network.ReadOptions().ReadTimeout)
+	defer cancel()
+
+	chunks, err := reader.fetchBatch(fetchCtx, indexes)
+	if err != nil {
+		return nil, fmt.Errorf("fetching first and last chunks: %w", err)
+	}
+
+	reader.chunks = map[int][]byte{0: chunks[0]}
+	reader.lastChunk = chunks[len(chunks)-1]
+
+	reader.chunkSize = int64(len(chunks[0]))
+	if int64(len(reader.lastChunk)) > reader.chunkSize {
+		return nil, fmt.Errorf("last chunk is bigger than the other chunks: %w", pkgErrors.ErrCorruptedChunk)
+	}
+
+	reader.size = int64(lastIndex)*reader.chunkSize + int64(len(reader.lastChunk))
+
+	return reader, nil
+}
+
+// Size returns the size of the file in bytes.
+func (r *ChunkReader) Size() int64 {
+	return r.size
+}
+
+// Read reads up to len(p) bytes from the current chunk, fetching it if needed.
+func (r *ChunkReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	index := int(r.offset / r.chunkSize)
+
+	chunk, err := r.chunk(index)
+	if err != nil {
+		return 0, err
+	}
+
+	n := copy(p, chunk[r.offset-int64(index)*r.chunkSize:])
+	r.offset += int64(n)
+
+	return n, nil
+}
+
+// Seek implements io.Seeker. No chunk is fetched until the next Read.
+func (r *ChunkReader) Seek(offset int64, whence int) (int64, error) {
+	var newOffset int64
+
+	switch whence {
+	case io.SeekStart:
+		newOffset = offset
+	case io.SeekCurrent:
+		newOffset = r.offset + offset
+	case io.SeekEnd:
+		newOffset = r.size + offset
+	default:
+		return 0, fmt.Errorf("invalid whence: %d", whence)
+	}
+
+	if newOffset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	r.offset = newOffset
+
+	return newOffset, nil
+}
+
+// chunk returns the chunk at the given index.
+// Sequential reads fetch a growing number of chunks at once, up to a datastore batch per worker,
+// while random accesses only fetch the needed chunk.
+func (r *ChunkReader) chunk(index int) ([]byte, error) {
+	if index == r.chunkCount-1 {
+		return r.lastChunk, nil
+	}
+
+	if chunk, ok := r.chunks[index]; ok {
+		return chunk, nil
+	}
+
+	if index == r.nextIndex {
+		r.readAhead = min(r.readAhead*2, datastoreBatchSize*r.workers)
+	} else {
+		r.readAhead = 1
+	}
+
+	end := min(index+r.readAhead, r.chunkCount-1)
+
+	indexes := make([]int, 0, end-index)
+	for i := index; i < end; i++ {
+		indexes = append(indexes, i)
+	}
+
+	ctx, cancel := msConfig.WithTimeout(r.ctx, r.network.ReadOptions().ReadTimeout)
+	defer cancel()
+
+	chunks, err := r.fetchChunks(ctx, indexes)
+	if err != nil {
+		return nil, err
+	}
+
+	r.chunks = make(map[int][]byte, len(chunks))
+
+	for i, chunk := range chunks {
+		chunkIndex := indexes[i]
+		if int64(len(chunk)) != r.chunkSize {
+			return nil, fmt.Errorf("%w: unexpected size for chunk %d: expected %d bytes, got %d", pkgErrors.ErrCorruptedChunk, chunkIndex, r.chunkSize, len(chunk))
+		}
+
+		r.chunks[chunkIndex] = chunk
+	}
+
+	r.nextIndex = end
+
+	logger.Debugf("Fetched chunks %d to %d of %d", index, end-1, r.chunkCount)
+
+	return r.chunks[index], nil
+}
+
+// fetchChunks fetches the chunks at the given indexes by datastore batches,
+// up to r.workers batches being fetched concurrently. The chunks are returned in the order of indexes,
+// and the remaining batches are not fetched once a batch fails.
+func (r *ChunkReader) fetchChunks(ctx context.Context, indexes []int) ([][]byte, error) {
+	if len(indexes) <= datastoreBatchSize {
+		return r.fetchBatch(ctx, indexes)
+	}
+
+	chunks := make([][]byte, len(indexes))
+
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(max(r.workers, 1))
+
+	for start := 0; start < len(indexes); start += datastoreBatchSize {
+		end := min(start+datastoreBatchSize, len(indexes))
+
+		group.Go(func() error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			batch, err := r.fetchBatch(ctx, indexes[start:end])
+			if err != nil {
+				return err
+			}
+
+			copy(chunks[start:end], batch)
+
+			return nil
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+
+	return chunks, nil
+}
+
+// fetchBatch fetches the chunks at the given indexes in a single datastore call.
+func (r *ChunkReader) fetchBatch(ctx context.Context, indexes []int) ([][]byte, error) {
+	keys := make([][]byte, len(indexes))
+	for i, index := range indexes {
+		keys[i] = storagekeys.FileChunkKey(r.filePathHash, index)
+	}
+
+	chunks, servedBy, err := readDatastore(ctx, r.network, r.websiteAddress, keys)
+	if err != nil {
+		return nil, fmt.Errorf("fetching chunks %d to %d: %w", indexes[0], indexes[len(indexes)-1], err)
+	}
+
+	for _, chunk := range chunks {
+		if len(chunk) == 0 {
+			return nil, fmt.Errorf("%w: empty chunk", pkgErrors.ErrCorruptedChunk)
+		}
+	}
+
+	if err := verifyEntries(ctx, r.network, r.websiteAddress, servedBy, keys, chunks); err != nil {
+		return nil, err
+	}
+
+	return chunks, nil
+}
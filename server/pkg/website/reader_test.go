@@ -0,0 +1,274 @@
+package website
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	msConfig "github.com/massalabs/deweb-server/pkg/config"
+	"github.com/massalabs/deweb-server/pkg/website/storagekeys"
+)
+
+var errDatastore = errors.New("datastore error")
+
+// fakeDatastore serves the chunks of a file in place of the node.
+type fakeDatastore struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	// latency is the duration of each call.
+	latency time.Duration
+	// failingKey makes the calls reading it fail without waiting for latency.
+	failingKey string
+	calls      int
+}
+
+func newFakeDatastore(filePathHash []byte, chunkCount int, chunkSize int, latency time.Duration) *fakeDatastore {
+	entries := make(map[string][]byte, chunkCount)
+
+	for i := range chunkCount {
+		entries[string(storagekeys.FileChunkKey(filePathHash, i))] = bytes.Repeat([]byte{byte(i)}, chunkSize)
+	}
+
+	return &fakeDatastore{entries: entries, latency: latency}
+}
+
+func (d *fakeDatastore) read(_ context.Context, _, _ string, keys [][]byte) ([][]byte, error) {
+	d.mu.Lock()
+	d.calls++
+
+	for _, key := range keys {
+		if string(key) == d.failingKey {
+			d.mu.Unlock()
+			return nil, errDatastore
+		}
+	}
+
+	d.mu.Unlock()
+
+	time.Sleep(d.latency)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		values[i] = d.entries[string(key)]
+	}
+
+	return values, nil
+}
+
+// useFakeDatastore makes the readers of the test read from datastore,
+// and returns a network whose readers fetch batches with the given number of workers.
+func useFakeDatastore(tb testing.TB, datastore *fakeDatastore, workers int) *msConfig.NetworkInfos {
+	tb.Helper()
+
+	previousReadEntries := readEntries
+
+	tb.Cleanup(func() {
+		readEntries = previousReadEntries
+	})
+
+	readEntries = datastore.read
+
+	reads := msConfig.DefaultReadOptions()
+	reads.ChunkFetchWorkers = workers
+
+	return &msConfig.NetworkInfos{Reads: &reads}
+}
+
+func TestChunkReaderParallelBatches(t *testing.T) {
+	filePathHash := sha256.Sum256([]byte("index.js"))
+	chunkCount := 10*datastoreBatchSize + 3
+
+	datastore := newFakeDatastore(filePathHash[:], chunkCount, 10, 0)
+	network := useFakeDatastore(t, datastore, 4)
+
+	reader, err := openChunkReader(context.Background(), network, "AS1", filePathHash[:], chunkCount)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+
+	if len(content) != chunkCount*10 {
+		t.Fatalf("Expected %d bytes, got %d", chunkCount*10, len(content))
+	}
+
+	for i := range chunkCount {
+		if content[i*10] != byte(i) {
+			t.Fatalf("Chunk %d is out of order", i)
+		}
+	}
+}
+
+func TestChunkReaderBatchError(t *testing.T) {
+	filePathHash := sha256.Sum256([]byte("index.js"))
+	chunkCount := 10 * datastoreBatchSize
+
+	datastore := newFakeDatastore(filePathHash[:], chunkCount, 10, 20*time.Millisecond)
+	network := useFakeDatastore(t, datastore, 2)
+
+	reader, err := openChunkReader(context.Background(), network, "AS1", filePathHash[:], chunkCount)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+
+	// The second batch fails while the first one is being fetched
+	datastore.failingKey = string(storagekeys.FileChunkKey(filePathHash[:], datastoreBatchSize+1))
+	datastore.calls = 0
+
+	indexes := make([]int, chunkCount)
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	if _, err := reader.fetchChunks(context.Background(), indexes); !errors.Is(err, errDatastore) {
+		t.Fatalf("Expected the datastore error, got %v", err)
+	}
+
+	// Only the batches started along with the failing one may have been fetched
+	if datastore.calls > 2 {
+		t.Errorf("Expected the batches after the failing one not to be fetched, got %d calls", datastore.calls)
+	}
+}
+
+func TestChunkReaderRange(t *testing.T) {
+	filePathHash := sha256.Sum256([]byte("video.mp4"))
+	chunkCount := 5
+	chunkSize := 10
+
+	// The last chunk is only partially filled
+	content := make([]byte, (chunkCount-1)*chunkSize+4)
+	for i := range content {
+		content[i] = byte(i)
+	}
+
+	datastore := &fakeDatastore{entries: map[string][]byte{}}
+	for i := range chunkCount {
+		end := min((i+1)*chunkSize, len(content))
+		datastore.entries[string(storagekeys.FileChunkKey(filePathHash[:], i))] = content[i*chunkSize : end]
+	}
+
+	network := useFakeDatastore(t, datastore, 1)
+
+	etag := `"v1"`
+
+	testCases := []struct {
+		name       string
+		rangeValue string
+		ifRange    string
+		statusCode int
+		expected   []byte
+		// calls is the number of datastore calls after the file was opened with its first and last chunks.
+		calls int
+	}{
+		{"First chunk", "bytes=0-9", "", http.StatusPartialContent, content[:10], 0},
+		{"Middle range", "bytes=15-27", "", http.StatusPartialContent, content[15:28], 1},
+		{"Last partial chunk", "bytes=40-43", "", http.StatusPartialContent, content[40:], 0},
+		{"Suffix range", "bytes=-6", "", http.StatusPartialContent, content[len(content)-6:], 1},
+		{"If-Range matching", "bytes=0-9", etag, http.StatusPartialContent, content[:10], 0},
+		{"If-Range not matching", "bytes=0-9", `"v0"`, http.StatusOK, content, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reader, err := openChunkReader(context.Background(), network, "AS1", filePathHash[:], chunkCount)
+			if err != nil {
+				t.Fatalf("Failed to open file: %v", err)
+			}
+
+			datastore.calls = 0
+
+			r := httptest.NewRequest(http.MethodGet, "/video.mp4", nil)
+			r.Header.Set("Range", tc.rangeValue)
+
+			if tc.ifRange != "" {
+				r.Header.Set("If-Range", tc.ifRange)
+			}
+
+			w := httptest.NewRecorder()
+			w.Header().Set("ETag", etag)
+
+			http.ServeContent(w, r, "video.mp4", time.Time{}, reader)
+
+			if w.Code != tc.statusCode {
+				t.Errorf("Expected status %d, got %d", tc.statusCode, w.Code)
+			}
+
+			if !bytes.Equal(w.Body.Bytes(), tc.expected) {
+				t.Errorf("Expected body %v, got %v", tc.expected, w.Body.Bytes())
+			}
+
+			if datastore.calls != tc.calls {
+				t.Errorf("Expected %d datastore calls, got %d", tc.calls, datastore.calls)
+			}
+		})
+	}
+}
+
+func BenchmarkChunkReader(b *testing.B) {
+	filePathHash := sha256.Sum256([]byte("index.js"))
+	// The duration of a read is dominated by the node latency, so small chunks are enough
+	chunkCount := 32 * datastoreBatchSize
+	chunkSize := 1000
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			datastore := newFakeDatastore(filePathHash[:], chunkCount, chunkSize, 5*time.Millisecond)
+			network := useFakeDatastore(b, datastore, workers)
+
+			b.SetBytes(int64(chunkCount * chunkSize))
+
+			for range b.N {
+				reader, err := openChunkReader(context.Background(), network, "AS1", filePathHash[:], chunkCount)
+				if err != nil {
+					b.Fatalf("Failed to open file: %v", err)
+				}
+
+				if _, err := io.Copy(io.Discard, reader); err != nil {
+					b.Fatalf("Failed to read file: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestChunkReaderContext(t *testing.T) {
+	filePathHash := sha256.Sum256([]byte("index.js"))
+	chunkCount := 4 * datastoreBatchSize
+
+	datastore := newFakeDatastore(filePathHash[:], chunkCount, 10, 0)
+	network := useFakeDatastore(t, datastore, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	reader, err := openChunkReader(ctx, network, "AS1", filePathHash[:], chunkCount)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+
+	// The request is gone, the remaining chunks are not fetched
+	cancel()
+
+	datastore.calls = 0
+
+	if _, err := io.ReadAll(reader); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	if datastore.calls != 0 {
+		t.Errorf("Expected no call once the request is cancelled, got %d", datastore.calls)
+	}
+}
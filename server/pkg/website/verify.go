@@ -0,0 +1,160 @@
+package website
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	msConfig "github.com/massalabs/deweb-server/pkg/config"
+	pkgErrors "github.com/massalabs/deweb-server/pkg/error"
+	"github.com/massalabs/deweb-server/pkg/nodepool"
+	"github.com/massalabs/station/pkg/logger"
+)
+
+var (
+	verifiedReads   atomic.Uint64
+	mismatchedReads atomic.Uint64
+	unverifiedReads atomic.Uint64
+)
+
+// VerificationStats counts the reads verified against several nodes since the server started.
+type VerificationStats struct {
+	// Verified is the number of reads every verification node returned identically.
+	Verified uint64
+	// Mismatches is the number of reads at least one verification node returned differently.
+	Mismatches uint64
+	// Unverified is the number of reads which could not be verified as not enough other nodes answered.
+	Unverified uint64
+}
+
+// GetVerificationStats returns the verification counters.
+func GetVerificationStats() VerificationStats {
+	return VerificationStats{
+		Verified:   verifiedReads.Load(),
+		Mismatches: mismatchedReads.Load(),
+		Unverified: unverifiedReads.Load(),
+	}
+}
+
+// verifyEntries reads the entries at keys again from other nodes of the network than servedBy, the node
+// the values were read from, and compares them with these values. A read is verified once the configured number
+// of other nodes returned it identically, see msConfig.ReadOptions. In VerificationFail mode, it returns an error wrapping ErrNodeMismatch
+// if a node returns different values, or ErrUnverified if not enough nodes answered. Otherwise it is only logged.
+// Only entry values are verified: the lists of keys are not, as nodes may be a few slots apart.
+func verifyEntries(
+	ctx context.Context,
+	network *msConfig.NetworkInfos,
+	websiteAddress, servedBy string,
+	keys [][]byte,
+	values [][]byte,
+) error {
+	conf := network.ReadOptions()
+	if conf.VerificationMode == msConfig.VerificationOff || len(keys) == 0 {
+		return nil
+	}
+
+	var nodes []nodepool.Node
+	if network.Nodes != nil {
+		nodes = network.Nodes.Available(conf.VerificationNodes, servedBy)
+	}
+
+	hashes := make([][]byte, len(nodes))
+	errs := make([]error, len(nodes))
+
+	var wg sync.WaitGroup
+
+	for i, n := range nodes {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			errs[i] = network.Nodes.DoNodeURL(ctx, n.URL, func(nodeURL string) error {
+				nodeValues, err := readEntries(ctx, nodeURL, websiteAddress, keys)
+				if err != nil {
+					return err
+				}
+
+				hashes[i] = hashEntries(nodeValues)
+
+				return nil
+			})
+		}()
+	}
+
+	wg.Wait()
+
+	// The nodes which did not answer in time are not to blame
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+
+	expected := hashEntries(values)
+	answered := 0
+
+	var mismatches []string
+
+	for i, n := range nodes {
+		if errs[i] != nil {
+			logger.Warnf("Failed to verify entries of website %s on node %s: %v", websiteAddress, n.URL, errs[i])
+			continue
+		}
+
+		answered++
+
+		if !bytes.Equal(hashes[i], expected) {
+			mismatches = append(mismatches, n.URL)
+		}
+	}
+
+	var err error
+
+	switch {
+	case len(mismatches) > 0:
+		mismatchedReads.Add(1)
+
+		err = fmt.Errorf("%w: %d entries of website %s read from %s differ on %s",
+			pkgErrors.ErrNodeMismatch, len(keys), websiteAddress, servedBy, strings.Join(mismatches, ", "))
+	case answered < conf.VerificationNodes:
+		unverifiedReads.Add(1)
+
+		err = fmt.Errorf("%w: %d entries of website %s read from %s were verified by %d of %d nodes",
+			pkgErrors.ErrUnverified, len(keys), websiteAddress, servedBy, answered, conf.VerificationNodes)
+	default:
+		verifiedReads.Add(1)
+
+		return nil
+	}
+
+	if conf.VerificationMode == msConfig.VerificationFail {
+		return err
+	}
+
+	logger.Warnf("Serving unverified data: %v", err)
+
+	return nil
+}
+
+// hashEntries returns the hash of the given entry values, each one being prefixed by whether it exists
+// and its length, so that a missing entry doesn't match an empty one and values can't be shifted from one entry to another.
+func hashEntries(values [][]byte) []byte {
+	hash := sha256.New()
+
+	for _, value := range values {
+		if value == nil {
+			hash.Write([]byte{0})
+			continue
+		}
+
+		hash.Write([]byte{1})
+		_ = binary.Write(hash, binary.BigEndian, uint64(len(value)))
+		hash.Write(value)
+	}
+
+	return hash.Sum(nil)
+}
@@ -0,0 +1,171 @@
+package website
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	msConfig "github.com/massalabs/deweb-server/pkg/config"
+	pkgErrors "github.com/massalabs/deweb-server/pkg/error"
+	"github.com/massalabs/deweb-server/pkg/nodepool"
+)
+
+func TestVerifyEntries(t *testing.T) {
+	errUnreachable := errors.New("connection refused")
+	keys := [][]byte{[]byte("key1"), []byte("key2")}
+	values := [][]byte{[]byte("value1"), []byte("value2")}
+	tampered := [][]byte{[]byte("value1"), []byte("tampered")}
+
+	testCases := []struct {
+		name        string
+		mode        string
+		nodeValues  map[string][][]byte
+		expectedErr error
+		expected    VerificationStats
+	}{
+		{
+			name:       "Verification disabled",
+			mode:       msConfig.VerificationOff,
+			nodeValues: map[string][][]byte{"node2": tampered, "node3": tampered},
+		},
+		{
+			name:       "Same values",
+			mode:       msConfig.VerificationFail,
+			nodeValues: map[string][][]byte{"node2": values, "node3": values},
+			expected:   VerificationStats{Verified: 1},
+		},
+		{
+			name:        "Tampered values",
+			mode:        msConfig.VerificationFail,
+			nodeValues:  map[string][][]byte{"node2": values, "node3": tampered},
+			expectedErr: pkgErrors.ErrNodeMismatch,
+			expected:    VerificationStats{Mismatches: 1},
+		},
+		{
+			name:       "Tampered values only logged",
+			mode:       msConfig.VerificationWarn,
+			nodeValues: map[string][][]byte{"node2": values, "node3": tampered},
+			expected:   VerificationStats{Mismatches: 1},
+		},
+		{
+			name:       "Values shifted between entries",
+			mode:       msConfig.VerificationWarn,
+			nodeValues: map[string][][]byte{"node2": {[]byte("value1value2"), nil}, "node3": values},
+			expected:   VerificationStats{Mismatches: 1},
+		},
+		{
+			name:        "Unreachable verification node",
+			mode:        msConfig.VerificationFail,
+			nodeValues:  map[string][][]byte{"node2": values},
+			expectedErr: pkgErrors.ErrUnverified,
+			expected:    VerificationStats{Unverified: 1},
+		},
+		{
+			name:       "Unreachable verification node only logged",
+			mode:       msConfig.VerificationWarn,
+			nodeValues: map[string][][]byte{"node2": values},
+			expected:   VerificationStats{Unverified: 1},
+		},
+		{
+			name:        "Only the serving node reachable",
+			mode:        msConfig.VerificationFail,
+			nodeValues:  map[string][][]byte{"node1": values},
+			expectedErr: pkgErrors.ErrUnverified,
+			expected:    VerificationStats{Unverified: 1},
+		},
+	}
+
+	defer func() { readEntries = getDatastoreEntries }()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pool, err := nodepool.New([]string{"node1", "node2", "node3"}, nodepool.DefaultOptions())
+			if err != nil {
+				t.Fatalf("Failed to create pool: %v", err)
+			}
+
+			reads := msConfig.ReadOptions{VerificationMode: tc.mode, VerificationNodes: 2}
+			network := &msConfig.NetworkInfos{NodeURL: "node1", Nodes: pool, Reads: &reads}
+
+			readEntries = func(_ context.Context, nodeURL, _ string, _ [][]byte) ([][]byte, error) {
+				// The values were read from node1, it must not verify them
+				if nodeURL == "node1" {
+					t.Error("Expected the serving node not to be called")
+				}
+
+				nodeValues, ok := tc.nodeValues[nodeURL]
+				if !ok {
+					return nil, errUnreachable
+				}
+
+				return nodeValues, nil
+			}
+
+			before := GetVerificationStats()
+
+			err = verifyEntries(context.Background(), network, "AS1", "node1", keys, values)
+			if !errors.Is(err, tc.expectedErr) {
+				t.Errorf("Expected error %v, got %v", tc.expectedErr, err)
+			}
+
+			after := GetVerificationStats()
+
+			stats := VerificationStats{
+				Verified:   after.Verified - before.Verified,
+				Mismatches: after.Mismatches - before.Mismatches,
+				Unverified: after.Unverified - before.Unverified,
+			}
+			if stats != tc.expected {
+				t.Errorf("Expected stats %+v, got %+v", tc.expected, stats)
+			}
+		})
+	}
+}
+
+func TestVerifyEntriesThroughPool(t *testing.T) {
+	defer func() { readEntries = getDatastoreEntries }()
+
+	pool, err := nodepool.New([]string{"node1", "node2", "node3"}, nodepool.Options{FailureThreshold: 1, OpenDuration: time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to create pool: %v", err)
+	}
+
+	reads := msConfig.ReadOptions{VerificationMode: msConfig.VerificationWarn, VerificationNodes: 2}
+	network := &msConfig.NetworkInfos{NodeURL: "node1", Nodes: pool, Reads: &reads}
+
+	readEntries = func(_ context.Context, nodeURL, _ string, keys [][]byte) ([][]byte, error) {
+		if nodeURL == "node3" {
+			return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+		}
+
+		return make([][]byte, len(keys)), nil
+	}
+
+	if err := verifyEntries(context.Background(), network, "AS1", "node1", [][]byte{[]byte("key")}, [][]byte{nil}); err != nil {
+		t.Fatalf("Expected the failure to be only logged, got %v", err)
+	}
+
+	// The failure of the verification node is recorded by the pool
+	for _, n := range pool.Available(3) {
+		if n.URL == "node3" {
+			t.Errorf("Expected node3 to be skipped after failing a verification")
+		}
+	}
+}
+
+func TestHashEntries(t *testing.T) {
+	if bytes.Equal(hashEntries([][]byte{nil}), hashEntries([][]byte{{}})) {
+		t.Error("Expected a missing entry not to hash like an empty one")
+	}
+
+	if bytes.Equal(hashEntries([][]byte{[]byte("ab"), nil}), hashEntries([][]byte{[]byte("a"), []byte("b")})) {
+		t.Error("Expected values shifted between entries not to hash the same")
+	}
+
+	if !bytes.Equal(hashEntries([][]byte{[]byte("a"), nil}), hashEntries([][]byte{[]byte("a"), nil})) {
+		t.Error("Expected the same entries to hash the same")
+	}
+}